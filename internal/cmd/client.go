@@ -0,0 +1,32 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/rest/client"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+// NewClient creates a client of the running auto-ssh, found through its socket, the web
+// configuration or the rest flags
+func NewClient() *client.Client {
+	return client.NewClient(client.OptionWeb(config.C.Web))
+}
+
+// ParseFilters reads --filter values, each written key=<key>,values=<value>;<value>
+func ParseFilters(filters []string) (managerModels.FiltersInput, error) {
+	input := managerModels.FiltersInput{}
+	for _, filter := range filters {
+		parsed, ok := managerModels.ParseFilter(filter)
+		if !ok {
+			return input, fmt.Errorf("filter (%s) is invalid.  Must be key=<key>,values=<value>;<value>", filter)
+		}
+		input.Filters = append(input.Filters, parsed)
+	}
+	return input, nil
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package monitor
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"us.figge.auto-ssh/internal/resources/engine/stats"
+)
+
+const (
+	retryInterval = 2 * time.Second
+)
+
+// feed connects to the stats port, passing on every update, and reconnects whenever
+// the connection is lost until the context ends.  Connection changes are reported as
+// status messages
+func feed(ctx context.Context, address string, updates chan<- []*stats.Data, status chan<- string) {
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			if !send(ctx, status, fmt.Sprintf("connected to %s", address)) {
+				_ = conn.Close()
+				return
+			}
+			connCtx, closeConn := context.WithCancel(ctx)
+			go func() {
+				<-connCtx.Done()
+				_ = conn.Close()
+			}()
+			err = readUpdates(conn, func(rows []*stats.Data) {
+				select {
+				case updates <- rows:
+				case <-ctx.Done():
+				}
+			})
+			closeConn()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if !send(ctx, status, fmt.Sprintf("waiting for auto-ssh on %s: %v", address, err)) {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// send passes on a status message, unless the context ends first
+func send(ctx context.Context, status chan<- string, message string) bool {
+	select {
+	case status <- message:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// readUpdates reads json updates, each padded with zeros, until the reader ends
+func readUpdates(r io.Reader, fn func(rows []*stats.Data)) error {
+	reader := bufio.NewReader(r)
+	for {
+		chunk, err := reader.ReadBytes(0)
+		if len(chunk) > 0 && chunk[len(chunk)-1] == 0 {
+			chunk = chunk[:len(chunk)-1]
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if len(chunk) == 0 {
+			continue
+		}
+		var rows []*stats.Data
+		if err = json.Unmarshal(chunk, &rows); err != nil {
+			return fmt.Errorf("unreadable stats: %w", err)
+		}
+		fn(rows)
+	}
+}
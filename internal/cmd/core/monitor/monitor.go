@@ -0,0 +1,503 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/resources/engine/stats"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+const (
+	refreshInterval = time.Second
+	escapeTimeout   = 50 * time.Millisecond
+	helpLine        = "q quit  up/down select  1-9 sort  r reverse  s start  x stop  c colour"
+)
+
+var (
+	ErrStatsDisabled = errors.New("stats port disabled.  monitor.statsPort=-1")
+
+	statsPort int
+	noColor   bool
+	once      bool
+)
+
+var launcherMonitorCmd = &cobra.Command{
+	Use:   "monitor",
+	Short: "Shows a live dashboard of the tunnels of a running auto-ssh",
+	Long: `Connects to the stats port of a running auto-ssh and shows its tunnels, sorted and coloured as
+monitor in the configuration describes.  The most recently used tunnel is highlighted, and jump
+tunnels, those whose host is reached through a jump host, are shown in their own colour.
+monitor.compressed leaves out everything but the table.
+
+Keys:  up/down or k/j select a tunnel, 1-9 sort by that column (again to reverse), r reverses
+the sort, s starts and x stops the selected tunnel, c toggles colour and q quits.
+
+When the output is not a terminal, or --output is other than table, the tunnels are printed
+each time the stats change.  --once prints them a single time`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := launchMonitor()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	flag.AddFlags(launcherMonitorCmd, flag.Core, flag.Server, flag.Token, flag.ClientCert)
+	launcherMonitorCmd.Flags().IntVar(&statsPort, "port", 0, "stats port of the running auto-ssh.  Defaults to monitor.statsPort")
+	launcherMonitorCmd.Flags().BoolVar(&noColor, "no-color", false, "show the monitor without colour")
+	launcherMonitorCmd.Flags().BoolVar(&once, "once", false, "print the tunnels once and exit")
+	cmd.RootCmd.AddCommand(launcherMonitorCmd)
+}
+
+// monitor is what the dashboard shows, and the choices made with the keyboard
+type monitor struct {
+	address    string
+	cols       []*column
+	order      []*sortKey
+	sortBy     *sortKey
+	units      string
+	compressed bool
+	palette    *palette
+	colored    bool
+	rows       []*stats.Data
+	selected   string
+	offset     int
+	status     string
+	message    string
+}
+
+// result is the outcome of starting or stopping a tunnel
+type result struct {
+	id     string
+	status *config.Status
+	err    error
+}
+
+func launchMonitor() error {
+	cfg := config.C.Monitor
+	if cfg == nil {
+		cfg = config.NewConfig().Monitor
+	}
+	port := cfg.StatsPort
+	if statsPort != 0 {
+		port = statsPort
+	}
+	if port == -1 {
+		return ErrStatsDisabled
+	}
+
+	v := config.NewValidations()
+	m := &monitor{
+		address:    fmt.Sprintf("127.0.0.1:%d", port),
+		cols:       columns(&v, cfg.Metrics),
+		order:      sortKeys(&v, cfg.SortOrder),
+		units:      cfg.Units,
+		compressed: cfg.Compressed,
+		palette:    newPalette(&v, cfg.Color, noColor),
+		status:     "waiting for stats",
+	}
+	m.colored = m.palette.enabled
+	_ = v.Output(nil)
+	if len(m.cols) == 0 {
+		return fmt.Errorf("monitor.metrics has nothing to show.  Must include some of: %s", strings.Join(titles(), ", "))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	updates := make(chan []*stats.Data)
+	status := make(chan string, 1)
+	go feed(ctx, m.address, updates, status)
+	if once || cmd.Formatted() || !interactive() {
+		return m.print(ctx, updates, status)
+	}
+	return m.run(ctx, stop, updates, status)
+}
+
+// print writes the tunnels each time the stats change, or only once with --once.  Status
+// messages go to stderr, keeping them out of the output
+func (m *monitor) print(ctx context.Context, updates <-chan []*stats.Data, status <-chan string) error {
+	m.palette.enabled = false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case s := <-status:
+			_, _ = fmt.Fprintf(os.Stderr, "%s\n", s)
+		case m.rows = <-updates:
+			sortRows(m.rows, m.sortOrder())
+			if err := m.printRows(time.Now()); err != nil || once {
+				return err
+			}
+		}
+	}
+}
+
+func (m *monitor) printRows(now time.Time) error {
+	if !cmd.Formatted() {
+		header, lines := layout(m.cols, m.rows, m.units, now)
+		fmt.Printf("%s\n%s\n\n", header, strings.Join(lines, "\n"))
+		return nil
+	}
+	snapshots := make([]*tunnelSnapshot, 0, len(m.rows))
+	for _, row := range m.rows {
+		snapshots = append(snapshots, snapshot(row))
+	}
+	var headers []string
+	for _, col := range m.cols {
+		headers = append(headers, col.title)
+	}
+	table := cmd.NewTable(headers...)
+	for _, row := range cells(m.cols, m.rows, m.units, now) {
+		table.Row(row...)
+	}
+	return cmd.Print(snapshots, table)
+}
+
+// tunnelSnapshot is a tunnel's stats, for --output other than table
+type tunnelSnapshot struct {
+	Id         string     `json:"id"`
+	Name       string     `json:"name"`
+	Port       int        `json:"port"`
+	State      string     `json:"state"`
+	Received   int64      `json:"received"`
+	Sent       int64      `json:"sent"`
+	Open       int        `json:"open"`
+	Used       int        `json:"used"`
+	Jump       bool       `json:"jump"`
+	LastUpdate *time.Time `json:"lastUpdate,omitempty"`
+}
+
+func snapshot(row *stats.Data) *tunnelSnapshot {
+	s := &tunnelSnapshot{
+		Id:       row.Id,
+		Name:     row.Name,
+		Port:     row.Port,
+		State:    row.State,
+		Received: row.In,
+		Sent:     row.Out,
+		Open:     row.Connected,
+		Used:     row.Connections,
+		Jump:     row.JumpTunnel,
+	}
+	if !row.LastUpdate.IsZero() {
+		s.LastUpdate = &row.LastUpdate
+	}
+	return s
+}
+
+// run takes over the terminal, redrawing whenever the stats change, a key is pressed,
+// or a second passes so the ages stay current
+func (m *monitor) run(ctx context.Context, stop context.CancelFunc, updates <-chan []*stats.Data, status <-chan string) error {
+	s, err := openScreen()
+	if err != nil {
+		return err
+	}
+	defer s.close()
+
+	keys := make(chan string)
+	go readKeys(keys)
+	results := make(chan result)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		width, height := s.size()
+		s.draw(m.render(width, height, time.Now()))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case m.status = <-status:
+		case m.rows = <-updates:
+		case r := <-results:
+			m.finished(r)
+		case key := <-keys:
+			if !m.key(ctx, key, results) {
+				stop()
+				return nil
+			}
+		}
+	}
+}
+
+// readKeys passes on each key pressed until the terminal is closed
+func readKeys(keys chan<- string) {
+	chunks := make(chan []byte)
+	go func() {
+		buf := make([]byte, 16)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(chunks)
+				return
+			}
+			chunks <- slices.Clone(buf[:n])
+		}
+	}()
+	splitKeys(chunks, keys, escapeTimeout)
+}
+
+// splitKeys splits what is read from the terminal into keys.  An escape sequence, such as
+// an arrow key, can arrive split across reads, so an escape is held until the rest of the
+// sequence arrives, and is only taken as the escape key once nothing follows it for timeout
+func splitKeys(chunks <-chan []byte, keys chan<- string, timeout time.Duration) {
+	defer close(keys)
+	var pending []byte
+	var wait <-chan time.Time
+	for {
+		final := false
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				for len(pending) > 0 {
+					key, n := nextKey(pending, true)
+					keys <- key
+					pending = pending[n:]
+				}
+				return
+			}
+			pending = append(pending, chunk...)
+		case <-wait:
+			final = true
+		}
+		for len(pending) > 0 {
+			key, n := nextKey(pending, final)
+			if n == 0 {
+				break
+			}
+			keys <- key
+			pending = pending[n:]
+		}
+		wait = nil
+		if len(pending) > 0 {
+			wait = time.After(timeout)
+		}
+	}
+}
+
+// nextKey returns the first key in b and its length, or a length of zero if b holds the
+// start of an escape sequence that may yet be completed.  Once final, what there is of
+// a sequence is returned as it is
+func nextKey(b []byte, final bool) (string, int) {
+	if b[0] != '\x1b' {
+		_, n := utf8.DecodeRune(b)
+		return string(b[:n]), n
+	}
+	if len(b) == 1 {
+		if final {
+			return string(b), 1
+		}
+		return "", 0
+	}
+	if b[1] != '[' && b[1] != 'O' {
+		return string(b[:2]), 2
+	}
+	// A control sequence ends with a byte from @ to ~
+	for i := 2; i < len(b); i++ {
+		if b[i] >= 0x40 && b[i] <= 0x7e {
+			return string(b[:i+1]), i + 1
+		}
+	}
+	if final {
+		return string(b), len(b)
+	}
+	return "", 0
+}
+
+// key acts on a key press, returning false once the monitor should end
+func (m *monitor) key(ctx context.Context, key string, results chan<- result) bool {
+	m.message = ""
+	switch key {
+	case "", "q", "Q", "\x03", "\x1b":
+		return false
+	case "k", escape + "A", "\x1bOA":
+		m.move(-1)
+	case "j", escape + "B", "\x1bOB":
+		m.move(1)
+	case "r":
+		if m.sortBy == nil {
+			m.sortBy = &sortKey{column: m.cols[0], ascending: false}
+		} else {
+			m.sortBy.ascending = !m.sortBy.ascending
+		}
+	case "c":
+		m.colored = !m.colored && m.palette.available
+	case "s":
+		m.act(ctx, "start", results)
+	case "x":
+		m.act(ctx, "stop", results)
+	default:
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			m.sortColumn(int(key[0] - '1'))
+		}
+	}
+	return true
+}
+
+// sortColumn sorts by the nth column shown, reversing the order when it is already sorted by it
+func (m *monitor) sortColumn(n int) {
+	if n >= len(m.cols) {
+		return
+	}
+	if m.sortBy != nil && m.sortBy.column == m.cols[n] {
+		m.sortBy.ascending = !m.sortBy.ascending
+	} else {
+		m.sortBy = &sortKey{column: m.cols[n], ascending: true}
+	}
+}
+
+func (m *monitor) sortOrder() []*sortKey {
+	if m.sortBy == nil {
+		return m.order
+	}
+	return append([]*sortKey{m.sortBy}, m.order...)
+}
+
+func (m *monitor) move(delta int) {
+	if len(m.rows) == 0 {
+		return
+	}
+	index := max(0, m.index()+delta)
+	m.selected = m.rows[min(index, len(m.rows)-1)].Id
+}
+
+// index is the row of the selected tunnel, or the first row
+func (m *monitor) index() int {
+	for i, row := range m.rows {
+		if row.Id == m.selected {
+			return i
+		}
+	}
+	return 0
+}
+
+// act starts or stops the selected tunnel through the rest api
+func (m *monitor) act(ctx context.Context, action string, results chan<- result) {
+	if len(m.rows) == 0 {
+		return
+	}
+	id := m.rows[m.index()].Id
+	m.message = fmt.Sprintf("tunnel (%s) %s requested", id, action)
+	go func() {
+		output := &managerModels.StartTunnelOutput{}
+		err := cmd.NewClient().Do(ctx, http.MethodPatch, "/tunnels/"+url.PathEscape(id)+"/"+action, nil, output)
+		select {
+		case results <- result{id: id, status: output.Status, err: err}:
+		case <-ctx.Done():
+		}
+	}()
+}
+
+func (m *monitor) finished(r result) {
+	if r.err != nil {
+		m.message = r.err.Error()
+		return
+	}
+	if r.status == nil {
+		return
+	}
+	m.message = fmt.Sprintf("tunnel (%s) %s", r.id, r.status.Running)
+	for _, row := range m.rows {
+		if row.Id == r.id {
+			row.State = r.status.Running
+		}
+	}
+}
+
+// render lays out a frame of the dashboard
+func (m *monitor) render(width int, height int, now time.Time) []string {
+	m.palette.enabled = m.colored
+	sortRows(m.rows, m.sortOrder())
+	header, lines := layout(m.cols, m.rows, m.units, now)
+	gutter := ""
+	if !m.colored {
+		// Without colour, the selected and most recently used tunnels are marked instead
+		gutter = "  "
+	}
+
+	var frame []string
+	if !m.compressed {
+		frame = append(frame, m.palette.paint(truncate("auto-ssh monitor  "+m.status, width), m.palette.header))
+	}
+	frame = append(frame, m.palette.paint(truncate(gutter+header, width), m.palette.header))
+
+	footer := m.message
+	if footer == "" && !m.compressed {
+		footer = helpLine
+	}
+	visible := height - len(frame)
+	if footer != "" {
+		visible--
+	}
+	selected := m.index()
+	if selected < m.offset {
+		m.offset = selected
+	} else if visible > 0 && selected >= m.offset+visible {
+		m.offset = selected - visible + 1
+	}
+	m.offset = max(0, min(m.offset, len(m.rows)-visible))
+
+	latest := mru(m.rows)
+	for i := m.offset; i < len(m.rows) && i < m.offset+visible; i++ {
+		row := m.rows[i]
+		codes := []string{colorOr(row.Color, m.palette.tunnel)}
+		if row.JumpTunnel {
+			codes[0] = m.palette.jump
+		}
+		if row == latest {
+			codes[0] = colorOr(row.Highlight, m.palette.mru)
+		}
+		mark := ""
+		if !m.colored {
+			mark = "  "
+			if row == latest {
+				mark = "* "
+			}
+			if i == selected && m.selected != "" {
+				mark = "> "
+			}
+		} else if i == selected && m.selected != "" {
+			codes = append(codes, reverse)
+		}
+		frame = append(frame, m.palette.paint(truncate(mark+lines[i], width), codes...))
+	}
+	if len(m.rows) == 0 && visible > 0 {
+		frame = append(frame, truncate("no tunnels reported", width))
+	}
+	if footer != "" {
+		for len(frame) < height-1 {
+			frame = append(frame, "")
+		}
+		frame = append(frame, truncate(footer, width))
+	}
+	return frame
+}
+
+// colorOr is the sgr code of a tunnel's own colour, or fallback when it has none
+func colorOr(name string, fallback string) string {
+	if code, ok := colorCodes[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return code
+	}
+	return fallback
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/resources/engine/stats"
+)
+
+func TestReadUpdates(t *testing.T) {
+	var stream bytes.Buffer
+	for _, rows := range [][]*stats.Data{
+		{{Id: "a", Name: "one", In: 10}},
+		{{Id: "a", Name: "one", In: 20}, {Id: "b", Name: "two"}},
+	} {
+		bs, err := json.Marshal(rows)
+		require.NoError(t, err)
+		stream.Write(bs)
+		stream.Write(make([]byte, 256-len(bs)%256))
+	}
+
+	var updates [][]*stats.Data
+	err := readUpdates(&stream, func(rows []*stats.Data) {
+		updates = append(updates, rows)
+	})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Len(t, updates, 2)
+	assert.Equal(t, int64(10), updates[0][0].In)
+	require.Len(t, updates[1], 2)
+	assert.Equal(t, "two", updates[1][1].Name)
+
+	err = readUpdates(bytes.NewBufferString("not json\x00"), func([]*stats.Data) {})
+	assert.ErrorContains(t, err, "unreadable stats")
+}
+
+func TestFeedStops(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	// Nothing reads the status messages, which must not hold feed up once the context ends
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		feed(ctx, ln.Addr().String(), make(chan []*stats.Data), make(chan string))
+		close(done)
+	}()
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("feed did not stop")
+	}
+}
+
+func TestSortRows(t *testing.T) {
+	v := config.NewValidations()
+	rows := []*stats.Data{
+		{Id: "b", In: 5},
+		{Id: "c", In: 100, JumpTunnel: true},
+		{Id: "a", In: 5},
+		{Id: "d", In: 20},
+	}
+	keys := sortKeys(&v, []*config.SortOrder{{Metric: "Group"}, {Metric: "rcvd"}, {Metric: "Id", Ascending: true}})
+	require.Len(t, keys, 3)
+	sortRows(rows, keys)
+	assert.Equal(t, []string{"c", "d", "a", "b"}, ids(rows))
+
+	keys = sortKeys(&v, []*config.SortOrder{{Metric: "Rcvd", Ascending: true}, {Metric: "missing"}})
+	require.Len(t, keys, 1)
+	assert.True(t, v.HasValidations())
+	sortRows(rows, keys)
+	assert.Equal(t, []string{"a", "b", "d", "c"}, ids(rows))
+}
+
+func TestColumns(t *testing.T) {
+	v := config.NewValidations()
+	cols := columns(&v, []string{"id", "Rcvd", "Group", "Bogus"})
+	require.Len(t, cols, 2)
+	assert.Equal(t, "Id", cols[0].title)
+	assert.Equal(t, "Rcvd", cols[1].title)
+	assert.Len(t, v.Validations(), 2)
+
+	now := time.Now()
+	rows := []*stats.Data{{Id: "a", In: 2048, LastUpdate: now.Add(-90 * time.Second)}, {Id: "bb"}}
+	header, lines := layout(columns(&v, []string{"Id", "Rcvd", "Jump", "Last"}), rows, "k", now)
+	assert.Equal(t, "Id Rcvd Jump Last", header)
+	assert.Equal(t, []string{" a 2.0k      1m", "bb 0.0k      -"}, lines)
+	assert.Equal(t, rows[0], mru(rows))
+}
+
+func TestByteCount(t *testing.T) {
+	assert.Equal(t, "1536", byteCount(1536, ""))
+	assert.Equal(t, "1.5k", byteCount(1536, "k"))
+	assert.Equal(t, "0.5m", byteCount(512*1024, "M"))
+	assert.Equal(t, "512", byteCount(512, "h"))
+	assert.Equal(t, "3.0g", byteCount(3<<30, "h"))
+}
+
+func ids(rows []*stats.Data) []string {
+	var result []string
+	for _, row := range rows {
+		result = append(result, row.Id)
+	}
+	return result
+}
+
+func TestSplitKeys(t *testing.T) {
+	chunks := make(chan []byte)
+	keys := make(chan string, 16)
+	go splitKeys(chunks, keys, 20*time.Millisecond)
+
+	chunks <- []byte("\x1b")
+	chunks <- []byte("[A")
+	assert.Equal(t, "\x1b[A", <-keys, "a split arrow key is one key")
+	chunks <- []byte("jk\x1b[B")
+	assert.Equal(t, "j", <-keys)
+	assert.Equal(t, "k", <-keys)
+	assert.Equal(t, "\x1b[B", <-keys)
+	chunks <- []byte("\x1b")
+	assert.Equal(t, "\x1b", <-keys, "a lone escape is the escape key")
+	chunks <- []byte("\x1b[")
+	close(chunks)
+	assert.Equal(t, "\x1b[", <-keys)
+	_, ok := <-keys
+	assert.False(t, ok)
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package monitor
+
+import (
+	"bufio"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+const (
+	escape       = "\x1b["
+	reset        = escape + "0m"
+	reverse      = "7"
+	enterScreen  = escape + "?1049h" + escape + "?25l"
+	leaveScreen  = escape + "?25h" + escape + "?1049l"
+	home         = escape + "H"
+	clearLine    = escape + "K"
+	clearBelow   = escape + "J"
+	clearScreen  = escape + "2J"
+	defaultWidth = 80
+)
+
+var (
+	colorCodes = map[string]string{
+		"black": "30", "red": "31", "green": "32", "yellow": "33",
+		"blue": "34", "magenta": "35", "cyan": "36", "white": "37",
+		"grey": "90", "gray": "90",
+		"bright-black": "90", "bright-red": "91", "bright-green": "92", "bright-yellow": "93",
+		"bright-blue": "94", "bright-magenta": "95", "bright-cyan": "96", "bright-white": "97",
+	}
+)
+
+// palette holds the colours of the monitor, as sgr codes.  Codes of an empty palette are
+// never written, so terminals without colour see plain text
+type palette struct {
+	enabled   bool
+	available bool
+	header    string
+	tunnel    string
+	mru       string
+	jump      string
+}
+
+// newPalette resolves monitor.color.  Colour is only used when enabled in the
+// configuration, not turned off by the caller or $NO_COLOR, and the terminal has it
+func newPalette(v *config.Validations, color *config.Color, noColor bool) *palette {
+	p := &palette{available: !noColor && os.Getenv("NO_COLOR") == "" && colorTerminal()}
+	if color == nil {
+		return p
+	}
+	p.header = colorCode(v, "header", color.Header)
+	p.tunnel = colorCode(v, "tunnel", color.Tunnel)
+	p.mru = colorCode(v, "mru", color.MRU)
+	p.jump = colorCode(v, "jump-tunnel", color.Jump)
+	p.enabled = color.Enabled && p.available
+	return p
+}
+
+func colorCode(v *config.Validations, attr string, name string) string {
+	if name == "" {
+		return ""
+	}
+	code, ok := colorCodes[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		v.Warnf("monitor.color.%s (%s) ignored.  Must be a colour such as red, grey or bright-cyan", attr, name)
+	}
+	return code
+}
+
+// paint wraps s in the given sgr codes
+func (p *palette) paint(s string, codes ...string) string {
+	var used []string
+	for _, code := range codes {
+		if code != "" && (p.enabled || code == reverse) {
+			used = append(used, code)
+		}
+	}
+	if len(used) == 0 {
+		return s
+	}
+	return escape + strings.Join(used, ";") + "m" + s + reset
+}
+
+func colorTerminal() bool {
+	t := os.Getenv("TERM")
+	return t != "" && t != "dumb"
+}
+
+// interactive reports whether the monitor can take over the terminal
+func interactive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) && colorTerminal()
+}
+
+// screen draws whole frames onto the alternate screen of a terminal in raw mode
+type screen struct {
+	out   *bufio.Writer
+	state *term.State
+}
+
+func openScreen() (*screen, error) {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	s := &screen{out: bufio.NewWriter(os.Stdout), state: state}
+	_, _ = s.out.WriteString(enterScreen + clearScreen)
+	return s, s.out.Flush()
+}
+
+func (s *screen) close() {
+	_, _ = s.out.WriteString(leaveScreen)
+	_ = s.out.Flush()
+	_ = term.Restore(int(os.Stdin.Fd()), s.state)
+}
+
+func (s *screen) size() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return defaultWidth, 24
+	}
+	return width, height
+}
+
+// draw replaces the screen with lines, clearing whatever the previous frame left behind
+func (s *screen) draw(lines []string) {
+	_, _ = s.out.WriteString(home)
+	for i, line := range lines {
+		if i > 0 {
+			_, _ = s.out.WriteString("\r\n")
+		}
+		_, _ = s.out.WriteString(line + clearLine)
+	}
+	_, _ = s.out.WriteString(clearBelow)
+	_ = s.out.Flush()
+}
+
+// truncate cuts s to width characters
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	return string(runes[:max(0, width)])
+}
@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package monitor
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/resources/engine/stats"
+)
+
+const (
+	// groupMetric sorts jump tunnels apart from the others.  It is not shown as a column
+	groupMetric = "Group"
+	timeLayout  = "2006-01-02T15:04:05.000000000"
+)
+
+// column is a metric of stats.Data, shown as its title, format and sort tags describe
+type column struct {
+	title  string
+	field  int
+	format string
+	sort   string
+}
+
+// sortKey orders rows by a column, ascending or not
+type sortKey struct {
+	column    *column
+	ascending bool
+}
+
+// metrics lists every field of stats.Data with a title, in declaration order
+func metrics() []*column {
+	var cols []*column
+	t := reflect.TypeOf((*stats.Data)(nil)).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if title, ok := field.Tag.Lookup("title"); ok {
+			cols = append(cols, &column{title: title, field: i, format: field.Tag.Get("format"), sort: field.Tag.Get("sort")})
+		}
+	}
+	return cols
+}
+
+func metric(name string) (*column, bool) {
+	if strings.EqualFold(name, groupMetric) {
+		name = "Jump"
+	}
+	for _, col := range metrics() {
+		if strings.EqualFold(col.title, name) {
+			return col, true
+		}
+	}
+	return nil, false
+}
+
+func titles() []string {
+	var names []string
+	for _, col := range metrics() {
+		names = append(names, col.title)
+	}
+	return names
+}
+
+// columns resolves monitor.metrics.  All metrics are shown when none are configured
+func columns(v *config.Validations, names []string) []*column {
+	if len(names) == 0 {
+		return metrics()
+	}
+	var cols []*column
+	for _, name := range names {
+		if col, ok := metric(name); ok && !strings.EqualFold(name, groupMetric) {
+			cols = append(cols, col)
+		} else {
+			v.Warnf("monitor.metrics (%s) ignored.  Must be one of: %s", name, strings.Join(titles(), ", "))
+		}
+	}
+	return cols
+}
+
+// sortKeys resolves monitor.sortOrder
+func sortKeys(v *config.Validations, order []*config.SortOrder) []*sortKey {
+	var keys []*sortKey
+	for _, o := range order {
+		if o == nil {
+			continue
+		}
+		if col, ok := metric(o.Metric); ok {
+			keys = append(keys, &sortKey{column: col, ascending: o.Ascending})
+		} else {
+			v.Warnf("monitor.sortOrder (%s) ignored.  Must be one of: %s, %s", o.Metric, groupMetric, strings.Join(titles(), ", "))
+		}
+	}
+	return keys
+}
+
+// raw is the unformatted value of a column, written so values of the same column
+// compare as strings once padded as the sort tag describes
+func (c *column) raw(d *stats.Data) string {
+	value := reflect.ValueOf(d).Elem().Field(c.field)
+	switch v := value.Interface().(type) {
+	case bool:
+		return strconv.FormatBool(v)[:1]
+	case time.Time:
+		return v.UTC().Format(timeLayout)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// cell is the value of a column as it is shown
+func (c *column) cell(d *stats.Data, units string, now time.Time) string {
+	value := reflect.ValueOf(d).Elem().Field(c.field)
+	switch v := value.Interface().(type) {
+	case int64:
+		return byteCount(v, units)
+	case bool:
+		if v {
+			return "yes"
+		}
+		return ""
+	case time.Time:
+		return age(v, now)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sortRows orders rows by keys, using each column's sort tag to pad its values so they
+// compare as strings
+func sortRows(rows []*stats.Data, keys []*sortKey) {
+	sortValues := make(map[*stats.Data][]string, len(rows))
+	for _, key := range keys {
+		width := 0
+		for _, row := range rows {
+			width = max(width, len(key.column.raw(row)))
+		}
+		for _, row := range rows {
+			raw := key.column.raw(row)
+			padding := strings.Repeat(" ", width-len(raw))
+			sortValues[row] = append(sortValues[row], fmt.Sprintf(key.column.sort, raw, padding))
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b *stats.Data) int {
+		for i, key := range keys {
+			if n := strings.Compare(sortValues[a][i], sortValues[b][i]); n != 0 {
+				if !key.ascending {
+					return -n
+				}
+				return n
+			}
+		}
+		return 0
+	})
+}
+
+// layout formats the header and rows, aligning each column to its widest value
+func layout(cols []*column, rows []*stats.Data, units string, now time.Time) (string, []string) {
+	rowCells := cells(cols, rows, units, now)
+	widths := make([]int, len(cols))
+	for i, col := range cols {
+		widths[i] = len(col.title)
+		for _, row := range rowCells {
+			widths[i] = max(widths[i], len(row[i]))
+		}
+	}
+	line := func(values []string) string {
+		var sb strings.Builder
+		for i, col := range cols {
+			_, _ = fmt.Fprintf(&sb, fmt.Sprintf(col.format, widths[i]), values[i])
+		}
+		return strings.TrimRight(sb.String(), " ")
+	}
+	var header []string
+	for _, col := range cols {
+		header = append(header, col.title)
+	}
+	lines := make([]string, len(rows))
+	for r := range rows {
+		lines[r] = line(rowCells[r])
+	}
+	return line(header), lines
+}
+
+// cells formats the value of each column of each row
+func cells(cols []*column, rows []*stats.Data, units string, now time.Time) [][]string {
+	result := make([][]string, len(rows))
+	for r, row := range rows {
+		for _, col := range cols {
+			result[r] = append(result[r], col.cell(row, units, now))
+		}
+	}
+	return result
+}
+
+// byteCount formats a byte count in monitor.units: b for bytes, k, m or g for a fixed unit,
+// or h to pick the unit that suits the value
+func byteCount(n int64, units string) string {
+	const unitNames = "kmgt"
+	switch u := strings.ToLower(units); u {
+	case "k", "m", "g", "t":
+		return fmt.Sprintf("%.1f%s", float64(n)/float64(int64(1)<<(10*(strings.Index(unitNames, u)+1))), u)
+	case "h", "auto":
+		value, unit := float64(n), ""
+		for i := 0; value >= 1024 && i < len(unitNames); i++ {
+			value, unit = value/1024, unitNames[i:i+1]
+		}
+		if unit == "" {
+			return strconv.FormatInt(n, 10)
+		}
+		return fmt.Sprintf("%.1f%s", value, unit)
+	default:
+		return strconv.FormatInt(n, 10)
+	}
+}
+
+// age is how long ago t was, in its largest whole unit
+func age(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", max(0, int(d.Seconds())))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// mru is the tunnel used most recently, if any has been used
+func mru(rows []*stats.Data) *stats.Data {
+	var latest *stats.Data
+	for _, row := range rows {
+		if !row.LastUpdate.IsZero() && (latest == nil || row.LastUpdate.After(latest.LastUpdate)) {
+			latest = row
+		}
+	}
+	return latest
+}
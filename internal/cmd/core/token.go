@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package core
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+var (
+	tokenRole string
+	tokenTags []string
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token <name>",
+	Short: "Generates an api token",
+	Long: `Generates a random api token, printing it along with the web.tokens entry that allows it.
+Only the hash is added to the configuration, so keep the token itself somewhere safe.
+Roles are read-only, operator (start and stop tunnels) and admin (everything).  Tags limit
+the token to the hosts and tunnels carrying any of them`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := token(args[0])
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(tokenCmd)
+	tokenCmd.Flags().StringVar(&tokenRole, "role", config.RoleReadOnly, "role granted to the token: read-only, operator or admin")
+	tokenCmd.Flags().StringSliceVar(&tokenTags, "tags", nil, "limit the token to hosts and tunnels with these tags")
+}
+
+func token(name string) error {
+	if !slices.Contains(config.Roles(), tokenRole) {
+		return fmt.Errorf("role (%s) is invalid.  Must be one of: %s", tokenRole, strings.Join(config.Roles(), ", "))
+	}
+	secret, err := config.GenerateToken()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("token: %s\n\nAdd to the web section of the configuration:\n", secret)
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&struct {
+		Tokens []*config.Token `yaml:"tokens"`
+	}{[]*config.Token{{Name: name, Hash: config.HashToken(secret), Role: tokenRole, Tags: tokenTags}}}); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
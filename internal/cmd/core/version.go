@@ -20,7 +20,7 @@ var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Displays version information about the binary",
 	Run: func(cmd *cobra.Command, args []string) {
-		err := version(cmd)
+		err := version(cmd.Flag("verbose").Changed)
 		if err != nil {
 			fmt.Printf("%v\n", err)
 			os.Exit(1)
@@ -33,8 +33,33 @@ func init() {
 	flag.AddFlags(versionCmd, flag.Verbose)
 }
 
-func version(cmd *cobra.Command) error {
-	if cmd.Flag("verbose").Changed {
+// versionInfo describes the binary, for --output other than table
+type versionInfo struct {
+	Binary  string `json:"binary"`
+	Version string `json:"version"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Build   string `json:"build,omitempty"`
+	Commit  string `json:"commit,omitempty"`
+	Release string `json:"release,omitempty"`
+}
+
+func version(verbose bool) error {
+	if cmd.Formatted() {
+		info := &versionInfo{
+			Binary:  os.Args[0],
+			Version: config.Version,
+			OS:      runtime.GOOS,
+			Arch:    runtime.GOARCH,
+			Build:   config.BuildNumber,
+			Commit:  config.Commit,
+			Release: config.Release,
+		}
+		table := cmd.NewTable("BINARY", "VERSION", "OS", "ARCH", "BUILD", "COMMIT", "RELEASE")
+		table.Row(info.Binary, info.Version, info.OS, info.Arch, info.Build, info.Commit, info.Release)
+		return cmd.Print(info, table)
+	}
+	if verbose {
 		format := "%s version %s %s/%s, build %s, commit %s, built %v\n"
 		fmt.Printf(format,
 			os.Args[0],
@@ -46,7 +71,7 @@ func version(cmd *cobra.Command) error {
 			time.Now().Format(time.DateTime),
 		)
 	} else {
-		fmt.Printf("%s verison %s %s/%s\n",
+		fmt.Printf("%s version %s %s/%s\n",
 			os.Args[0],
 			config.Version,
 			runtime.GOOS,
@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/core/pidfile"
+	"us.figge.auto-ssh/internal/core/utils"
+	"us.figge.auto-ssh/internal/rest/client"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var (
+	ErrNotRunning  = errors.New("auto-ssh is not running")
+	ErrOtherServer = errors.New("api is served by another auto-ssh")
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Runs auto-ssh in the background",
+	Long: `Runs auto-ssh in the background, detached from the terminal, with the configuration ash
+would otherwise run in the foreground.  Each configuration file may have one daemon`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(daemonCmd)
+}
+
+// daemonFlags adds the flags that locate the daemon and its api: config, verbose,
+// server, token and client-cert
+func daemonFlags(c *cobra.Command) {
+	flag.AddFlags(c, flag.Config, flag.Verbose, flag.Server, flag.Token, flag.ClientCert)
+}
+
+// pidFile reads the pid of the auto-ssh running the configuration, or zero if none is.
+// auto-ssh holds its pidfile locked while it runs, so one left by an auto-ssh that has
+// ended is not mistaken for it, even once its pid is reused
+func pidFile() (int, error) {
+	if err := config.C.Daemon.CheckDir(); err != nil {
+		return 0, err
+	}
+	return pidfile.Read(config.C.Daemon.PidPath(config.FileName))
+}
+
+// checkServed confirms the api answering is that of the auto-ssh with pid, running the
+// configuration file loaded here.  Another auto-ssh may be listening on the same socket
+// or port
+func checkServed(ctx context.Context, pid int) error {
+	output := &managerModels.GetConfigOutput{}
+	if err := cmd.NewClient().Do(ctx, http.MethodGet, "/config", nil, output); err != nil {
+		return err
+	}
+	if output.Pid != pid || output.File != config.FileName {
+		return fmt.Errorf("%w (pid %d) running %s", ErrOtherServer, output.Pid, utils.DefaultString(output.File, "the defaults"))
+	}
+	return nil
+}
+
+// health describes the tunnels and hosts of the running auto-ssh, as its api reports them
+type health struct {
+	Tunnels        int `json:"tunnels"`
+	TunnelsStarted int `json:"tunnelsStarted"`
+	Hosts          int `json:"hosts"`
+	HostsConnected int `json:"hostsConnected"`
+}
+
+func checkHealth(ctx context.Context) (*health, error) {
+	c := cmd.NewClient()
+	h := &health{}
+	tunnelsInput := &managerModels.ListTunnelInput{}
+	for {
+		output := &managerModels.ListTunnelOutput{}
+		if err := c.Do(ctx, http.MethodPost, "/tunnels/list?status=true", tunnelsInput, output); err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			h.Tunnels++
+			if item.Status != nil && item.Status.Running == "Started" {
+				h.TunnelsStarted++
+			}
+		}
+		if output.More == nil {
+			break
+		}
+		tunnelsInput.More = output.More
+	}
+	hostsInput := &managerModels.ListHostInput{}
+	for {
+		output := &managerModels.ListHostOutput{}
+		if err := c.Do(ctx, http.MethodPost, "/hosts/list", hostsInput, output); err != nil {
+			return nil, err
+		}
+		for _, item := range output.Items {
+			h.Hosts++
+			if item.Running {
+				h.HostsConnected++
+			}
+		}
+		if output.More == nil {
+			break
+		}
+		hostsInput.More = output.More
+	}
+	return h, nil
+}
+
+// hasApi reports whether the configuration serves an api health can be checked through
+func hasApi() bool {
+	return config.C.Web.SocketPath() != "" || config.C.Web.Port != 0 || config.ServerFlag != "" || os.Getenv(client.ServerEnv) != ""
+}
+
+// waitForExit waits for the auto-ssh with pid to end, and so give up its pidfile,
+// reporting whether it did within timeout
+func waitForExit(pid int, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		if held, err := pidFile(); err == nil && held != pid {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
@@ -0,0 +1,43 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/core/flag"
+)
+
+var daemonRestartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Stops and starts auto-ssh running in the background",
+	Long: `Stops the auto-ssh daemon of the configuration, if it is running, and starts it again.
+Changes to web and monitor take effect, which a reload does not apply`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := daemonRestart()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonRestartCmd)
+	flag.AddFlags(daemonRestartCmd, daemonFlags)
+	daemonRestartCmd.Flags().DurationVar(&stopTimeout, "timeout", 30*time.Second, "how long to wait for auto-ssh to end")
+	daemonRestartCmd.Flags().DurationVar(&startWait, "wait", 10*time.Second, "how long to wait for the api to answer")
+}
+
+func daemonRestart() error {
+	if err := daemonStop(); err != nil {
+		return err
+	}
+	return daemonStart()
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/core/pidfile"
+)
+
+var (
+	startWait time.Duration
+)
+
+var daemonStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Starts auto-ssh in the background",
+	Long: `Starts auto-ssh in the background, holding its pid in daemon.pidFile while it runs and
+writing its output to daemon.logFile.  A daemon already running for the configuration is left alone.  Start
+waits until the api answers, or reports why auto-ssh ended.  It fails if the api does not
+answer within --wait, leaving auto-ssh running`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := daemonStart()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonStartCmd)
+	flag.AddFlags(daemonStartCmd, daemonFlags)
+	daemonStartCmd.Flags().DurationVar(&startWait, "wait", 10*time.Second, "how long to wait for the api to answer")
+}
+
+func daemonStart() error {
+	pid, err := pidFile()
+	if err != nil {
+		return err
+	}
+	if pid != 0 {
+		return fmt.Errorf("%w (pid %d)", pidfile.ErrRunning, pid)
+	}
+
+	logFile := config.C.Daemon.LogPath(config.FileName)
+	if err = os.MkdirAll(filepath.Dir(logFile), 0700); err != nil {
+		return err
+	}
+	log, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = log.Close() }()
+
+	c, err := command(log)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(log, "\n%s ash daemon starting\n", time.Now().Format(time.RFC3339))
+	if err = c.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- c.Wait() }()
+	if err = awaitStart(c.Process.Pid, exited); err != nil {
+		return fmt.Errorf("%w.  See %s", err, logFile)
+	}
+	fmt.Printf("auto-ssh started (pid %d).  Logging to %s\n", c.Process.Pid, logFile)
+	return nil
+}
+
+// command runs this binary with the configuration file the daemon was started for, or
+// with the defaults when there is none
+func command(log *os.File) (*exec.Cmd, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	var args []string
+	if config.FileName != "" {
+		args = append(args, "--config", config.FileName)
+	}
+	if config.VerboseFlag {
+		args = append(args, "--verbose")
+	}
+	c := exec.Command(exe, args...)
+	c.Stdout, c.Stderr = log, log
+	detach(c)
+	return c, nil
+}
+
+// awaitStart waits for the daemon with pid to claim its pidfile, and then for its api to
+// answer.  Without an api there is nothing to wait for beyond auto-ssh surviving its
+// start up
+func awaitStart(pid int, exited <-chan error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), startWait)
+	defer cancel()
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	err := errors.New("pidfile not claimed")
+	for {
+		select {
+		case exitErr := <-exited:
+			return ended(exitErr)
+		case <-ctx.Done():
+			return fmt.Errorf("auto-ssh (pid %d) did not start within %v: %w", pid, startWait, err)
+		case <-ticker.C:
+		}
+		if held, _ := pidFile(); held != pid {
+			continue
+		}
+		if !hasApi() {
+			select {
+			case exitErr := <-exited:
+				return ended(exitErr)
+			case <-time.After(time.Second):
+				return nil
+			}
+		}
+		check, checkCancel := context.WithTimeout(ctx, time.Second)
+		if err = checkServed(check, pid); err == nil {
+			_, err = checkHealth(check)
+		}
+		checkCancel()
+		if err == nil {
+			return nil
+		}
+	}
+}
+
+func ended(err error) error {
+	if err == nil {
+		err = errors.New("exit status 0")
+	}
+	return fmt.Errorf("auto-ssh ended during start up: %w", err)
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+)
+
+const (
+	exitNotRunning = 3
+)
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Reports whether auto-ssh is running in the background, and its health",
+	Long: `Reports whether the auto-ssh daemon of the configuration is running, and the tunnels and
+hosts its api reports.  Exits 1 when the api does not answer, and 3 when auto-ssh is not
+running`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		status, err := daemonStatus()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		if !status.Running {
+			os.Exit(exitNotRunning)
+		}
+		if status.Error != "" {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonStatusCmd)
+	flag.AddFlags(daemonStatusCmd, daemonFlags)
+}
+
+// status describes the daemon, for --output other than table
+type status struct {
+	Running bool    `json:"running"`
+	Pid     int     `json:"pid,omitempty"`
+	Config  string  `json:"config,omitempty"`
+	PidFile string  `json:"pidFile"`
+	LogFile string  `json:"logFile"`
+	Health  *health `json:"health,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+func daemonStatus() (*status, error) {
+	pid, err := pidFile()
+	if err != nil {
+		return nil, err
+	}
+	s := &status{
+		Running: pid != 0,
+		Pid:     pid,
+		Config:  config.FileName,
+		PidFile: config.C.Daemon.PidPath(config.FileName),
+		LogFile: config.C.Daemon.LogPath(config.FileName),
+	}
+	if s.Running && hasApi() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err = checkServed(ctx, pid); err == nil {
+			s.Health, err = checkHealth(ctx)
+		}
+		cancel()
+		if err != nil {
+			s.Error = err.Error()
+		}
+	}
+
+	if cmd.Formatted() {
+		table := cmd.NewTable("RUNNING", "PID", "TUNNELS", "STARTED", "HOSTS", "CONNECTED", "ERROR")
+		row := []string{strconv.FormatBool(s.Running), "", "", "", "", "", s.Error}
+		if s.Running {
+			row[1] = strconv.Itoa(s.Pid)
+		}
+		if s.Health != nil {
+			row[2], row[3] = strconv.Itoa(s.Health.Tunnels), strconv.Itoa(s.Health.TunnelsStarted)
+			row[4], row[5] = strconv.Itoa(s.Health.Hosts), strconv.Itoa(s.Health.HostsConnected)
+		}
+		table.Row(row...)
+		return s, cmd.Print(s, table)
+	}
+
+	if !s.Running {
+		fmt.Printf("%v\n", ErrNotRunning)
+		return s, nil
+	}
+	fmt.Printf("auto-ssh is running (pid %d)\n", s.Pid)
+	printField("config", config.FileName)
+	printField("pidfile", s.PidFile)
+	printField("log", s.LogFile)
+	switch {
+	case s.Error != "":
+		printField("api", s.Error)
+	case s.Health != nil:
+		printField("api", "ok")
+		printField("tunnels", fmt.Sprintf("%d of %d started", s.Health.TunnelsStarted, s.Health.Tunnels))
+		printField("hosts", fmt.Sprintf("%d of %d connected", s.Health.HostsConnected, s.Health.Hosts))
+	default:
+		printField("api", "not configured")
+	}
+	return s, nil
+}
+
+func printField(label string, value string) {
+	if value != "" {
+		fmt.Printf("  %-9s%s\n", label+":", value)
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/core/flag"
+)
+
+var (
+	stopTimeout time.Duration
+)
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stops auto-ssh running in the background",
+	Long: `Stops the auto-ssh daemon of the configuration, shutting it down as it is on SIGTERM, and
+waits for it to end`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := daemonStop()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	daemonCmd.AddCommand(daemonStopCmd)
+	flag.AddFlags(daemonStopCmd, daemonFlags)
+	daemonStopCmd.Flags().DurationVar(&stopTimeout, "timeout", 30*time.Second, "how long to wait for auto-ssh to end")
+}
+
+func daemonStop() error {
+	pid, err := pidFile()
+	if err != nil {
+		return err
+	}
+	if pid == 0 {
+		fmt.Printf("%v\n", ErrNotRunning)
+		return nil
+	}
+	return stop(pid)
+}
+
+func stop(pid int) error {
+	if err := terminate(pid); err != nil {
+		return fmt.Errorf("auto-ssh (pid %d) not stopped: %w", pid, err)
+	}
+	if !waitForExit(pid, stopTimeout) {
+		return fmt.Errorf("auto-ssh (pid %d) did not stop within %v", pid, stopTimeout)
+	}
+	fmt.Printf("auto-ssh stopped (pid %d)\n", pid)
+	return nil
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/pidfile"
+	"us.figge.auto-ssh/internal/rest/client"
+)
+
+func testDaemon(t *testing.T, socket string) {
+	runtime := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+	t.Setenv(client.ServerEnv, "")
+	config.C = config.NewConfig()
+	config.C.Web.Socket = socket
+	config.FileName = filepath.Join(runtime, "auto-ssh.yaml")
+	startWait = 5 * time.Second
+	t.Cleanup(func() { config.C, config.FileName = nil, "" })
+}
+
+func TestAwaitStartEnded(t *testing.T) {
+	for name, socket := range map[string]string{
+		"no api":  config.SocketDisabled,
+		"missing": filepath.Join(os.TempDir(), "ash-missing.sock"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			testDaemon(t, socket)
+			exited := make(chan error, 1)
+			exited <- errors.New("exit status 1")
+			err := awaitStart(os.Getpid(), exited)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "ended during start up")
+		})
+	}
+}
+
+func TestAwaitStartTimeout(t *testing.T) {
+	testDaemon(t, config.SocketDisabled)
+	startWait = 500 * time.Millisecond
+	err := awaitStart(os.Getpid(), make(chan error))
+	require.Error(t, err, "a daemon that never claims its pidfile is not started")
+	assert.Contains(t, err.Error(), "did not start within")
+}
+
+func TestAwaitStartClaimed(t *testing.T) {
+	testDaemon(t, config.SocketDisabled)
+	require.NoError(t, config.C.Daemon.CheckDir())
+	pidf, err := pidfile.Claim(config.C.Daemon.PidPath(config.FileName))
+	require.NoError(t, err)
+	defer pidf.Release()
+
+	assert.NoError(t, awaitStart(os.Getpid(), make(chan error)))
+	startWait = 500 * time.Millisecond
+	assert.Error(t, awaitStart(os.Getpid()+1, make(chan error)), "the pidfile is claimed by another auto-ssh")
+}
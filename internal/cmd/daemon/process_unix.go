@@ -0,0 +1,28 @@
+//go:build !windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the daemon in a session of its own, so it has no controlling terminal
+// and is not sent the signals of the one it was started from
+func detach(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// terminate sends SIGTERM, which auto-ssh shuts down gracefully on
+func terminate(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Signal(syscall.SIGTERM)
+}
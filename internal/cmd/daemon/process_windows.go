@@ -0,0 +1,34 @@
+//go:build windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package daemon
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const detachedProcess = 0x00000008
+
+// detach starts the daemon without a console, in a process group of its own, so it
+// outlives the console it was started from
+func detach(c *exec.Cmd) {
+	c.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP,
+		HideWindow:    true,
+	}
+}
+
+// terminate ends the daemon.  Windows has no SIGTERM to deliver to a process without a
+// console, so it is ended without the graceful shutdown
+func terminate(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
@@ -3,3 +3,40 @@
  */
 
 package hosts
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+var hostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "Manages the ssh hosts tunnels connect through",
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(hostsCmd)
+}
+
+func hostPath(id string) string {
+	return "/hosts/" + url.PathEscape(id)
+}
+
+// retrying describes how far a host's reconnect has got, or nothing when it is not retrying
+func retrying(status *config.HostStatus) string {
+	if status == nil || status.Attempts == 0 {
+		return ""
+	}
+	if status.NextRetry == nil {
+		return fmt.Sprintf("attempt %d", status.Attempts)
+	}
+	return fmt.Sprintf("attempt %d in %s", status.Attempts, max(0, time.Until(*status.NextRetry)).Round(time.Second))
+}
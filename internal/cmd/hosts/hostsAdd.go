@@ -3,3 +3,169 @@
  */
 
 package hosts
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/core/utils"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var (
+	addId         string
+	addRemote     string
+	addUsername   string
+	addIdentity   string
+	addKnownHosts string
+	addJumpHost   string
+	addAuth       []string
+	addTags       []string
+	addFile       string
+)
+
+var hostsAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Adds a host",
+	Long: `Adds a host described by the flags, or the hosts in a yaml or json file holding either a
+single host or a list of them.  Use --file - to read the file from stdin.  With --prompt, the
+name, remote, username and identity are asked for when not given`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := hostsAdd(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(hostsAddCmd)
+	flag.AddFlags(hostsAddCmd, flag.Rest, flag.Prompt)
+	hostsAddCmd.Flags().StringVar(&addId, "id", "", "host id.  Defaults to the name")
+	hostsAddCmd.Flags().StringVar(&addRemote, "remote", "", "ssh server address, host:port")
+	hostsAddCmd.Flags().StringVar(&addUsername, "username", "", "user to log in as")
+	hostsAddCmd.Flags().StringVar(&addIdentity, "identity", "", "private key file")
+	hostsAddCmd.Flags().StringVar(&addKnownHosts, "known-hosts", "", "known_hosts file the host key is verified with")
+	hostsAddCmd.Flags().StringVar(&addJumpHost, "jump-host", "", "id of the host to connect through")
+	hostsAddCmd.Flags().StringSliceVar(&addAuth, "auth", nil, "authentication methods, in the order tried")
+	hostsAddCmd.Flags().StringSliceVar(&addTags, "tags", nil, "metadata tags")
+	hostsAddCmd.Flags().StringVarP(&addFile, "file", "f", "", "yaml or json file of hosts to add")
+}
+
+func hostsAdd(args []string) error {
+	var hosts []*config.Host
+	if addFile != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("a name cannot be given with --file")
+		}
+		var err error
+		if hosts, err = readHosts(addFile); err != nil {
+			return err
+		}
+	} else {
+		host := &config.Host{
+			Id:         addId,
+			Username:   addUsername,
+			Identity:   addIdentity,
+			KnownHosts: addKnownHosts,
+			JumpHost:   addJumpHost,
+		}
+		if len(args) > 0 {
+			host.Name = args[0]
+		}
+		if config.PromptFlag {
+			promptHost(host)
+		}
+		if host.Name == "" && host.Id == "" {
+			return fmt.Errorf("a name or --id is required")
+		}
+		if addRemote != "" {
+			host.Remote = config.NewAddress(addRemote)
+		}
+		if len(addAuth) > 0 {
+			host.Auth = &config.Auth{Methods: addAuth}
+		}
+		if len(addTags) > 0 {
+			host.Metadata = &config.Metadata{Tags: addTags}
+		}
+		hosts = append(hosts, host)
+	}
+
+	c := cmd.NewClient()
+	var added []*config.Host
+	for _, host := range hosts {
+		output := &managerModels.AddHostOutput{}
+		input := &managerModels.AddHostInput{Host: *host}
+		if err := c.Do(context.Background(), http.MethodPost, "/hosts", input, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err := cmd.Print(output, nil); err != nil {
+				return err
+			}
+		} else if !cmd.Formatted() {
+			fmt.Printf("host (%s) added\n", output.Id)
+		}
+		added = append(added, &output.Host)
+	}
+	if config.RawFlag || !cmd.Formatted() {
+		return nil
+	}
+	return cmd.Print(added, hostsTable(added))
+}
+
+// promptHost asks for the fields of a host not given as arguments or flags
+func promptHost(host *config.Host) {
+	if host.Name == "" && host.Id == "" {
+		host.Name, _ = utils.Ask("name: ", false, true)
+	}
+	if addRemote == "" {
+		addRemote, _ = utils.Ask("remote (host:port): ", false, true)
+	}
+	if host.Username == "" {
+		host.Username, _ = utils.Ask("username: ", false, true)
+	}
+	if host.Identity == "" {
+		host.Identity, _ = utils.Ask("identity (blank for none): ", false, true)
+	}
+}
+
+// readHosts reads a single host, or a list of them.  Json is read as yaml
+func readHosts(filename string) ([]*config.Host, error) {
+	var bs []byte
+	var err error
+	if filename == "-" {
+		bs, err = io.ReadAll(os.Stdin)
+	} else {
+		bs, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(bs, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	var hosts []*config.Host
+	if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.SequenceNode {
+		err = doc.Decode(&hosts)
+	} else {
+		host := &config.Host{}
+		err = doc.Decode(host)
+		hosts = append(hosts, host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return hosts, nil
+}
@@ -3,3 +3,120 @@
  */
 
 package hosts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/core/utils"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+const (
+	labelWidth = 13
+)
+
+var hostsGetCmd = &cobra.Command{
+	Use:   "get <id>...",
+	Short: "Shows the configuration of hosts",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := hostsGet(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(hostsGetCmd)
+	flag.AddFlags(hostsGetCmd, flag.Rest)
+}
+
+func hostsGet(ids []string) error {
+	c := cmd.NewClient()
+	var hosts []*config.Host
+	for i, id := range ids {
+		output := &managerModels.GetHostOutput{}
+		if err := c.Do(context.Background(), http.MethodGet, hostPath(id)+"?status=true", nil, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err := cmd.Print(output, nil); err != nil {
+				return err
+			}
+			continue
+		}
+		hosts = append(hosts, &output.Host)
+		if cmd.Formatted() {
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		printHost(&output.Host)
+	}
+	if config.RawFlag || !cmd.Formatted() {
+		return nil
+	}
+	return cmd.Print(hosts, hostsTable(hosts))
+}
+
+func hostsTable(hosts []*config.Host) *cmd.Table {
+	table := cmd.NewTable("ID", "NAME", "REMOTE", "USERNAME", "IDENTITY", "KNOWN HOSTS", "JUMP HOST")
+	for _, host := range hosts {
+		table.Row(host.Id, host.Name, host.Remote.Configured(), host.Username, host.Identity, host.KnownHosts, host.JumpHost)
+	}
+	return table
+}
+
+func printHost(host *config.Host) {
+	printField("Id", host.Id)
+	printField("Name", host.Name)
+	printField("Remote", host.Remote.Configured())
+	printField("Username", host.Username)
+	printField("Identity", host.Identity)
+	printField("Known hosts", host.KnownHosts)
+	printField("Jump host", host.JumpHost)
+	if host.Auth != nil {
+		printField("Auth", strings.Join(host.Auth.Methods, ", "))
+		printField("Certificate", host.Auth.Certificate)
+	}
+	if host.KeepAlive != nil {
+		printField("Keep alive", fmt.Sprintf("every %ds, dropped after %d missed", host.KeepAlive.Interval, host.KeepAlive.CountMax))
+	}
+	if host.Retry != nil {
+		attempts := "forever"
+		if host.Retry.MaxAttempts > 0 {
+			attempts = strconv.Itoa(host.Retry.MaxAttempts) + " attempts"
+		}
+		printField("Retry", fmt.Sprintf("%gs to %gs, x%g, %s", host.Retry.InitialDelay, host.Retry.MaxDelay, host.Retry.Multiplier, attempts))
+	}
+	if host.Metadata != nil {
+		printField("Tags", strings.Join(host.Metadata.Tags, ", "))
+		printField("Color", host.Metadata.Color)
+	}
+	printField("Source", host.Source)
+	if host.Status != nil {
+		printField("Connected", strconv.FormatBool(host.Status.Connected))
+		printField("Retry", retrying(host.Status))
+		printField("Last error", host.Status.LastError)
+	}
+}
+
+// printField prints a labelled value, wrapping it beneath the value column.  Empty values are skipped
+func printField(label string, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Printf("%-*s%s\n", labelWidth, label+":", utils.Wrap(value, labelWidth))
+}
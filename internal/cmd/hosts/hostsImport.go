@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package hosts
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/core/sshconfig"
+)
+
+var (
+	importTunnels bool
+)
+
+var hostsImportCmd = &cobra.Command{
+	Use:   "import [ssh_config ...]",
+	Short: "Converts OpenSSH client configuration into auto-ssh hosts and tunnels",
+	Long: `Reads the Host blocks of one or more OpenSSH client configuration files (default ~/.ssh/config)
+and prints the equivalent auto-ssh hosts, and tunnels for their forwards, as yaml`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := hostsImport(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(hostsImportCmd)
+	flag.AddFlags(hostsImportCmd, flag.Verbose)
+	hostsImportCmd.Flags().BoolVarP(&importTunnels, "tunnels", "t", true, "import Local, Remote and Dynamic forwards as tunnels")
+}
+
+func hostsImport(filenames []string) error {
+	if len(filenames) == 0 {
+		filenames = []string{sshconfig.DefaultFile}
+	}
+	imported := &config.Configuration{}
+	v := config.NewValidations()
+	for _, filename := range filenames {
+		hosts, tunnels, err := sshconfig.Import(filename, &v)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", filename, err)
+		}
+		imported.Hosts = append(imported.Hosts, hosts...)
+		if importTunnels {
+			imported.Tunnels = append(imported.Tunnels, tunnels...)
+		}
+	}
+	_ = v.Output(nil)
+	if cmd.Formatted() {
+		return cmd.Print(imported, nil)
+	}
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(imported); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
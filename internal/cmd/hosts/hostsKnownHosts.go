@@ -0,0 +1,69 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package hosts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var hostsKnownHostsCmd = &cobra.Command{
+	Use:   "known-hosts",
+	Short: "Lists the known_hosts files the hosts verify keys with",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := hostsKnownHosts()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(hostsKnownHostsCmd)
+	flag.AddFlags(hostsKnownHostsCmd, flag.Rest)
+}
+
+func hostsKnownHosts() error {
+	c := cmd.NewClient()
+	files := []string{}
+	path := "/hosts/known-hosts"
+	for {
+		output := &managerModels.ListKnownHostsOutput{}
+		if err := c.Do(context.Background(), http.MethodGet, path, nil, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err := cmd.Print(output, nil); err != nil {
+				return err
+			}
+		}
+		for _, item := range output.Items {
+			files = append(files, item.File)
+		}
+		if output.More == nil {
+			break
+		}
+		path = "/hosts/known-hosts?more=" + url.QueryEscape(*output.More)
+	}
+	if config.RawFlag {
+		return nil
+	}
+	table := cmd.NewTable("FILE")
+	for _, file := range files {
+		table.Row(file)
+	}
+	return cmd.Print(files, table)
+}
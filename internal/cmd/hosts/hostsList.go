@@ -3,3 +3,90 @@
  */
 
 package hosts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var (
+	listFilters    []string
+	listMaxResults int
+)
+
+var hostsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the hosts and whether they are connected",
+	Long: `Lists the hosts of the running auto-ssh.  Filters are written key=<key>,values=<value>;<value>
+and may be repeated, such as --filter key=username,values=root.  Keys are id, name, tags, address,
+username, identity, knownHosts, jumpHost, valid and metadata.color`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := hostsList()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(hostsListCmd)
+	flag.AddFlags(hostsListCmd, flag.Rest)
+	hostsListCmd.Flags().StringArrayVar(&listFilters, "filter", nil, "filter the hosts listed: key=<key>,values=<value>;<value>")
+	hostsListCmd.Flags().IntVar(&listMaxResults, "max-results", 0, "hosts fetched per request.  All pages are listed")
+}
+
+func hostsList() error {
+	filters, err := cmd.ParseFilters(listFilters)
+	if err != nil {
+		return err
+	}
+	input := &managerModels.ListHostInput{
+		FiltersInput:    filters,
+		PaginationInput: managerModels.PaginationInput{MaxResults: listMaxResults},
+	}
+	c := cmd.NewClient()
+	items := []*managerModels.HostHeader{}
+	for {
+		output := &managerModels.ListHostOutput{}
+		if err = c.Do(context.Background(), http.MethodPost, "/hosts/list?status=true", input, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err = cmd.Print(output, nil); err != nil {
+				return err
+			}
+		}
+		items = append(items, output.Items...)
+		if output.More == nil {
+			break
+		}
+		input.More = output.More
+	}
+	if config.RawFlag {
+		return nil
+	}
+
+	slices.SortFunc(items, func(a, b *managerModels.HostHeader) int { return strings.Compare(a.Id, b.Id) })
+	table := cmd.NewTable("ID", "NAME", "VALID", "CONNECTED", "RETRY", "ERROR")
+	for _, item := range items {
+		retry, lastError := "", ""
+		if item.Status != nil {
+			retry, lastError = retrying(item.Status), item.Status.LastError
+		}
+		table.Row(item.Id, item.Name, strconv.FormatBool(item.Valid), strconv.FormatBool(item.Running), retry, lastError)
+	}
+	return cmd.Print(items, table)
+}
@@ -3,3 +3,81 @@
  */
 
 package hosts
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/core/utils"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var hostsRemoveCmd = &cobra.Command{
+	Use:   "remove <id>...",
+	Short: "Removes hosts",
+	Long: `Removes hosts after asking for confirmation.  A host still used by tunnels or other hosts
+is only removed with --force, which also skips the confirmation`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := hostsRemove(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	hostsCmd.AddCommand(hostsRemoveCmd)
+	flag.AddFlags(hostsRemoveCmd, flag.Rest, flag.Force)
+}
+
+func hostsRemove(ids []string) error {
+	c := cmd.NewClient()
+	var outputs []*managerModels.RemoveHostOutput
+	for _, id := range ids {
+		if answer, _ := utils.Askf("Remove host (%s)? [y/N] ", false, true, id); !strings.HasPrefix(strings.ToLower(answer), "y") {
+			fmt.Fprintf(os.Stderr, "host (%s) not removed\n", id)
+			continue
+		}
+		path := hostPath(id)
+		if config.ForcedFlag {
+			path += "?force=true"
+		}
+		output := &managerModels.RemoveHostOutput{}
+		if err := c.Do(context.Background(), http.MethodDelete, path, nil, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err := cmd.Print(output, nil); err != nil {
+				return err
+			}
+		} else if !cmd.Formatted() {
+			printRemoved(output)
+		}
+		outputs = append(outputs, output)
+	}
+	if config.RawFlag || !cmd.Formatted() {
+		return nil
+	}
+	table := cmd.NewTable("ID", "REFERENCES")
+	for _, output := range outputs {
+		table.Row(output.Id, strings.Join(output.References, " "))
+	}
+	return cmd.Print(outputs, table)
+}
+
+func printRemoved(output *managerModels.RemoveHostOutput) {
+	if len(output.References) > 0 {
+		fmt.Printf("host (%s) removed while still used by %s\n", output.Id, strings.Join(output.References, ", "))
+	} else {
+		fmt.Printf("host (%s) removed\n", output.Id)
+	}
+}
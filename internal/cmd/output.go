@@ -0,0 +1,193 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/utils"
+)
+
+const (
+	OutputTable    = "table"
+	OutputJson     = "json"
+	OutputYaml     = "yaml"
+	OutputCsv      = "csv"
+	OutputTemplate = "template"
+)
+
+var (
+	outputTemplate *template.Template
+	templateFuncs  = template.FuncMap{
+		"json": func(v any) (string, error) {
+			bs, err := json.Marshal(v)
+			return string(bs), err
+		},
+		"join": strings.Join,
+	}
+)
+
+func Outputs() []string {
+	return []string{OutputTable, OutputJson, OutputYaml, OutputCsv, OutputTemplate}
+}
+
+func initOutput() {
+	if err := initOutputE(); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// initOutputE checks --output, and parses --template so mistakes are reported before
+// anything is done.  --raw responses have no table form, so are json unless --output
+// says otherwise
+func initOutputE() error {
+	config.OutputFlag = strings.ToLower(strings.TrimSpace(config.OutputFlag))
+	if config.TemplateFlag != "" {
+		switch config.OutputFlag {
+		case "", OutputTable:
+			config.OutputFlag = OutputTemplate
+		case OutputTemplate:
+		default:
+			return fmt.Errorf("--template cannot be used with --output %s", config.OutputFlag)
+		}
+	}
+	if config.RawFlag {
+		switch config.OutputFlag {
+		case "", OutputTable:
+			config.OutputFlag = OutputJson
+		case OutputCsv:
+			return fmt.Errorf("--raw cannot be used with --output %s", config.OutputFlag)
+		}
+	}
+	switch config.OutputFlag {
+	case "":
+		config.OutputFlag = OutputTable
+	case OutputTable, OutputJson, OutputYaml, OutputCsv:
+	case OutputTemplate:
+		if config.TemplateFlag == "" {
+			return fmt.Errorf("--output template requires --template")
+		}
+		var err error
+		if outputTemplate, err = template.New("output").Funcs(templateFuncs).Parse(config.TemplateFlag); err != nil {
+			return fmt.Errorf("--template is invalid: %w", err)
+		}
+	default:
+		return fmt.Errorf("output (%s) is invalid.  Must be one of: %s", config.OutputFlag, strings.Join(Outputs(), ", "))
+	}
+	return nil
+}
+
+// Formatted reports whether --output asks for something other than a command's usual
+// table or text
+func Formatted() bool {
+	return config.OutputFlag != "" && config.OutputFlag != OutputTable
+}
+
+// Print writes data in the --output format.  The table and csv forms are written from
+// table, which is nil for data that has no tabular form
+func Print(data any, table *Table) error {
+	switch config.OutputFlag {
+	case OutputJson:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case OutputYaml:
+		return printYaml(data)
+	case OutputTemplate:
+		if err := outputTemplate.Execute(os.Stdout, data); err != nil {
+			return err
+		}
+		fmt.Println()
+		return nil
+	case OutputCsv:
+		if table == nil {
+			return fmt.Errorf("output (%s) is not supported here", config.OutputFlag)
+		}
+		return table.CSV()
+	default:
+		if table == nil {
+			return fmt.Errorf("output (%s) is not supported here", OutputTable)
+		}
+		return table.Print()
+	}
+}
+
+// printYaml writes data as yaml with the same keys, in the same order, as json.  Json is
+// yaml, so it is read back as a document and written in block style
+func printYaml(data any) error {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(bs, &doc); err != nil {
+		return err
+	}
+	blockStyle(&doc)
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// Table aligns tab separated columns, truncating rows to the width of the terminal.  The
+// cells are kept for csv output
+type Table struct {
+	headers []string
+	rows    [][]string
+}
+
+func NewTable(headers ...string) *Table {
+	return &Table{headers: headers}
+}
+
+func (t *Table) Row(values ...string) {
+	t.rows = append(t.rows, values)
+}
+
+func (t *Table) Print() error {
+	var buffer bytes.Buffer
+	writer := tabwriter.NewWriter(&buffer, 0, 4, 2, ' ', 0)
+	for _, row := range append([][]string{t.headers}, t.rows...) {
+		_, _ = fmt.Fprintln(writer, strings.Join(row, "\t"))
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n") {
+		fmt.Println(utils.TruncateLine(strings.TrimRight(line, " "), 0))
+	}
+	return nil
+}
+
+func (t *Table) CSV() error {
+	writer := csv.NewWriter(os.Stdout)
+	if err := writer.Write(t.headers); err != nil {
+		return err
+	}
+	if err := writer.WriteAll(t.rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
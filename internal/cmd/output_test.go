@@ -0,0 +1,46 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+func TestInitOutput(t *testing.T) {
+	defer func() { config.OutputFlag, config.TemplateFlag, config.RawFlag = "", "", false }()
+	tests := []struct {
+		output   string
+		template string
+		raw      bool
+		expected string
+		err      string
+	}{
+		{output: "", expected: OutputTable},
+		{output: " JSON ", expected: OutputJson},
+		{output: "table", template: "{{.Id}}", expected: OutputTemplate},
+		{output: "template", err: "requires --template"},
+		{output: "template", template: "{{.Id", err: "--template is invalid"},
+		{output: "xml", err: "output (xml) is invalid"},
+		{output: "json", template: "{{.Id}}", err: "--template cannot be used with --output json"},
+		{output: "csv", template: "{{.Id}}", err: "--template cannot be used with --output csv"},
+		{output: "", raw: true, expected: OutputJson},
+		{output: "yaml", raw: true, expected: OutputYaml},
+		{output: "csv", raw: true, err: "--raw cannot be used with --output csv"},
+	}
+	for _, test := range tests {
+		config.OutputFlag, config.TemplateFlag, config.RawFlag = test.output, test.template, test.raw
+		err := initOutputE()
+		if test.err != "" {
+			assert.ErrorContains(t, err, test.err, test.output)
+			continue
+		}
+		assert.NoError(t, err, test.output)
+		assert.Equal(t, test.expected, config.OutputFlag)
+		assert.Equal(t, test.expected != OutputTable, Formatted())
+	}
+}
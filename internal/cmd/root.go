@@ -6,11 +6,13 @@ package cmd
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
 	"syscall"
 
@@ -18,7 +20,10 @@ import (
 	"gopkg.in/yaml.v3"
 	"us.figge.auto-ssh/internal/core/config"
 	"us.figge.auto-ssh/internal/core/flag"
+	"us.figge.auto-ssh/internal/core/pidfile"
+	"us.figge.auto-ssh/internal/core/sshconfig"
 	"us.figge.auto-ssh/internal/resources/engine/host"
+	engineReload "us.figge.auto-ssh/internal/resources/engine/reload"
 	engineStats "us.figge.auto-ssh/internal/resources/engine/stats"
 	engineTunnel "us.figge.auto-ssh/internal/resources/engine/tunnel"
 	engineModels "us.figge.auto-ssh/internal/resources/models"
@@ -43,6 +48,8 @@ var RootCmd = &cobra.Command{
 	Short: "auto-ssh command line interface",
 	Long:  `A command line for establishing and managing automatic ssh tunneling`,
 	Run: func(cmd *cobra.Command, args []string) {
+		pidf := claimPidFile()
+		defer pidf.Release()
 		startEngines()
 		startServer()
 		startApplication()
@@ -57,8 +64,8 @@ func Execute() {
 }
 
 func init() {
-	cobra.OnInitialize(initContext, initConfig)
-	flag.AddFlags(RootCmd, rest.Flags, flag.Core)
+	cobra.OnInitialize(initContext, initConfig, initOutput)
+	flag.AddFlags(RootCmd, rest.Flags, flag.Core, flag.Output)
 }
 
 func initConfig() {
@@ -70,11 +77,22 @@ func initConfig() {
 func initConfigE() error {
 	// Locate the configuration file, if one was provided, or search for one
 	// in the users home directory or the current directory (current first)
-	var bs []byte
 	var err error
 	var paths []string
 
+	config.C = config.NewConfig()
 	if config.FileName != "" {
+		fi, err := os.Stat(config.FileName)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			bs, err := os.ReadFile(config.FileName)
+			if err != nil {
+				return err
+			}
+			return useConfig(config.FileName, bs)
+		}
 		paths = append(paths, config.FileName)
 	} else {
 		var pwd, home string
@@ -96,19 +114,79 @@ func initConfigE() error {
 		}
 	}
 
-	config.C = config.NewConfig()
 	for _, path := range paths {
 		for _, filename := range configFilenames {
-			config.FileName = filepath.Join(path, filename)
-			bs, err = os.ReadFile(config.FileName)
-			if err == nil && len(bs) > 0 {
-				fmt.Printf("Loading config from %s\n", config.FileName)
-				err = yaml.Unmarshal(bs, config.C)
-				return err
+			filename = filepath.Join(path, filename)
+			if bs, err := os.ReadFile(filename); err == nil && len(bs) > 0 {
+				return useConfig(filename, bs)
 			}
 		}
 	}
-	fmt.Printf("No config file found.  Setting defaults")
+	fmt.Fprintf(os.Stderr, "No config file found.  Setting defaults\n")
+	config.FileName = ""
+	return nil
+}
+
+// useConfig loads the configuration read from filename.  Its path is made absolute, so a
+// daemon started with it, and the checks made of that daemon, name the same file
+func useConfig(filename string, bs []byte) error {
+	var err error
+	if config.FileName, err = filepath.Abs(filename); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Loading config from %s\n", config.FileName)
+	config.C, err = parseConfig(bs)
+	config.Applied(config.FileName, sha256.Sum256(bs))
+	return err
+}
+
+// loadConfig reads a configuration file, as it is read again when reloaded
+func loadConfig(filename string) (*config.Configuration, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(bs)
+}
+
+func parseConfig(bs []byte) (*config.Configuration, error) {
+	c := config.NewConfig()
+	if err := yaml.Unmarshal(bs, c); err != nil {
+		return nil, err
+	}
+	if err := importSshConfig(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// importSshConfig adds the hosts and tunnels of any ssh_config files named in the
+// configuration.  Definitions in the configuration file itself take precedence
+func importSshConfig(c *config.Configuration) error {
+	v := config.NewValidations()
+	for _, filename := range c.SshConfig {
+		hosts, tunnels, err := sshconfig.Import(filename, &v)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", filename, err)
+		}
+		for _, h := range hosts {
+			if !slices.ContainsFunc(c.Hosts, func(defined *config.Host) bool {
+				return defined.Id == h.Id || defined.Name == h.Name
+			}) {
+				h.Source = filename
+				c.Hosts = append(c.Hosts, h)
+			}
+		}
+		for _, t := range tunnels {
+			if !slices.ContainsFunc(c.Tunnels, func(defined *config.Tunnel) bool {
+				return defined.Id == t.Id
+			}) {
+				t.Source = filename
+				c.Tunnels = append(c.Tunnels, t)
+			}
+		}
+	}
+	_ = v.Output(nil)
 	return nil
 }
 
@@ -123,12 +201,36 @@ func startEngines() {
 	}
 }
 func startEnginesE() error {
+	if config.PromptFlag {
+		host.PromptSecrets(config.C.Hosts)
+	}
 	hostEngine = host.NewEngine(ctx, config.C.Hosts)
 	tunnelEngine = engineTunnel.NewEngine(ctx, hostEngine, config.C.Tunnels)
 	statsEngine = engineStats.NewEngine()
 	return nil
 }
 
+// claimPidFile holds the pidfile of the configuration for as long as auto-ssh runs, so a
+// second auto-ssh with the same configuration, in the foreground or as a daemon, is refused
+func claimPidFile() *pidfile.File {
+	pidf, err := claimPidFileE()
+	if err != nil {
+		fmt.Printf("failed to start: %v\n", err)
+		os.Exit(1)
+	}
+	return pidf
+}
+func claimPidFileE() (*pidfile.File, error) {
+	if err := config.C.Daemon.CheckDir(); err != nil {
+		return nil, err
+	}
+	path := config.C.Daemon.PidPath(config.FileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return pidfile.Claim(path)
+}
+
 func startServer() {
 	if err := startServerE(); err != nil {
 		fmt.Printf("failed to start server: %v\n", err)
@@ -151,14 +253,25 @@ func startApplication() {
 	}
 	tunnelEngine.StartTunnels(ctx, statsEngine, wg)
 
+	reloadEngine := engineReload.NewEngine(ctx, config.FileName, config.C, loadConfig, hostEngine, tunnelEngine)
+	go reloadEngine.Watch()
+
 	go func() {
-		// Pressing Ctrl+C signals all threads to end. This in turn causes the below wg.Wait() to end
+		// Pressing Ctrl+C signals all threads to end. This in turn causes the below wg.Wait() to end.
+		// SIGHUP reloads the configuration file
 		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-		<-sigChan
-		fmt.Printf("\nsystem-service: received signal. Shutting down\n")
-		server.Shutdown()
-		cancel()
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+		for sig := range sigChan {
+			if sig == syscall.SIGHUP {
+				fmt.Printf("\nsystem-service: received SIGHUP. Reloading configuration\n")
+				_ = reloadEngine.Reload()
+				continue
+			}
+			fmt.Printf("\nsystem-service: received signal. Shutting down\n")
+			server.Shutdown()
+			cancel()
+			return
+		}
 	}()
 
 	wg.Wait()
@@ -3,3 +3,33 @@
  */
 
 package tunnels
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/core/flag"
+)
+
+var tunnelsRestartCmd = &cobra.Command{
+	Use:   "restart <id>...",
+	Short: "Stops and then starts tunnels",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := tunnelsRestart(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tunnelsCmd.AddCommand(tunnelsRestartCmd)
+	flag.AddFlags(tunnelsRestartCmd, flag.Rest)
+}
+
+func tunnelsRestart(ids []string) error {
+	return changeState(ids, "stop", "start")
+}
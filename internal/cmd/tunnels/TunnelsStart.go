@@ -3,3 +3,33 @@
  */
 
 package tunnels
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/core/flag"
+)
+
+var tunnelsStartCmd = &cobra.Command{
+	Use:   "start <id>...",
+	Short: "Starts tunnels",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := tunnelsStart(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tunnelsCmd.AddCommand(tunnelsStartCmd)
+	flag.AddFlags(tunnelsStartCmd, flag.Rest)
+}
+
+func tunnelsStart(ids []string) error {
+	return changeState(ids, "start")
+}
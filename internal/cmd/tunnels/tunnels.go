@@ -3,3 +3,87 @@
  */
 
 package tunnels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+var tunnelsCmd = &cobra.Command{
+	Use:   "tunnels",
+	Short: "Manages the tunnels of a running auto-ssh",
+	Run: func(cmd *cobra.Command, args []string) {
+		_ = cmd.Help()
+	},
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(tunnelsCmd)
+}
+
+func tunnelPath(id string, action string) string {
+	path := "/tunnels/" + url.PathEscape(id)
+	if action != "" {
+		path += "/" + action
+	}
+	return path
+}
+
+// tunnelStatus is the reply to starting or stopping a tunnel
+type tunnelStatus struct {
+	Id     string         `json:"id"`
+	Status *config.Status `json:"status,omitempty"`
+}
+
+// changeState applies each action, start or stop, to the tunnels in turn, reporting the
+// state each is left in
+func changeState(ids []string, actions ...string) error {
+	c := cmd.NewClient()
+	var outputs []*tunnelStatus
+	for _, id := range ids {
+		output := &tunnelStatus{}
+		for _, action := range actions {
+			output = &tunnelStatus{}
+			if err := c.Do(context.Background(), http.MethodPatch, tunnelPath(id, action), nil, output); err != nil {
+				return err
+			}
+			if config.RawFlag {
+				if err := cmd.Print(output, nil); err != nil {
+					return err
+				}
+			} else if !cmd.Formatted() {
+				printStatus(output.Id, output.Status)
+			}
+		}
+		outputs = append(outputs, output)
+	}
+	if config.RawFlag || !cmd.Formatted() {
+		return nil
+	}
+	table := cmd.NewTable("ID", "STATE", "ERROR")
+	for _, output := range outputs {
+		if output.Status == nil {
+			table.Row(output.Id, "", "")
+		} else {
+			table.Row(output.Id, output.Status.Running, output.Status.LastError)
+		}
+	}
+	return cmd.Print(outputs, table)
+}
+
+// printStatus reports the state a tunnel was left in by a start or stop
+func printStatus(id string, status *config.Status) {
+	if status == nil {
+		fmt.Printf("tunnel (%s)\n", id)
+	} else if status.LastError != "" && status.Running != "Started" {
+		fmt.Printf("tunnel (%s) %s: %s\n", id, status.Running, status.LastError)
+	} else {
+		fmt.Printf("tunnel (%s) %s\n", id, status.Running)
+	}
+}
@@ -3,3 +3,144 @@
  */
 
 package tunnels
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var (
+	addId     string
+	addType   string
+	addLocal  string
+	addRemote string
+	addHost   string
+	addTags   []string
+	addStart  bool
+	addFile   string
+)
+
+var tunnelsAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Adds a tunnel",
+	Long: `Adds a tunnel described by the flags, or the tunnels in a yaml or json file holding either
+a single tunnel or a list of them.  Use --file - to read the file from stdin`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := tunnelsAdd(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tunnelsCmd.AddCommand(tunnelsAddCmd)
+	flag.AddFlags(tunnelsAddCmd, flag.Rest)
+	tunnelsAddCmd.Flags().StringVar(&addId, "id", "", "tunnel id.  Defaults to the name")
+	tunnelsAddCmd.Flags().StringVar(&addType, "type", "", "tunnel type: local, remote or dynamic")
+	tunnelsAddCmd.Flags().StringVar(&addLocal, "local", "", "local address, host:port")
+	tunnelsAddCmd.Flags().StringVar(&addRemote, "remote", "", "remote address, host:port")
+	tunnelsAddCmd.Flags().StringVar(&addHost, "host", "", "id of the host the tunnel runs through")
+	tunnelsAddCmd.Flags().StringSliceVar(&addTags, "tags", nil, "metadata tags")
+	tunnelsAddCmd.Flags().BoolVar(&addStart, "start", false, "start the tunnel once added")
+	tunnelsAddCmd.Flags().StringVarP(&addFile, "file", "f", "", "yaml or json file of tunnels to add")
+}
+
+func tunnelsAdd(args []string) error {
+	var tunnels []*config.Tunnel
+	if addFile != "" {
+		if len(args) > 0 {
+			return fmt.Errorf("a name cannot be given with --file")
+		}
+		var err error
+		if tunnels, err = readTunnels(addFile); err != nil {
+			return err
+		}
+	} else {
+		if len(args) == 0 && addId == "" {
+			return fmt.Errorf("a name or --id is required")
+		}
+		tunnel := &config.Tunnel{Id: addId, Type: addType, Host: addHost}
+		if len(args) > 0 {
+			tunnel.Name = args[0]
+		}
+		if addLocal != "" {
+			tunnel.Local = config.NewAddress(addLocal)
+		}
+		if addRemote != "" {
+			tunnel.Remote = config.NewAddress(addRemote)
+		}
+		if len(addTags) > 0 {
+			tunnel.Metadata = &config.Metadata{Tags: addTags}
+		}
+		tunnels = append(tunnels, tunnel)
+	}
+
+	c := cmd.NewClient()
+	var outputs []*managerModels.AddTunnelOutput
+	for _, tunnel := range tunnels {
+		output := &managerModels.AddTunnelOutput{}
+		input := &managerModels.AddTunnelInput{Tunnel: *tunnel, Start: addStart}
+		if err := c.Do(context.Background(), http.MethodPost, "/tunnels", input, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err := cmd.Print(output, nil); err != nil {
+				return err
+			}
+		} else if !cmd.Formatted() {
+			fmt.Printf("tunnel (%s) added\n", output.Id)
+		}
+		outputs = append(outputs, output)
+	}
+	if config.RawFlag || !cmd.Formatted() {
+		return nil
+	}
+	table := cmd.NewTable("ID", "NAME", "TYPE", "LOCAL", "REMOTE", "HOST")
+	for _, output := range outputs {
+		table.Row(output.Id, output.Name, output.Type, output.Local.Configured(), output.Remote.Configured(), output.Host)
+	}
+	return cmd.Print(outputs, table)
+}
+
+// readTunnels reads a single tunnel, or a list of them.  Json is read as yaml
+func readTunnels(filename string) ([]*config.Tunnel, error) {
+	var bs []byte
+	var err error
+	if filename == "-" {
+		bs, err = io.ReadAll(os.Stdin)
+	} else {
+		bs, err = os.ReadFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err = yaml.Unmarshal(bs, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	var tunnels []*config.Tunnel
+	if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.SequenceNode {
+		err = doc.Decode(&tunnels)
+	} else {
+		tunnel := &config.Tunnel{}
+		err = doc.Decode(tunnel)
+		tunnels = append(tunnels, tunnel)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return tunnels, nil
+}
@@ -3,3 +3,96 @@
  */
 
 package tunnels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var (
+	listFilters    []string
+	listMaxResults int
+)
+
+var tunnelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the tunnels and their state",
+	Long: `Lists the tunnels of the running auto-ssh and the state they are in.  Filters are written
+key=<key>,values=<value>;<value> and may be repeated, such as --filter key=tags,values=prod;dev.
+Keys are id, name, tags, type, local, remote, host, valid, running and metadata.color`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := tunnelsList()
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tunnelsCmd.AddCommand(tunnelsListCmd)
+	flag.AddFlags(tunnelsListCmd, flag.Rest)
+	tunnelsListCmd.Flags().StringArrayVar(&listFilters, "filter", nil, "filter the tunnels listed: key=<key>,values=<value>;<value>")
+	tunnelsListCmd.Flags().IntVar(&listMaxResults, "max-results", 0, "tunnels fetched per request.  All pages are listed")
+}
+
+func tunnelsList() error {
+	filters, err := cmd.ParseFilters(listFilters)
+	if err != nil {
+		return err
+	}
+	input := &managerModels.ListTunnelInput{
+		FiltersInput:    filters,
+		PaginationInput: managerModels.PaginationInput{MaxResults: listMaxResults},
+	}
+	c := cmd.NewClient()
+	items := []*managerModels.TunnelHeader{}
+	for {
+		output := &managerModels.ListTunnelOutput{}
+		if err = c.Do(context.Background(), http.MethodPost, "/tunnels/list?status=true", input, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err = cmd.Print(output, nil); err != nil {
+				return err
+			}
+		}
+		items = append(items, output.Items...)
+		if output.More == nil {
+			break
+		}
+		input.More = output.More
+	}
+	if config.RawFlag {
+		return nil
+	}
+
+	slices.SortFunc(items, func(a, b *managerModels.TunnelHeader) int { return strings.Compare(a.Id, b.Id) })
+	table := cmd.NewTable("ID", "NAME", "STATE", "SINCE", "ERROR")
+	for _, item := range items {
+		state, since, lastError := "", "", ""
+		if item.Status != nil {
+			state, lastError = item.Status.Running, item.Status.LastError
+			if item.Status.Since != nil {
+				since = time.Since(*item.Status.Since).Round(time.Second).String()
+			}
+			if !item.Status.Valid {
+				state = "Invalid"
+			}
+		}
+		table.Row(item.Id, item.Name, state, since, lastError)
+	}
+	return cmd.Print(items, table)
+}
@@ -3,3 +3,61 @@
  */
 
 package tunnels
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/cmd"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/flag"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var tunnelsRemoveCmd = &cobra.Command{
+	Use:   "remove <id>...",
+	Short: "Removes tunnels, stopping them first",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := tunnelsRemove(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tunnelsCmd.AddCommand(tunnelsRemoveCmd)
+	flag.AddFlags(tunnelsRemoveCmd, flag.Rest)
+}
+
+func tunnelsRemove(ids []string) error {
+	c := cmd.NewClient()
+	var outputs []*managerModels.RemoveTunnelOutput
+	for _, id := range ids {
+		output := &managerModels.RemoveTunnelOutput{}
+		if err := c.Do(context.Background(), http.MethodDelete, tunnelPath(id, ""), nil, output); err != nil {
+			return err
+		}
+		if config.RawFlag {
+			if err := cmd.Print(output, nil); err != nil {
+				return err
+			}
+		} else if !cmd.Formatted() {
+			fmt.Printf("tunnel (%s) removed\n", output.Id)
+		}
+		outputs = append(outputs, output)
+	}
+	if config.RawFlag || !cmd.Formatted() {
+		return nil
+	}
+	table := cmd.NewTable("ID")
+	for _, output := range outputs {
+		table.Row(output.Id)
+	}
+	return cmd.Print(outputs, table)
+}
@@ -3,3 +3,33 @@
  */
 
 package tunnels
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"us.figge.auto-ssh/internal/core/flag"
+)
+
+var tunnelsStopCmd = &cobra.Command{
+	Use:   "stop <id>...",
+	Short: "Stops tunnels",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := tunnelsStop(args)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	tunnelsCmd.AddCommand(tunnelsStopCmd)
+	flag.AddFlags(tunnelsStopCmd, flag.Rest)
+}
+
+func tunnelsStop(ids []string) error {
+	return changeState(ids, "stop")
+}
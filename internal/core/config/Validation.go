@@ -5,7 +5,9 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
 type Validations struct {
@@ -63,6 +65,21 @@ func (v *Validations) Output(returnErr error) error {
 	return err
 }
 
+// Err joins the error messages onto base, for reporting validation failures to a caller
+// rather than the console
+func (v *Validations) Err(base error) error {
+	if !v.hasErrors {
+		return nil
+	}
+	var messages []string
+	for _, entry := range v.entries {
+		if entry.isError {
+			messages = append(messages, strings.TrimPrefix(entry.message, "  Error - "))
+		}
+	}
+	return fmt.Errorf("%w: %w", base, errors.New(strings.Join(messages, "; ")))
+}
+
 func (ve *ValidationEntry) IsError() bool {
 	return ve.isError
 }
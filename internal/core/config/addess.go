@@ -12,8 +12,11 @@ import (
 	"strings"
 )
 
+// Address is a host and port.  Validation resolves the address, but it is marshalled as
+// configured, so saving a configuration does not replace names with what they resolved to
 type Address struct {
 	valid             bool
+	configured        string
 	address           string
 	port              int
 	resolvedAddresses *net.IPAddr
@@ -21,7 +24,8 @@ type Address struct {
 
 func NewAddress(address string) *Address {
 	return &Address{
-		address: address,
+		configured: address,
+		address:    address,
 	}
 }
 
@@ -84,16 +88,40 @@ func (a *Address) Validate(group string, name string, attr string, remote bool,
 }
 
 func (a *Address) UnmarshalJSON(data []byte) error {
-	a.address = strings.TrimSpace(string(data))
+	var address string
+	if err := json.Unmarshal(data, &address); err != nil {
+		return err
+	}
+	a.address = strings.TrimSpace(address)
+	a.configured = a.address
 	return nil
 }
 
 func (a *Address) MarshalJSON() ([]byte, error) {
-	return json.Marshal(a.address)
+	return json.Marshal(a.Configured())
 }
 
 func (a *Address) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	return unmarshal(&a.address)
+	if err := unmarshal(&a.address); err != nil {
+		return err
+	}
+	a.configured = a.address
+	return nil
+}
+
+func (a *Address) MarshalYAML() (interface{}, error) {
+	return a.Configured(), nil
+}
+
+// Configured is the address as it was given, before validation resolved it
+func (a *Address) Configured() string {
+	if a == nil {
+		return ""
+	}
+	if a.configured == "" {
+		return a.address
+	}
+	return a.configured
 }
 
 func (a *Address) IsBlank() bool {
@@ -108,4 +136,9 @@ func (a *Address) Port() int {
 	return a.port
 }
 
-func (a *Address) String() string { return a.address }
+func (a *Address) String() string {
+	if a == nil {
+		return ""
+	}
+	return a.address
+}
@@ -4,10 +4,22 @@
 
 package config
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
 const (
 	Undefined = "<default>"
 )
 
+const ( // Tunnel types
+	TunnelLocal   = "local"
+	TunnelRemote  = "remote"
+	TunnelDynamic = "dynamic"
+)
+
 var ( // Build values
 	Commit      string
 	Version     string
@@ -16,47 +28,170 @@ var ( // Build values
 )
 
 var ( // Argument flags
-	FileName    string
-	C           *Configuration
-	VerboseFlag bool
-	ForcedFlag  bool
-	PromptFlag  bool
-	CurlFlag    bool
-	RawFlag     bool
+	FileName       string
+	C              *Configuration
+	VerboseFlag    bool
+	ForcedFlag     bool
+	PromptFlag     bool
+	CurlFlag       bool
+	RawFlag        bool
+	TokenFlag      string
+	ServerFlag     string
+	ClientCertFlag string
+	ClientKeyFlag  string
+	CACertFlag     string
+	OutputFlag     string
+	TemplateFlag   string
 )
 
 type Configuration struct {
-	Hosts   []*Host   `yaml:"hosts,omitempty" json:"hosts,omitempty"`
-	Tunnels []*Tunnel `yaml:"tunnels,omitempty" json:"tunnels,omitempty"`
-	Monitor *Monitor  `yaml:"monitor,omitempty" json:"monitor,omitempty"`
-	Web     *Web      `yaml:"web,omitempty" json:"web,omitempty"`
+	SshConfig []string  `yaml:"sshConfig,omitempty" json:"sshConfig,omitempty"`
+	Hosts     []*Host   `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	Tunnels   []*Tunnel `yaml:"tunnels,omitempty" json:"tunnels,omitempty"`
+	Monitor   *Monitor  `yaml:"monitor,omitempty" json:"monitor,omitempty"`
+	Web       *Web      `yaml:"web,omitempty" json:"web,omitempty"`
+	Daemon    *Daemon   `yaml:"daemon,omitempty" json:"daemon,omitempty"`
 }
 
 type Host struct {
-	Id         string    `yaml:"id" json:"id"`
-	Name       string    `yaml:"name" json:"name"`
-	Remote     *Address  `yaml:"remote" json:"remove"`
-	Username   string    `yaml:"username" json:"username"`
-	Passphrase string    `yaml:"passphrase,omitempty"  json:"passphrase,omitempty"`
-	Identity   string    `yaml:"identity" json:"identity"`
-	KnownHosts string    `yaml:"knownHosts" json:"knownHosts"`
-	JumpHost   string    `yaml:"jumpHost" json:"jumpHost"`
-	Metadata   *Metadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Id         string      `yaml:"id" json:"id"`
+	Name       string      `yaml:"name" json:"name"`
+	Remote     *Address    `yaml:"remote" json:"remote"`
+	Username   string      `yaml:"username" json:"username"`
+	Passphrase string      `yaml:"passphrase,omitempty"  json:"passphrase,omitempty"`
+	Identity   string      `yaml:"identity" json:"identity"`
+	KnownHosts string      `yaml:"knownHosts" json:"knownHosts"`
+	JumpHost   string      `yaml:"jumpHost" json:"jumpHost"`
+	Auth       *Auth       `yaml:"auth,omitempty" json:"auth,omitempty"`
+	KeepAlive  *KeepAlive  `yaml:"keepAlive,omitempty" json:"keepAlive,omitempty"`
+	Retry      *Retry      `yaml:"retry,omitempty" json:"retry,omitempty"`
+	Metadata   *Metadata   `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Status     *HostStatus `yaml:"-" json:"status,omitempty"`
+	Source     string      `yaml:"-" json:"source,omitempty"`
+}
+
+// UnmarshalJSON reads a host, still accepting remove, which the remote address was once
+// misnamed as in json, so older api clients keep working
+func (h *Host) UnmarshalJSON(data []byte) error {
+	type plain Host
+	in := struct {
+		*plain
+		Remove *Address `json:"remove"`
+	}{plain: (*plain)(h)}
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+	if in.Remove != nil {
+		fmt.Printf("  Warn  - host (%s) remove is deprecated, use remote\n", h.Name)
+		if h.Remote == nil {
+			h.Remote = in.Remove
+		}
+	}
+	return nil
+}
+
+// KeepAlive sends a keepalive@openssh.com request every Interval seconds.  The connection
+// is dropped once CountMax requests in a row go unanswered
+type KeepAlive struct {
+	Interval int `yaml:"interval,omitempty" json:"interval,omitempty"`
+	CountMax int `yaml:"countMax,omitempty" json:"countMax,omitempty"`
+}
+
+// Retry is the backoff between attempts to re-establish a connection.  Delays are in
+// seconds, Jitter is the fraction each delay is randomly spread by, and a MaxAttempts
+// of zero retries forever
+type Retry struct {
+	InitialDelay float64  `yaml:"initialDelay,omitempty" json:"initialDelay,omitempty"`
+	MaxDelay     float64  `yaml:"maxDelay,omitempty" json:"maxDelay,omitempty"`
+	Multiplier   float64  `yaml:"multiplier,omitempty" json:"multiplier,omitempty"`
+	Jitter       *float64 `yaml:"jitter,omitempty" json:"jitter,omitempty"`
+	MaxAttempts  int      `yaml:"maxAttempts,omitempty" json:"maxAttempts,omitempty"`
+}
+
+const ( // Authentication methods
+	AuthPublicKey           = "publickey"
+	AuthPassword            = "password"
+	AuthKeyboardInteractive = "keyboard-interactive"
+)
+
+// Auth lists the methods offered to the server, in order.  Passwords are read from
+// Secret, which must reference an environment variable (env:NAME) or a file
+// (file:/path), or are prompted for once when auto-ssh starts with --prompt.
+// Certificate defaults to the identity file with a -cert.pub suffix
+type Auth struct {
+	Methods     []string `yaml:"methods,omitempty" json:"methods,omitempty"`
+	Secret      string   `yaml:"secret,omitempty" json:"secret,omitempty"`
+	Certificate string   `yaml:"certificate,omitempty" json:"certificate,omitempty"`
+	Agent       *Agent   `yaml:"agent,omitempty" json:"agent,omitempty"`
+}
+
+// Agent selects keys from a running ssh-agent.  When no fingerprints or comments are
+// listed, every key held by the agent is offered
+type Agent struct {
+	Socket       string   `yaml:"socket,omitempty" json:"socket,omitempty"`
+	Fingerprints []string `yaml:"fingerprints,omitempty" json:"fingerprints,omitempty"`
+	Comments     []string `yaml:"comments,omitempty" json:"comments,omitempty"`
 }
 
 type Tunnel struct {
 	Id       string    `yaml:"id" json:"id"`
 	Name     string    `yaml:"name" json:"name"`
+	Type     string    `yaml:"type,omitempty" json:"type,omitempty"`
 	Local    *Address  `yaml:"local" json:"local"`
 	Remote   *Address  `yaml:"remote" json:"remote"`
 	Host     string    `yaml:"host,omitempty" json:"host,omitempty"`
+	Socks    *Socks    `yaml:"socks,omitempty" json:"socks,omitempty"`
+	Retry    *Retry    `yaml:"retry,omitempty" json:"retry,omitempty"`
 	Metadata *Metadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
-	Status   *Status   `yaml:"status,omitempty" json:"status,omitempty"`
+	Status   *Status   `yaml:"-" json:"status,omitempty"`
+	Source   string    `yaml:"-" json:"source,omitempty"`
+}
+
+// Masked stands in for a secret the api does not return
+const Masked = "********"
+
+type Socks struct {
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+}
+
+// Masked is a copy of the socks authentication with its password masked
+func (s *Socks) Masked() *Socks {
+	if s == nil {
+		return nil
+	}
+	masked := *s
+	if masked.Password != "" {
+		masked.Password = Masked
+	}
+	return &masked
 }
 
 type Status struct {
-	Valid   bool   `json:"valid"`
-	Running string `json:"running"`
+	Valid       bool          `json:"valid"`
+	Running     string        `json:"running"`
+	Since       *time.Time    `json:"since,omitempty"`
+	LastError   string        `json:"lastError,omitempty"`
+	Attempts    int           `json:"attempts,omitempty"`
+	NextRetry   *time.Time    `json:"nextRetry,omitempty"`
+	Transitions []*Transition `json:"transitions,omitempty"`
+}
+
+// HostStatus is the connection state of a host.  While the host is being reconnected,
+// Attempts and NextRetry show how far the backoff has got
+type HostStatus struct {
+	Connected bool       `json:"connected"`
+	LastError string     `json:"lastError,omitempty"`
+	Attempts  int        `json:"attempts,omitempty"`
+	NextRetry *time.Time `json:"nextRetry,omitempty"`
+}
+
+// Transition records a tunnel moving between running states, and the error that caused it
+type Transition struct {
+	From  string    `json:"from"`
+	To    string    `json:"to"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
 }
 
 type Metadata struct {
@@ -88,11 +223,24 @@ type SortOrder struct {
 }
 
 type Web struct {
-	Address         string `yaml:"address" json:"address"`
-	Port            int16  `yaml:"port,omitempty" json:"port,omitempty"`
-	CertificateFile string `yaml:"certificateFile,omitempty" json:"certificateFile,omitempty"`
-	CertificateKey  string `yaml:"certificateKey,omitempty" json:"certificateKey,omitempty"`
-	KeyPassphrase   string `yaml:"keyPassphrase,omitempty" json:"keyPassphrase,omitempty"`
+	Address         string        `yaml:"address" json:"address"`
+	Port            int16         `yaml:"port,omitempty" json:"port,omitempty"`
+	Socket          string        `yaml:"socket,omitempty" json:"socket,omitempty"`
+	CertificateFile string        `yaml:"certificateFile,omitempty" json:"certificateFile,omitempty"`
+	CertificateKey  string        `yaml:"certificateKey,omitempty" json:"certificateKey,omitempty"`
+	KeyPassphrase   string        `yaml:"keyPassphrase,omitempty" json:"keyPassphrase,omitempty"`
+	Tokens          []*Token      `yaml:"tokens,omitempty" json:"tokens,omitempty"`
+	ClientCA        string        `yaml:"clientCA,omitempty" json:"clientCA,omitempty"`
+	Clients         []*ClientCert `yaml:"clients,omitempty" json:"clients,omitempty"`
+	Insecure        bool          `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+}
+
+// Daemon is where ash daemon start keeps the pid and output of the auto-ssh it runs in
+// the background.  Both default to the directory of the api socket, named for the
+// configuration file
+type Daemon struct {
+	PidFile string `yaml:"pidFile,omitempty" json:"pidFile,omitempty"`
+	LogFile string `yaml:"logFile,omitempty" json:"logFile,omitempty"`
 }
 
 func NewConfig() *Configuration {
@@ -102,7 +250,7 @@ func NewConfig() *Configuration {
 		Monitor: &Monitor{
 			StatsPort:  2663,
 			Compressed: false,
-			Metrics:    []string{"Id", "Name", "Port", "Rcvd", "Sent", "Open", "Jump", "Last"},
+			Metrics:    []string{"Id", "Name", "Port", "State", "Rcvd", "Sent", "Open", "Jump", "Last"},
 			SortOrder: []*SortOrder{
 				{Metric: "Group", Ascending: false},
 				{Metric: "Id", Ascending: true},
@@ -113,9 +261,6 @@ func NewConfig() *Configuration {
 	return &config
 }
 
-func (c *Configuration) WriteConfig() {
-
-}
 func (c *Configuration) Validate() Validations {
 	return NewValidations()
 }
@@ -128,6 +273,9 @@ func (w *Web) Merge(in *Web) *Web {
 	if out.Address == "" {
 		out.Address = in.Address
 	}
+	if out.Socket == "" {
+		out.Socket = in.Socket
+	}
 	if out.CertificateFile == "" {
 		out.CertificateFile = in.CertificateFile
 	}
@@ -137,5 +285,15 @@ func (w *Web) Merge(in *Web) *Web {
 	if out.KeyPassphrase == "" {
 		out.KeyPassphrase = in.KeyPassphrase
 	}
+	if out.Tokens == nil {
+		out.Tokens = in.Tokens
+	}
+	if out.ClientCA == "" {
+		out.ClientCA = in.ClientCA
+	}
+	if out.Clients == nil {
+		out.Clients = in.Clients
+	}
+	out.Insecure = out.Insecure || in.Insecure
 	return &out
 }
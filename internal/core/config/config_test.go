@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostJson(t *testing.T) {
+	h := &Host{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "bastion", "remote": "10.0.0.1:22", "username": "admin"}`), h))
+	assert.Equal(t, "10.0.0.1:22", h.Remote.Configured())
+	assert.Equal(t, "admin", h.Username)
+
+	h = &Host{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "bastion", "remove": "10.0.0.2:22"}`), h))
+	require.NotNil(t, h.Remote, "the deprecated key is still accepted")
+	assert.Equal(t, "10.0.0.2:22", h.Remote.Configured())
+
+	h = &Host{}
+	require.NoError(t, json.Unmarshal([]byte(`{"name": "bastion", "remote": "10.0.0.1:22", "remove": "10.0.0.2:22"}`), h))
+	assert.Equal(t, "10.0.0.1:22", h.Remote.Configured())
+
+	bs, err := json.Marshal(h)
+	require.NoError(t, err)
+	assert.Contains(t, string(bs), `"remote":"10.0.0.1:22"`)
+	assert.NotContains(t, string(bs), `"remove"`)
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+)
+
+// PidPath is the pidfile of the daemon running filename
+func (d *Daemon) PidPath(filename string) string {
+	if d != nil && d.PidFile != "" {
+		return d.PidFile
+	}
+	return daemonFile(filename, ".pid")
+}
+
+// LogPath is the file the daemon running filename writes its output to
+func (d *Daemon) LogPath(filename string) string {
+	if d != nil && d.LogFile != "" {
+		return d.LogFile
+	}
+	return daemonFile(filename, ".log")
+}
+
+// CheckDir checks the directory the pid and log files default to, beside the default
+// socket, is one of the user's own, creating it if missing
+func (d *Daemon) CheckDir() error {
+	if d != nil && d.PidFile != "" && d.LogFile != "" {
+		return nil
+	}
+	_, err := SocketDir()
+	return err
+}
+
+// daemonFile names a file for the configuration file, so each configuration can have a
+// daemon of its own
+func daemonFile(filename string, ext string) string {
+	name := "ash"
+	if filename != "" {
+		if abs, err := filepath.Abs(filename); err == nil {
+			filename = abs
+		}
+		sum := sha256.Sum256([]byte(filename))
+		name += "-" + hex.EncodeToString(sum[:4])
+	}
+	return filepath.Join(filepath.Dir(DefaultSocket()), name+ext)
+}
@@ -0,0 +1,34 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemonPaths(t *testing.T) {
+	runtime := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+	dir := filepath.Join(runtime, "auto-ssh")
+
+	var d *Daemon
+	assert.Equal(t, filepath.Join(dir, "ash.pid"), d.PidPath(""))
+	assert.Equal(t, filepath.Join(dir, "ash.log"), d.LogPath(""))
+
+	pid := d.PidPath("/home/user/.auto-ssh.yaml")
+	assert.Equal(t, dir, filepath.Dir(pid))
+	assert.True(t, strings.HasPrefix(filepath.Base(pid), "ash-"), pid)
+	assert.Equal(t, strings.TrimSuffix(pid, ".pid")+".log", d.LogPath("/home/user/.auto-ssh.yaml"))
+	assert.NotEqual(t, pid, d.PidPath("/home/user/other.yaml"), "each configuration has its own daemon")
+
+	d = &Daemon{PidFile: "/var/run/ash.pid", LogFile: "/var/log/ash.log"}
+	assert.Equal(t, "/var/run/ash.pid", d.PidPath("/home/user/.auto-ssh.yaml"))
+	assert.Equal(t, "/var/log/ash.log", d.LogPath("/home/user/.auto-ssh.yaml"))
+	assert.NoError(t, d.CheckDir())
+}
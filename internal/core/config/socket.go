@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	SocketDisabled = "none"
+	socketName     = "ash.sock"
+)
+
+var (
+	ErrSocketDir = errors.New("socket directory is not private")
+)
+
+// DefaultSocket is where the api socket is created when web.socket is not set.  It is in
+// the user's runtime directory, or else a directory of their own under the temp directory
+func DefaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "auto-ssh", socketName)
+	}
+	dir := "auto-ssh"
+	if uid := os.Getuid(); uid >= 0 {
+		dir = fmt.Sprintf("auto-ssh-%d", uid)
+	}
+	return filepath.Join(os.TempDir(), dir, socketName)
+}
+
+// SocketPath is the unix socket the api is served on, or empty when web.socket is none
+func (w *Web) SocketPath() string {
+	if w == nil || w.Socket == "" {
+		return DefaultSocket()
+	}
+	if strings.EqualFold(w.Socket, SocketDisabled) {
+		return ""
+	}
+	return w.Socket
+}
+
+// IsSocket reports whether path exists and is a unix socket
+func IsSocket(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode()&os.ModeSocket != 0
+}
+
+// SocketDir creates the directory of the default socket if it is missing, and checks it
+// is a directory only the user can use.  Whoever creates it first could otherwise serve
+// the socket the user's commands connect to, or replace the pidfile kept beside it
+func SocketDir() (string, error) {
+	dir := filepath.Dir(DefaultSocket())
+	return dir, PrivateDir(dir)
+}
+
+// PrivateDir creates dir if it is missing, and checks it is a directory only the user can
+// use.  A socket is only as private as the directory it is in
+func PrivateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return privateDir(dir)
+}
+
+// TrustedSocket checks a socket before it is connected to.  A socket is only trusted in a
+// directory of the user's own, as anyone able to create it could otherwise be sent the
+// user's token
+func TrustedSocket(path string) error {
+	return privateDir(filepath.Dir(path))
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// privateDir checks dir is a directory, not a link to one, owned by the user and closed
+// to everyone else
+func privateDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrSocketDir, dir)
+	}
+	if stat, ok := fi.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: %s is not owned by uid %d", ErrSocketDir, dir, os.Getuid())
+	}
+	if fi.Mode().Perm() != 0700 {
+		return fmt.Errorf("%w: %s has mode %04o, not 0700", ErrSocketDir, dir, fi.Mode().Perm())
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocketDir(t *testing.T) {
+	runtime := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtime)
+	dir, err := SocketDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(runtime, "auto-ssh"), dir)
+	assert.NoError(t, TrustedSocket(DefaultSocket()))
+
+	require.NoError(t, os.Chmod(dir, 0755))
+	_, err = SocketDir()
+	assert.ErrorIs(t, err, ErrSocketDir)
+	assert.ErrorIs(t, TrustedSocket(DefaultSocket()), ErrSocketDir)
+	assert.ErrorIs(t, TrustedSocket(filepath.Join(dir, "other.sock")), ErrSocketDir, "a configured socket is checked too")
+	assert.ErrorIs(t, PrivateDir(dir), ErrSocketDir)
+	assert.NoError(t, PrivateDir(filepath.Join(runtime, "configured")))
+
+	require.NoError(t, os.Remove(dir))
+	target := t.TempDir()
+	require.NoError(t, os.Chmod(target, 0700))
+	require.NoError(t, os.Symlink(target, dir))
+	_, err = SocketDir()
+	assert.ErrorIs(t, err, ErrSocketDir)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// privateDir checks dir is a directory.  Windows has no owner or mode bits to check, the
+// directory inheriting the access of the user's temp directory
+func privateDir(dir string) error {
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", ErrSocketDir, dir)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+const (
+	tokenHashPrefix = "sha256:"
+)
+
+const ( // Token roles, each allowed everything the one before it is
+	RoleReadOnly = "read-only"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
+var (
+	roles = []string{RoleReadOnly, RoleOperator, RoleAdmin}
+)
+
+// Token is an api token.  Only the hash of the token is configured, as produced by
+// HashToken, so the configuration file does not hold anything that grants access.
+// Role defaults to read-only.  A token with Tags only sees the hosts and tunnels carrying
+// at least one of them
+type Token struct {
+	Name string   `yaml:"name" json:"name"`
+	Hash string   `yaml:"hash" json:"hash"`
+	Role string   `yaml:"role,omitempty" json:"role,omitempty"`
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// ClientCert maps the client certificates whose common name, subject or any subject
+// alternative name matches Subject, which may contain * and ? wildcards, to a role and
+// tags as a Token would.  Role defaults to read-only
+type ClientCert struct {
+	Subject string   `yaml:"subject" json:"subject"`
+	Role    string   `yaml:"role,omitempty" json:"role,omitempty"`
+	Tags    []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// Roles lists the token roles, from least to most privileged
+func Roles() []string {
+	return slices.Clone(roles)
+}
+
+// RoleAllows reports whether role grants everything required does
+func RoleAllows(role string, required string) bool {
+	have, need := slices.Index(roles, role), slices.Index(roles, required)
+	return have != -1 && have >= need
+}
+
+// GenerateToken returns a new random token
+func GenerateToken() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// HashToken returns the hash of a token, in the form configured under web.tokens
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return tokenHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// ValidHash reports whether a configured hash has the form produced by HashToken
+func (t *Token) ValidHash() bool {
+	hash, ok := strings.CutPrefix(strings.ToLower(t.Hash), tokenHashPrefix)
+	if !ok || len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// Matches compares token against the configured hash in constant time
+func (t *Token) Matches(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(strings.ToLower(t.Hash))) == 1
+}
@@ -0,0 +1,404 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrNoConfigFile = errors.New("no configuration file was loaded")
+	ErrConfigFormat = errors.New("configuration file is not a mapping")
+	ErrConfigEdited = errors.New("configuration file was edited since it was loaded")
+)
+
+const (
+	backupLayout = "20060102-150405.000000"
+	keepBackups  = 10
+)
+
+var (
+	appliedLock sync.Mutex
+	applied     = make(map[string][sha256.Size]byte)
+)
+
+// Applied records the checksum of the content the running hosts and tunnels were loaded
+// from, so saving them does not overwrite edits made to the file since
+func Applied(filename string, checksum [sha256.Size]byte) {
+	appliedLock.Lock()
+	defer appliedLock.Unlock()
+	applied[filename] = checksum
+}
+
+// IsApplied reports whether checksum is that of the content last loaded from, or written
+// to, filename
+func IsApplied(filename string, checksum [sha256.Size]byte) bool {
+	appliedLock.Lock()
+	defer appliedLock.Unlock()
+	last, ok := applied[filename]
+	return ok && last == checksum
+}
+
+// WriteConfig saves the hosts and tunnels to filename, returning the backup taken of the
+// previous file.  They are merged into the existing file, matching entries by id, so
+// comments, ordering and every other section survive.  Json files are written as json.
+// A file edited since it was last loaded or written is left alone, as its edits would be
+// lost, until it is reloaded
+func (c *Configuration) WriteConfig(filename string) (string, error) {
+	return c.writeConfig(filename, false)
+}
+
+// OverwriteConfig saves the hosts and tunnels as WriteConfig does, even over edits made
+// to the file since it was last loaded or written.  The backup keeps the edits
+func (c *Configuration) OverwriteConfig(filename string) (string, error) {
+	return c.writeConfig(filename, true)
+}
+
+func (c *Configuration) writeConfig(filename string, overwrite bool) (string, error) {
+	if filename == "" {
+		return "", ErrNoConfigFile
+	}
+	appliedLock.Lock()
+	defer appliedLock.Unlock()
+	existing, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	if checksum, ok := applied[filename]; ok && !overwrite && checksum != sha256.Sum256(existing) {
+		return "", fmt.Errorf("%w: %s", ErrConfigEdited, filename)
+	}
+
+	var doc yaml.Node
+	if err = yaml.Unmarshal(existing, &doc); err != nil {
+		return "", fmt.Errorf("%s: %w", filename, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return "", fmt.Errorf("%w: %s", ErrConfigFormat, filename)
+	}
+	if err = mergeSection(root, "hosts", c.Hosts); err != nil {
+		return "", err
+	}
+	if err = mergeSection(root, "tunnels", c.Tunnels); err != nil {
+		return "", err
+	}
+
+	var bs []byte
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		bs, err = json.MarshalIndent(jsonNode{root}, "", "  ")
+		bs = append(bs, '\n')
+	} else {
+		var buffer bytes.Buffer
+		encoder := yaml.NewEncoder(&buffer)
+		encoder.SetIndent(2)
+		err = encoder.Encode(&doc)
+		bs = buffer.Bytes()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	backup := ""
+	if existing != nil {
+		if backup, err = backupFile(filename); err != nil {
+			return "", err
+		}
+	}
+	if err = writeFileAtomic(filename, bs); err != nil {
+		return "", err
+	}
+	applied[filename] = sha256.Sum256(bs)
+	return backup, nil
+}
+
+// mergeSection replaces a top level list with items, keeping the position and comments
+// of any item already in the file
+func mergeSection[T any](root *yaml.Node, key string, items []T) error {
+	var src yaml.Node
+	if err := src.Encode(items); err != nil {
+		return err
+	}
+	prune(&src)
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			if len(items) == 0 {
+				root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			} else {
+				mergeSequence(root.Content[i+1], &src)
+			}
+			return nil
+		}
+	}
+	if len(items) > 0 {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &src)
+	}
+	return nil
+}
+
+// mergeSequence matches items by their id, or name, dropping those no longer present and
+// appending new ones at the end.  An item sharing its id or name with one already matched
+// is a duplicate validation refused, which is kept for the user to resolve
+func mergeSequence(dst *yaml.Node, src *yaml.Node) {
+	if dst.Kind != yaml.SequenceNode {
+		*dst = *src
+		return
+	}
+	matched := make(map[*yaml.Node]bool)
+	var content []*yaml.Node
+	for _, item := range dst.Content {
+		merged, duplicate := false, false
+		for _, replacement := range src.Content {
+			if !matched[replacement] && sameItem(item, replacement) {
+				mergeNode(item, replacement)
+				matched[replacement] = true
+				merged = true
+				break
+			}
+			duplicate = duplicate || matched[replacement] && sharesKey(item, replacement)
+		}
+		if merged || duplicate {
+			content = append(content, item)
+		}
+	}
+	for _, replacement := range src.Content {
+		if !matched[replacement] {
+			content = append(content, replacement)
+		}
+	}
+	dst.Content = content
+}
+
+func sameItem(a *yaml.Node, b *yaml.Node) bool {
+	for _, key := range []string{"id", "name"} {
+		if av, bv := mappingValue(a, key), mappingValue(b, key); av != nil && bv != nil {
+			return av.Value == bv.Value
+		}
+	}
+	return false
+}
+
+// sharesKey reports whether two items have the same id, or the same name
+func sharesKey(a *yaml.Node, b *yaml.Node) bool {
+	for _, key := range []string{"id", "name"} {
+		if av, bv := mappingValue(a, key), mappingValue(b, key); av != nil && bv != nil && av.Value == bv.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// mergeNode gives dst the value of src, keeping the comments of dst and of any keys it
+// already had.  Unchanged values keep the style they were written in
+func mergeNode(dst *yaml.Node, src *yaml.Node) {
+	if dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode && dst.Value == src.Value {
+		return
+	}
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+		*dst = *src
+		dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+		return
+	}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == key.Value {
+				mergeNode(dst.Content[j+1], value)
+				content = append(content, dst.Content[j], dst.Content[j+1])
+				found = true
+				break
+			}
+		}
+		if !found {
+			content = append(content, key, value)
+		}
+	}
+	dst.Content = content
+}
+
+// prune drops the keys of empty values, which the configuration does not need written
+func prune(node *yaml.Node) {
+	for _, child := range node.Content {
+		prune(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		value := node.Content[i+1]
+		if value.Kind == yaml.ScalarNode && (value.Value == "" || value.Tag == "!!null") {
+			continue
+		}
+		content = append(content, node.Content[i], value)
+	}
+	node.Content = content
+}
+
+// backupFile copies filename aside, named for the time it was replaced, and prunes all
+// but the newest keepBackups copies
+func backupFile(filename string) (string, error) {
+	in, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = in.Close() }()
+	fi, err := in.Stat()
+	if err != nil {
+		return "", err
+	}
+	stamp := time.Now().Format(backupLayout)
+	backup := fmt.Sprintf("%s.%s.bak", filename, stamp)
+	out, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	for i := 1; os.IsExist(err); i++ {
+		backup = fmt.Sprintf("%s.%s_%d.bak", filename, stamp, i)
+		out, err = os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return "", err
+	}
+	if err = out.Close(); err != nil {
+		return "", err
+	}
+	pruneBackups(filename)
+	return backup, nil
+}
+
+// pruneBackups removes the oldest backups of filename beyond keepBackups.  Their names
+// sort in the order they were taken
+func pruneBackups(filename string) {
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		return
+	}
+	prefix := filepath.Base(filename) + "."
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".bak")
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".bak") || len(stamp) < len(backupLayout) {
+			continue
+		}
+		if _, err = time.Parse(backupLayout, stamp[:len(backupLayout)]); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	for len(backups) > keepBackups {
+		_ = os.Remove(filepath.Join(filepath.Dir(filename), backups[0]))
+		backups = backups[1:]
+	}
+}
+
+// writeFileAtomic writes to a temporary file beside filename and renames it into place, so
+// readers never see a partly written configuration
+func writeFileAtomic(filename string, bs []byte) error {
+	mode := os.FileMode(0600)
+	if fi, err := os.Stat(filename); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	if _, err = f.Write(bs); err == nil {
+		if err = f.Chmod(mode); err == nil {
+			err = f.Sync()
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filename)
+}
+
+// jsonNode writes a yaml node as json, keeping the order of mapping keys
+type jsonNode struct {
+	*yaml.Node
+}
+
+func (n jsonNode) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	switch n.Kind {
+	case yaml.DocumentNode:
+		return jsonNode{n.Content[0]}.MarshalJSON()
+	case yaml.AliasNode:
+		return jsonNode{n.Alias}.MarshalJSON()
+	case yaml.MappingNode:
+		buffer.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			key, _ := json.Marshal(n.Content[i].Value)
+			value, err := jsonNode{n.Content[i+1]}.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(key)
+			buffer.WriteByte(':')
+			buffer.Write(value)
+		}
+		buffer.WriteByte('}')
+	case yaml.SequenceNode:
+		buffer.WriteByte('[')
+		for i, item := range n.Content {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			value, err := jsonNode{item}.MarshalJSON()
+			if err != nil {
+				return nil, err
+			}
+			buffer.Write(value)
+		}
+		buffer.WriteByte(']')
+	default:
+		var value any
+		if err := n.Decode(&value); err != nil {
+			return nil, err
+		}
+		return json.Marshal(value)
+	}
+	return buffer.Bytes(), nil
+}
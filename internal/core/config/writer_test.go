@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	testConfig = `# auto-ssh configuration
+web:
+  port: 8080 # api port
+hosts:
+  # the bastion
+  - id: bastion
+    name: bastion
+    remote: 10.0.0.1:22 # inside the vpn
+    username: admin
+  - id: old
+    name: old
+    remote: 10.0.0.2:22
+tunnels:
+  - id: db
+    name: database
+    local: 5432
+    remote: db.internal:5432
+    host: bastion
+`
+)
+
+func TestWriteConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(testConfig), 0640))
+
+	c := &Configuration{}
+	require.NoError(t, yaml.Unmarshal([]byte(testConfig), c))
+	c.Hosts[0].Username = "root"
+	c.Hosts = append(c.Hosts[:1], &Host{Id: "new", Name: "new", Remote: NewAddress("10.0.0.3:22")})
+	c.Tunnels[0].Name = "postgres"
+
+	backup, err := c.WriteConfig(filename)
+	require.NoError(t, err)
+	bs, err := os.ReadFile(backup)
+	require.NoError(t, err)
+	assert.Equal(t, testConfig, string(bs))
+
+	bs, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	written := string(bs)
+	assert.Contains(t, written, "# auto-ssh configuration")
+	assert.Contains(t, written, "port: 8080 # api port")
+	assert.Contains(t, written, "# the bastion")
+	assert.Contains(t, written, "remote: 10.0.0.1:22 # inside the vpn")
+	assert.Contains(t, written, "username: root")
+	assert.Contains(t, written, "local: 5432\n")
+	assert.Contains(t, written, "name: postgres")
+	assert.NotContains(t, written, "id: old")
+	assert.NotContains(t, written, "identity:")
+	assert.Less(t, strings.Index(written, "id: bastion"), strings.Index(written, "id: new"))
+
+	fi, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	reloaded := &Configuration{}
+	require.NoError(t, yaml.Unmarshal(bs, reloaded))
+	assert.Len(t, reloaded.Hosts, 2)
+	assert.Equal(t, "10.0.0.3:22", reloaded.Hosts[1].Remote.String())
+}
+
+func TestWriteConfigJson(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{"web": {"port": 8080}, "hosts": [{"id": "a", "name": "a"}]}`), 0600))
+
+	c := &Configuration{Hosts: []*Host{{Id: "a", Name: "renamed"}}}
+	_, err := c.WriteConfig(filename)
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	var written map[string]any
+	require.NoError(t, json.Unmarshal(bs, &written))
+	assert.Equal(t, map[string]any{"port": float64(8080)}, written["web"])
+	assert.Equal(t, "renamed", written["hosts"].([]any)[0].(map[string]any)["name"])
+	assert.NotContains(t, written, "tunnels")
+}
+
+func TestWriteConfigNoFile(t *testing.T) {
+	_, err := (&Configuration{}).WriteConfig("")
+	assert.ErrorIs(t, err, ErrNoConfigFile)
+}
+
+func TestWriteConfigBackups(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, ".auto-ssh.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte("hosts: []\n"), 0600))
+
+	seen := make(map[string]bool)
+	var backups []string
+	for i := 0; i < keepBackups+5; i++ {
+		previous, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		c := &Configuration{Hosts: []*Host{{Name: fmt.Sprintf("host%d", i)}}}
+		backup, err := c.WriteConfig(filename)
+		require.NoError(t, err)
+		assert.False(t, seen[backup], "backup %s overwritten", backup)
+		seen[backup] = true
+		backups = append(backups, backup)
+		bs, err := os.ReadFile(backup)
+		require.NoError(t, err)
+		assert.Equal(t, previous, bs)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, ".auto-ssh.yaml.*.bak"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, backups[len(backups)-keepBackups:], matches, "only the newest backups are kept")
+}
+
+func TestWriteConfigKeepsEdits(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.yaml")
+	loaded := []byte("hosts:\n  - name: a\n")
+	require.NoError(t, os.WriteFile(filename, loaded, 0600))
+	Applied(filename, sha256.Sum256(loaded))
+
+	c := &Configuration{Hosts: []*Host{{Name: "b"}}}
+	_, err := c.WriteConfig(filename)
+	require.NoError(t, err)
+	_, err = c.WriteConfig(filename)
+	require.NoError(t, err, "a file saved since it was loaded is not an edit")
+
+	edited := []byte("hosts:\n  - name: edited\n")
+	require.NoError(t, os.WriteFile(filename, edited, 0600))
+	_, err = c.WriteConfig(filename)
+	assert.ErrorIs(t, err, ErrConfigEdited)
+	bs, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, edited, bs)
+
+	Applied(filename, sha256.Sum256(edited))
+	_, err = c.WriteConfig(filename)
+	assert.NoError(t, err)
+}
+
+func TestWriteConfigKeepsDuplicates(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(`hosts:
+  - name: bastion
+    remote: 10.0.0.1:22
+  - name: bastion
+    remote: 10.0.0.9:22
+  - name: old
+    remote: 10.0.0.2:22
+`), 0600))
+
+	c := &Configuration{Hosts: []*Host{{Name: "bastion", Remote: NewAddress("10.0.0.1:2222")}}}
+	_, err := c.WriteConfig(filename)
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	written := string(bs)
+	assert.Contains(t, written, "remote: 10.0.0.1:2222")
+	assert.Contains(t, written, "remote: 10.0.0.9:22")
+	assert.NotContains(t, written, "name: old")
+}
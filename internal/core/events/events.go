@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package events
+
+import (
+	"sync"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+const ( // Event types
+	TunnelState      = "tunnel.state"
+	ConnectionOpened = "connection.opened"
+	ConnectionClosed = "connection.closed"
+	HostConnected    = "host.connected"
+	HostDisconnected = "host.disconnected"
+	ValidationError  = "validation.error"
+	ConfigReloaded   = "config.reloaded"
+	Missed           = "events.missed"
+)
+
+const (
+	subscriberBuffer = 256
+)
+
+// Event is something that happened to a tunnel, a host or the configuration.  Ids
+// increase with every event published, so a subscriber can resume after the last it saw
+type Event struct {
+	Id      uint64    `yaml:"id" json:"id"`
+	Type    string    `yaml:"type" json:"type"`
+	Time    time.Time `yaml:"time" json:"time"`
+	Tunnel  string    `yaml:"tunnel,omitempty" json:"tunnel,omitempty"`
+	Host    string    `yaml:"host,omitempty" json:"host,omitempty"`
+	Tags    []string  `yaml:"tags,omitempty" json:"tags,omitempty"`
+	From    string    `yaml:"from,omitempty" json:"from,omitempty"`
+	To      string    `yaml:"to,omitempty" json:"to,omitempty"`
+	Address string    `yaml:"address,omitempty" json:"address,omitempty"`
+	Message string    `yaml:"message,omitempty" json:"message,omitempty"`
+	Error   string    `yaml:"error,omitempty" json:"error,omitempty"`
+}
+
+// Bus hands each event published to every subscriber, and keeps the most recent so a
+// subscriber that reconnects can catch up on what it missed
+type Bus struct {
+	lock        sync.Mutex
+	lastId      uint64
+	history     []*Event
+	size        int
+	subscribers map[*Subscription]struct{}
+	now         func() time.Time
+}
+
+// Subscription receives the events published after it was made.  A subscriber that falls
+// so far behind its buffer fills is dropped, and its channel closed, rather than holding
+// up the publisher.  It can subscribe again from the last event it received
+type Subscription struct {
+	Events  <-chan *Event
+	events  chan *Event
+	History []*Event
+	Missed  bool
+}
+
+var (
+	defaultBus = NewBus()
+)
+
+type OptFn func(b *Bus)
+
+// OptionHistory sets how many events are kept for subscribers that resume
+func OptionHistory(size int) OptFn {
+	return func(b *Bus) {
+		b.size = size
+	}
+}
+
+func NewBus(options ...OptFn) *Bus {
+	bus := &Bus{
+		size:        1000,
+		subscribers: make(map[*Subscription]struct{}),
+		now:         time.Now,
+	}
+	for _, option := range options {
+		option(bus)
+	}
+	return bus
+}
+
+// Publish publishes an event on the default bus
+func Publish(event *Event) {
+	defaultBus.Publish(event)
+}
+
+// Subscribe subscribes to the default bus
+func Subscribe(after uint64) *Subscription {
+	return defaultBus.Subscribe(after)
+}
+
+// Unsubscribe ends a subscription to the default bus
+func Unsubscribe(s *Subscription) {
+	defaultBus.Unsubscribe(s)
+}
+
+// Publish numbers and timestamps event, then delivers it
+func (b *Bus) Publish(event *Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.lastId++
+	event.Id = b.lastId
+	if event.Time.IsZero() {
+		event.Time = b.now()
+	}
+	b.history = append(b.history, event)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+	for s := range b.subscribers {
+		select {
+		case s.events <- event:
+		default:
+			delete(b.subscribers, s)
+			close(s.events)
+		}
+	}
+}
+
+// Subscribe starts a subscription.  Its History holds the events kept since after, the id
+// of the last event the subscriber saw, or none when after is zero.  Missed is set when
+// events since after are no longer kept, or after is from before a restart
+func (b *Bus) Subscribe(after uint64) *Subscription {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	events := make(chan *Event, subscriberBuffer)
+	s := &Subscription{Events: events, events: events}
+	if after > 0 {
+		oldest := b.lastId - uint64(len(b.history)) + 1
+		s.Missed = after > b.lastId || after+1 < oldest
+		for _, event := range b.history {
+			if event.Id > after {
+				s.History = append(s.History, event)
+			}
+		}
+	}
+	b.subscribers[s] = struct{}{}
+	return s
+}
+
+// Unsubscribe ends a subscription, closing its channel
+func (b *Bus) Unsubscribe(s *Subscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if _, ok := b.subscribers[s]; ok {
+		delete(b.subscribers, s)
+		close(s.events)
+	}
+}
+
+// Tags returns the tags of a tunnel or host, which subscribers can filter on
+func Tags(metadata *config.Metadata) []string {
+	if metadata == nil {
+		return nil
+	}
+	return metadata.Tags
+}
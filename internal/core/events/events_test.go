@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus(OptionHistory(2))
+	live := bus.Subscribe(0)
+	for _, tunnel := range []string{"a", "b", "c", "d"} {
+		bus.Publish(&Event{Type: TunnelState, Tunnel: tunnel})
+	}
+	for i := uint64(1); i <= 4; i++ {
+		assert.Equal(t, i, (<-live.Events).Id)
+	}
+
+	resumed := bus.Subscribe(2)
+	assert.False(t, resumed.Missed)
+	require.Len(t, resumed.History, 2)
+	assert.Equal(t, "c", resumed.History[0].Tunnel)
+
+	assert.True(t, bus.Subscribe(1).Missed)
+	assert.True(t, bus.Subscribe(9).Missed)
+	assert.Empty(t, bus.Subscribe(4).History)
+
+	bus.Unsubscribe(live)
+	_, ok := <-live.Events
+	assert.False(t, ok)
+
+	for range subscriberBuffer + 1 {
+		bus.Publish(&Event{Type: TunnelState})
+	}
+	count := 0
+	for range resumed.Events {
+		count++
+	}
+	assert.Equal(t, subscriberBuffer, count)
+}
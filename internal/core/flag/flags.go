@@ -16,15 +16,29 @@ func AddFlags(cmd *cobra.Command, flags ...func(cmd *cobra.Command)) {
 }
 
 func Raw(cmd *cobra.Command) {
-	cmd.Flags().BoolVarP(&config.RawFlag, "raw", "r", false, "prints and unfiltered response")
+	cmd.Flags().BoolVarP(&config.RawFlag, "raw", "r", false, "print each response unfiltered, as json unless --output says otherwise")
 }
 
 func Curl(cmd *cobra.Command) {
 	cmd.Flags().BoolVar(&config.CurlFlag, "curl", false, "print a curl command for the rest call executed")
 }
 
+func Token(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&config.TokenFlag, "token", "", "api token for the auto-ssh server.  Defaults to $ASH_TOKEN")
+}
+
+func Server(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&config.ServerFlag, "server", "", "url of the auto-ssh server.  Defaults to $ASH_SERVER or the web configuration")
+}
+
+func ClientCert(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&config.ClientCertFlag, "client-cert", "", "client certificate for an auto-ssh server requiring one.  Defaults to $ASH_CLIENT_CERT")
+	cmd.Flags().StringVar(&config.ClientKeyFlag, "client-key", "", "private key of the client certificate.  Defaults to $ASH_CLIENT_KEY")
+	cmd.Flags().StringVar(&config.CACertFlag, "ca-cert", "", "CA certificates the auto-ssh server certificate is verified with.  Defaults to $ASH_CA_CERT")
+}
+
 func Config(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&config.FileName, "config", "c", "", "optional configuration file")
+	cmd.Flags().StringVarP(&config.FileName, "config", "c", "", "optional configuration file, or a directory to search for one")
 }
 
 func Prompt(cmd *cobra.Command) {
@@ -39,10 +53,19 @@ func Verbose(cmd *cobra.Command) {
 	cmd.Flags().BoolVarP(&config.VerboseFlag, "verbose", "v", false, "displays supplemental information")
 }
 
-// Rest adds: curl, raw raw
+// Output adds output and template to a command and every command beneath it
+func Output(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&config.OutputFlag, "output", "o", "table", "output format: table, json, yaml, csv or template")
+	cmd.PersistentFlags().StringVar(&config.TemplateFlag, "template", "", "go text/template the output is written with.  Implies --output template")
+}
+
+// Rest adds: curl, raw, server, token, client-cert, client-key, ca-cert
 func Rest(cmd *cobra.Command) {
 	Curl(cmd)
 	Raw(cmd)
+	Server(cmd)
+	Token(cmd)
+	ClientCert(cmd)
 }
 
 // Default adds: config, auth, rest
@@ -0,0 +1,27 @@
+//go:build !windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package pidfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lock takes an exclusive or shared lock on f without waiting.  It is released when f is
+// closed, or when the process ends however it ends
+func lock(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLocked
+	}
+	return err
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package pidfile
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lock takes an exclusive or shared lock on f without waiting.  It is released when f is
+// closed, or when the process ends however it ends.  The byte locked is far past the pid,
+// as Windows locks keep others from reading what they cover
+func lock(f *os.File, exclusive bool) error {
+	flags := uint32(windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if exclusive {
+		flags |= windows.LOCKFILE_EXCLUSIVE_LOCK
+	}
+	overlapped := &windows.Overlapped{OffsetHigh: 0x7fffffff}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLocked
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+// Package pidfile keeps the pid of a running auto-ssh in a file it holds locked for as
+// long as it runs.  Whether the file is locked, rather than whether a process with its
+// pid exists, says whether auto-ssh is running, so a reused pid is not mistaken for it
+package pidfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	claimAttempts = 10
+	claimInterval = 100 * time.Millisecond
+)
+
+var (
+	ErrRunning = errors.New("auto-ssh is already running")
+	errLocked  = errors.New("pidfile is locked")
+)
+
+// File is a pidfile claimed by this process
+type File struct {
+	f *os.File
+}
+
+// Claim locks the pidfile at path and writes the pid of this process to it.  It fails
+// with ErrRunning if another process holds it.  A check may hold the lock for a moment,
+// so the claim is retried briefly before giving up
+func Claim(path string) (*File, error) {
+	for attempt := 1; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		if err = lock(f, true); err != nil {
+			_ = f.Close()
+			if !errors.Is(err, errLocked) {
+				return nil, err
+			}
+			if attempt < claimAttempts {
+				time.Sleep(claimInterval)
+				continue
+			}
+			pid, _ := readPid(path)
+			return nil, fmt.Errorf("%w (pid %d): %s is held", ErrRunning, pid, path)
+		}
+		// A check finding the pidfile stale removes it, which may have happened while it
+		// was being locked.  The lock is only of use on the file at path
+		if current, err := os.Stat(path); err != nil || !sameFile(f, current) {
+			_ = f.Close()
+			continue
+		}
+		if err = write(f); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+		return &File{f: f}, nil
+	}
+}
+
+func sameFile(f *os.File, fi os.FileInfo) bool {
+	held, err := f.Stat()
+	return err == nil && os.SameFile(held, fi)
+}
+
+func write(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// Release removes the pidfile and gives up the lock
+func (p *File) Release() {
+	_ = os.Remove(p.f.Name())
+	_ = p.f.Close()
+}
+
+// Read returns the pid of the process holding the pidfile at path, or zero if none does.
+// A pidfile no process holds was left by one that did not end cleanly, and is removed
+func Read(path string) (int, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer func() { _ = f.Close() }()
+	if err = lock(f, false); err == nil {
+		_ = os.Remove(path)
+		return 0, nil
+	} else if !errors.Is(err, errLocked) {
+		return 0, err
+	}
+
+	// The holder may not have written its pid yet
+	for attempt := 1; ; attempt++ {
+		pid, err := readPid(path)
+		if err == nil || attempt == claimAttempts {
+			return pid, err
+		}
+		time.Sleep(claimInterval)
+	}
+}
+
+func readPid(path string) (int, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(bs)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("pidfile %s is held, but holds no pid", path)
+	}
+	return pid, nil
+}
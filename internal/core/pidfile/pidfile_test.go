@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package pidfile
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ash.pid")
+	pid, err := Read(path)
+	require.NoError(t, err)
+	assert.Zero(t, pid)
+
+	held, err := Claim(path)
+	require.NoError(t, err)
+	pid, err = Read(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+
+	_, err = Claim(path)
+	assert.ErrorIs(t, err, ErrRunning)
+
+	held.Release()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	pid, err = Read(path)
+	require.NoError(t, err)
+	assert.Zero(t, pid)
+}
+
+func TestStalePidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ash.pid")
+	// The pid of a running process, which does not hold the pidfile
+	require.NoError(t, os.WriteFile(path, []byte(strconv.Itoa(os.Getppid())+"\n"), 0600))
+
+	pid, err := Read(path)
+	require.NoError(t, err)
+	assert.Zero(t, pid)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "a stale pidfile is removed")
+
+	require.NoError(t, os.WriteFile(path, []byte("12345\n"), 0600))
+	held, err := Claim(path)
+	require.NoError(t, err)
+	defer held.Release()
+	bs, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(os.Getpid())+"\n", string(bs))
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package sshconfig
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/utils"
+)
+
+const (
+	DefaultFile       = "~/.ssh/config"
+	defaultKnownHosts = "~/.ssh/known_hosts"
+)
+
+var (
+	// defaultIdentities are the keys ssh tries, in order, when no IdentityFile is set
+	defaultIdentities = []string{
+		"~/.ssh/id_rsa", "~/.ssh/id_ecdsa", "~/.ssh/id_ecdsa_sk", "~/.ssh/id_ed25519", "~/.ssh/id_ed25519_sk",
+	}
+)
+
+// Import converts the hosts named in an ssh_config file into host definitions, and
+// their Local, Remote and Dynamic forwards into tunnels through them
+func Import(filename string, v *config.Validations) ([]*config.Host, []*config.Tunnel, error) {
+	filename, _ = utils.ExpandHomeE(filename)
+	c, err := Parse(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aliases := c.Aliases()
+	hosts := &importedHosts{ids: make(map[string]bool)}
+	for _, alias := range aliases {
+		hosts.ids[alias] = true
+	}
+	var tunnels []*config.Tunnel
+	for _, alias := range aliases {
+		host := c.host(alias)
+		host.JumpHost = c.jumpChain(alias, hosts)
+		hosts.add(host)
+		tunnels = append(tunnels, c.tunnels(alias, v)...)
+	}
+	return hosts.hosts, tunnels, nil
+}
+
+// importedHosts collects the hosts of the file, and those defined for the hops of jump
+// chains, each once
+type importedHosts struct {
+	hosts []*config.Host
+	ids   map[string]bool
+}
+
+func (h *importedHosts) add(host *config.Host) {
+	h.hosts = append(h.hosts, host)
+	h.ids[host.Id] = true
+}
+
+func (c *SshConfig) host(alias string) *config.Host {
+	hostname := utils.DefaultString(c.first(alias, "HostName"), alias)
+	hostname = strings.ReplaceAll(hostname, "%h", alias)
+	port := utils.DefaultString(c.first(alias, "Port"), "22")
+	username := c.first(alias, "User")
+
+	host := &config.Host{
+		Id:       alias,
+		Name:     alias,
+		Remote:   config.NewAddress(fmt.Sprintf("%s:%s", hostname, port)),
+		Username: username,
+		Identity: c.expand(c.first(alias, "IdentityFile"), alias, hostname, username),
+	}
+	if host.Identity == "" {
+		host.Identity = c.defaultIdentity(alias, hostname, username)
+	}
+
+	if knownHosts := c.first(alias, "UserKnownHostsFile"); knownHosts != "" {
+		host.KnownHosts = c.expand(knownHosts, alias, hostname, username)
+	} else if path := c.expand(defaultKnownHosts, alias, hostname, username); fileExists(path) {
+		host.KnownHosts = path
+	}
+
+	return host
+}
+
+// defaultIdentity is the first of the keys ssh tries by default that exists
+func (c *SshConfig) defaultIdentity(alias string, hostname string, username string) string {
+	for _, identity := range defaultIdentities {
+		if path := c.expand(identity, alias, hostname, username); fileExists(path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// jumpChain resolves the ProxyJump of alias to the host it jumps through.  ssh connects
+// to each hop through the one before it, so every hop after the first is defined as a
+// host of its own, named for the chain leading to it, jumping through the previous hop.
+// Hops that are not hosts of the file are defined from the options that apply to them
+func (c *SshConfig) jumpChain(alias string, hosts *importedHosts) string {
+	proxyJump := c.first(alias, "ProxyJump")
+	if proxyJump == "" || strings.EqualFold(proxyJump, "none") {
+		return ""
+	}
+	previous := ""
+	for i, hop := range strings.Split(proxyJump, ",") {
+		username, name, port := parseHop(hop)
+		id := name
+		if i > 0 {
+			id = previous + "+" + name
+		}
+		if !hosts.ids[id] {
+			hosts.ids[id] = true
+			host := c.host(name)
+			host.Id, host.Name = id, id
+			if i > 0 {
+				host.JumpHost = previous
+			} else {
+				host.JumpHost = c.jumpChain(name, hosts)
+			}
+			if username != "" {
+				host.Username = username
+			}
+			if port != "" {
+				host.Remote = config.NewAddress(fmt.Sprintf("%s:%s", utils.DefaultString(c.first(name, "HostName"), name), port))
+			}
+			hosts.add(host)
+		}
+		previous = id
+	}
+	return previous
+}
+
+func (c *SshConfig) tunnels(alias string, v *config.Validations) []*config.Tunnel {
+	var tunnels []*config.Tunnel
+	for i, args := range c.GetAll(alias, "LocalForward") {
+		if len(args) != 2 {
+			v.Warnf("host (%s) LocalForward %s skipped.  Only tcp forwards can be imported", alias, strings.Join(args, " "))
+			continue
+		}
+		tunnels = append(tunnels, &config.Tunnel{
+			Id:     fmt.Sprintf("%s-L%d", alias, i+1),
+			Name:   fmt.Sprintf("%s LocalForward %s", alias, args[0]),
+			Type:   config.TunnelLocal,
+			Local:  config.NewAddress(listenAddress(args[0], "127.0.0.1")),
+			Remote: config.NewAddress(args[1]),
+			Host:   alias,
+		})
+	}
+	for i, args := range c.GetAll(alias, "RemoteForward") {
+		if len(args) != 2 {
+			v.Warnf("host (%s) RemoteForward %s skipped.  Only tcp forwards can be imported", alias, strings.Join(args, " "))
+			continue
+		}
+		tunnels = append(tunnels, &config.Tunnel{
+			Id:     fmt.Sprintf("%s-R%d", alias, i+1),
+			Name:   fmt.Sprintf("%s RemoteForward %s", alias, args[0]),
+			Type:   config.TunnelRemote,
+			Local:  config.NewAddress(args[1]),
+			Remote: config.NewAddress(listenAddress(args[0], "127.0.0.1")),
+			Host:   alias,
+		})
+	}
+	for i, args := range c.GetAll(alias, "DynamicForward") {
+		if len(args) != 1 {
+			v.Warnf("host (%s) DynamicForward %s skipped", alias, strings.Join(args, " "))
+			continue
+		}
+		tunnels = append(tunnels, &config.Tunnel{
+			Id:    fmt.Sprintf("%s-D%d", alias, i+1),
+			Name:  fmt.Sprintf("%s DynamicForward %s", alias, args[0]),
+			Type:  config.TunnelDynamic,
+			Local: config.NewAddress(listenAddress(args[0], "127.0.0.1")),
+			Host:  alias,
+		})
+	}
+	return tunnels
+}
+
+func (c *SshConfig) first(alias string, key string) string {
+	args := c.Get(alias, key)
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+// expand replaces ~ and the common ssh_config tokens in a path
+func (c *SshConfig) expand(path string, alias string, hostname string, username string) string {
+	if path == "" {
+		return ""
+	}
+	home, _ := os.UserHomeDir()
+	local := ""
+	if u, err := user.Current(); err == nil {
+		local = u.Username
+	}
+	path = strings.NewReplacer(
+		"%%", "%",
+		"%d", home,
+		"%h", hostname,
+		"%n", alias,
+		"%r", utils.DefaultString(username, local),
+		"%u", local,
+	).Replace(path)
+	path, _ = utils.ExpandHomeE(path)
+	return path
+}
+
+// listenAddress converts a forward's [bind_address:]port, where * binds every interface
+func listenAddress(spec string, defaultBind string) string {
+	index := strings.LastIndex(spec, ":")
+	if index == -1 {
+		return fmt.Sprintf("%s:%s", defaultBind, spec)
+	}
+	bind := spec[:index]
+	if bind == "*" || bind == "" {
+		bind = "0.0.0.0"
+	}
+	return fmt.Sprintf("%s:%s", bind, spec[index+1:])
+}
+
+// parseHop splits a ProxyJump hop, [user@]host[:port], into its parts
+func parseHop(hop string) (string, string, string) {
+	hop = strings.TrimSpace(hop)
+	hop = strings.TrimPrefix(hop, "ssh://")
+	username, port := "", ""
+	if index := strings.LastIndex(hop, "@"); index != -1 {
+		username, hop = hop[:index], hop[index+1:]
+	}
+	if index := strings.LastIndex(hop, ":"); index != -1 {
+		hop, port = hop[:index], hop[index+1:]
+	}
+	return username, hop, port
+}
+
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
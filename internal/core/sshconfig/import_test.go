@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+const (
+	testConfig = `
+Include conf.d/*.conf
+
+Host bastion
+    HostName 10.0.0.1
+    Port 2222
+    IdentityFile /keys/bastion.pem
+    LocalForward 8000 gateway.internal:443
+    DynamicForward *:1080
+
+Host db web
+    ProxyJump admin@bastion:2222
+    RemoteForward 9000 localhost:3000
+
+Host app
+    ProxyJump bastion,ops@gateway.internal:2200,db
+Host *.internal !skip.internal
+    User internal-user
+
+Match host anything
+    User never-used
+
+# Defaults, applied last as the first value found wins
+Host *
+    User default-user
+`
+	testInclude = `
+Host web
+    HostName=web.internal
+    User "web user"
+    UserKnownHostsFile /keys/known_hosts
+`
+)
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "conf.d"), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config"), []byte(testConfig), 0600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "conf.d", "web.conf"), []byte(testInclude), 0600))
+
+	identity := filepath.Join(dir, "id_ed25519")
+	assert.NoError(t, os.WriteFile(identity, []byte("key"), 0600))
+	defaults := defaultIdentities
+	defaultIdentities = []string{filepath.Join(dir, "id_rsa"), identity}
+	defer func() { defaultIdentities = defaults }()
+
+	v := config.NewValidations()
+	hosts, tunnels, err := Import(filepath.Join(dir, "config"), &v)
+	assert.NoError(t, err)
+	assert.Len(t, hosts, 6)
+
+	byId := make(map[string]*config.Host)
+	for _, host := range hosts {
+		byId[host.Id] = host
+	}
+
+	assert.Equal(t, "web", hosts[0].Id)
+	assert.Equal(t, "web.internal:22", byId["web"].Remote.String())
+	assert.Equal(t, "web user", byId["web"].Username)
+	assert.Equal(t, "/keys/known_hosts", byId["web"].KnownHosts)
+	assert.Equal(t, "bastion", byId["web"].JumpHost)
+
+	assert.Equal(t, "10.0.0.1:2222", byId["bastion"].Remote.String())
+	assert.Equal(t, "default-user", byId["bastion"].Username)
+	assert.Equal(t, "/keys/bastion.pem", byId["bastion"].Identity)
+
+	assert.Equal(t, "db:22", byId["db"].Remote.String())
+	assert.Equal(t, "bastion", byId["db"].JumpHost)
+	assert.Equal(t, identity, byId["db"].Identity)
+
+	assert.Equal(t, "bastion+gateway.internal+db", byId["app"].JumpHost)
+	assert.Equal(t, "bastion+gateway.internal", byId["bastion+gateway.internal+db"].JumpHost)
+	assert.Equal(t, "db:22", byId["bastion+gateway.internal+db"].Remote.String())
+	assert.Equal(t, "bastion", byId["bastion+gateway.internal"].JumpHost)
+	assert.Equal(t, "gateway.internal:2200", byId["bastion+gateway.internal"].Remote.String())
+	assert.Equal(t, "ops", byId["bastion+gateway.internal"].Username)
+
+	byTunnel := make(map[string]*config.Tunnel)
+	for _, tunnel := range tunnels {
+		byTunnel[tunnel.Id] = tunnel
+	}
+	assert.Len(t, tunnels, 4)
+	assert.Equal(t, config.TunnelLocal, byTunnel["bastion-L1"].Type)
+	assert.Equal(t, "127.0.0.1:8000", byTunnel["bastion-L1"].Local.String())
+	assert.Equal(t, "gateway.internal:443", byTunnel["bastion-L1"].Remote.String())
+	assert.Equal(t, config.TunnelDynamic, byTunnel["bastion-D1"].Type)
+	assert.Equal(t, "0.0.0.0:1080", byTunnel["bastion-D1"].Local.String())
+	assert.Equal(t, config.TunnelRemote, byTunnel["db-R1"].Type)
+	assert.Equal(t, "127.0.0.1:9000", byTunnel["db-R1"].Remote.String())
+	assert.Equal(t, "localhost:3000", byTunnel["db-R1"].Local.String())
+	assert.Equal(t, "web", byTunnel["web-R1"].Host)
+}
+
+func TestBlockMatches(t *testing.T) {
+	tests := map[string]struct {
+		patterns []string
+		alias    string
+		match    bool
+	}{
+		"exact":    {patterns: []string{"bastion"}, alias: "bastion", match: true},
+		"case":     {patterns: []string{"Bastion"}, alias: "bastion", match: true},
+		"wildcard": {patterns: []string{"*.internal"}, alias: "db.internal", match: true},
+		"negated":  {patterns: []string{"*.internal", "!db.internal"}, alias: "db.internal", match: false},
+		"miss":     {patterns: []string{"*.internal"}, alias: "bastion", match: false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(tt *testing.T) {
+			assert.Equal(tt, test.match, (&block{patterns: test.patterns}).matches(test.alias))
+		})
+	}
+}
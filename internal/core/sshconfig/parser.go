@@ -0,0 +1,233 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package sshconfig
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"us.figge.auto-ssh/internal/core/utils"
+)
+
+var (
+	maxIncludeDepth = 16
+
+	ErrIncludeDepth = errors.New("include nested too deeply")
+	ErrQuotes       = errors.New("unbalanced quotes")
+)
+
+type option struct {
+	key  string
+	args []string
+}
+
+// block is a Host section of an ssh_config file.  Options before the first Host
+// line belong to an implicit block matching every host
+type block struct {
+	patterns []string
+	match    bool
+	options  []*option
+}
+
+type SshConfig struct {
+	blocks []*block
+}
+
+// Parse reads an OpenSSH client configuration file, following Include directives
+func Parse(filename string) (*SshConfig, error) {
+	c := &SshConfig{
+		blocks: []*block{{patterns: []string{"*"}}},
+	}
+	if err := c.parseFile(filename, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *SshConfig) parseFile(filename string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%w: %s", ErrIncludeDepth, filename)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		key, args, err := parseLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s line %d: %w", filename, line, err)
+		} else if key == "" {
+			continue
+		}
+		switch key {
+		case "host":
+			c.blocks = append(c.blocks, &block{patterns: args})
+		case "match":
+			// Match criteria depend on the connection being made, so they cannot be
+			// evaluated ahead of time.  Options in the block are never applied
+			c.blocks = append(c.blocks, &block{match: true})
+		case "include":
+			for _, arg := range args {
+				if err = c.include(filename, arg, depth); err != nil {
+					return err
+				}
+			}
+		default:
+			current := c.blocks[len(c.blocks)-1]
+			current.options = append(current.options, &option{key: key, args: args})
+		}
+	}
+	return scanner.Err()
+}
+
+// include parses each file matching pattern.  Relative paths are resolved against the
+// directory of the including file
+func (c *SshConfig) include(parent string, pattern string, depth int) error {
+	pattern, _ = utils.ExpandHomeE(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(parent), pattern)
+	}
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if err = c.parseFile(match, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseLine splits a line into a lower case keyword and its arguments.  Keywords may
+// be separated from their arguments by whitespace or a single '='
+func parseLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+	end := strings.IndexAny(line, " \t=")
+	if end == -1 {
+		return strings.ToLower(line), nil, nil
+	}
+	key := strings.ToLower(line[:end])
+	rest := strings.TrimSpace(line[end:])
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "="))
+	args, err := splitArgs(rest)
+	return key, args, err
+}
+
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	quoted, started := false, false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if started {
+				args = append(args, current.String())
+				current.Reset()
+				started = false
+			}
+		case !quoted && r == '#':
+			if started {
+				args = append(args, current.String())
+			}
+			return args, nil
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, ErrQuotes
+	}
+	if started {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// Aliases returns every host named explicitly, without wildcards or negation, in the
+// order they first appear
+func (c *SshConfig) Aliases() []string {
+	var aliases []string
+	seen := make(map[string]bool)
+	for _, b := range c.blocks[1:] {
+		for _, pattern := range b.patterns {
+			if strings.ContainsAny(pattern, "*?!") || seen[pattern] {
+				continue
+			}
+			seen[pattern] = true
+			aliases = append(aliases, pattern)
+		}
+	}
+	return aliases
+}
+
+// Get returns the arguments of the first value obtained for key, matching OpenSSH
+// where the first value found for a host wins
+func (c *SshConfig) Get(alias string, key string) []string {
+	key = strings.ToLower(key)
+	for _, b := range c.blocks {
+		if !b.matches(alias) {
+			continue
+		}
+		for _, o := range b.options {
+			if o.key == key {
+				return o.args
+			}
+		}
+	}
+	return nil
+}
+
+// GetAll returns every value for a key that accumulates, such as LocalForward
+func (c *SshConfig) GetAll(alias string, key string) [][]string {
+	key = strings.ToLower(key)
+	var values [][]string
+	for _, b := range c.blocks {
+		if !b.matches(alias) {
+			continue
+		}
+		for _, o := range b.options {
+			if o.key == key {
+				values = append(values, o.args)
+			}
+		}
+	}
+	return values
+}
+
+func (b *block) matches(alias string) bool {
+	if b.match {
+		return false
+	}
+	alias = strings.ToLower(alias)
+	matched := false
+	for _, pattern := range b.patterns {
+		pattern = strings.ToLower(pattern)
+		if negated := strings.HasPrefix(pattern, "!"); negated {
+			if utils.MatchWildcard(pattern[1:], alias) {
+				return false
+			}
+		} else if utils.MatchWildcard(pattern, alias) {
+			matched = true
+		}
+	}
+	return matched
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package backoff
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+type OptFn func(*backoffConfig)
+
+type backoffConfig struct {
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	multiplier   float64
+	jitter       float64
+	maxAttempts  int
+}
+
+// Backoff produces exponentially growing delays between attempts.  Each delay is spread
+// by up to +/- jitter of itself so that many retries do not fire in lockstep
+type Backoff struct {
+	*backoffConfig
+	attempts int
+	random   func() float64
+}
+
+func NewBackoff(options ...OptFn) *Backoff {
+	b := &Backoff{
+		backoffConfig: &backoffConfig{
+			initialDelay: time.Second,
+			maxDelay:     time.Minute,
+			multiplier:   2,
+			jitter:       0.2,
+			maxAttempts:  0,
+		},
+		random: rand.Float64,
+	}
+	for _, option := range options {
+		option(b.backoffConfig)
+	}
+	if b.maxDelay < b.initialDelay {
+		b.maxDelay = b.initialDelay
+	}
+	return b
+}
+
+func OptionInitialDelay(delay time.Duration) OptFn {
+	return func(c *backoffConfig) {
+		if delay > 0 {
+			c.initialDelay = delay
+		}
+	}
+}
+func OptionMaxDelay(delay time.Duration) OptFn {
+	return func(c *backoffConfig) {
+		if delay > 0 {
+			c.maxDelay = delay
+		}
+	}
+}
+func OptionMultiplier(multiplier float64) OptFn {
+	return func(c *backoffConfig) {
+		if multiplier >= 1 {
+			c.multiplier = multiplier
+		}
+	}
+}
+func OptionJitter(jitter float64) OptFn {
+	return func(c *backoffConfig) {
+		c.jitter = math.Max(0, math.Min(1, jitter))
+	}
+}
+func OptionMaxAttempts(attempts int) OptFn {
+	return func(c *backoffConfig) {
+		c.maxAttempts = max(0, attempts)
+	}
+}
+
+// OptionRetry applies each value set in a configured retry policy
+func OptionRetry(retry *config.Retry) OptFn {
+	return func(c *backoffConfig) {
+		if retry == nil {
+			return
+		}
+		OptionInitialDelay(seconds(retry.InitialDelay))(c)
+		OptionMaxDelay(seconds(retry.MaxDelay))(c)
+		OptionMultiplier(retry.Multiplier)(c)
+		if retry.Jitter != nil {
+			OptionJitter(*retry.Jitter)(c)
+		}
+		OptionMaxAttempts(retry.MaxAttempts)(c)
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Next returns the delay before the next attempt, or false once the attempts are exhausted
+func (b *Backoff) Next() (time.Duration, bool) {
+	if b.maxAttempts > 0 && b.attempts >= b.maxAttempts {
+		return 0, false
+	}
+	delay := float64(b.initialDelay) * math.Pow(b.multiplier, float64(b.attempts))
+	delay = math.Min(delay, float64(b.maxDelay))
+	delay += delay * b.jitter * (2*b.random() - 1)
+	b.attempts++
+	return time.Duration(delay), true
+}
+
+// Attempts is the number of retries handed out since the last reset
+func (b *Backoff) Attempts() int {
+	return b.attempts
+}
+
+func (b *Backoff) Reset() {
+	b.attempts = 0
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package backoff
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/utils"
+)
+
+func TestConfigDefault(t *testing.T) {
+	b := NewBackoff()
+	assert.Equal(t, time.Second, b.initialDelay)
+	assert.Equal(t, time.Minute, b.maxDelay)
+	assert.Equal(t, 2.0, b.multiplier)
+	assert.Equal(t, 0.2, b.jitter)
+	assert.Equal(t, 0, b.maxAttempts)
+}
+
+func TestConfigRetry(t *testing.T) {
+	b := NewBackoff(OptionRetry(&config.Retry{
+		InitialDelay: 0.5,
+		MaxDelay:     10,
+		Multiplier:   3,
+		Jitter:       utils.Ptr(0.0),
+		MaxAttempts:  4,
+	}))
+	assert.Equal(t, 500*time.Millisecond, b.initialDelay)
+	assert.Equal(t, 10*time.Second, b.maxDelay)
+	assert.Equal(t, 3.0, b.multiplier)
+	assert.Equal(t, 0.0, b.jitter)
+	assert.Equal(t, 4, b.maxAttempts)
+
+	b = NewBackoff(OptionRetry(&config.Retry{Multiplier: 0.5, Jitter: utils.Ptr(2.0), MaxDelay: 0.1}))
+	assert.Equal(t, 2.0, b.multiplier)
+	assert.Equal(t, 1.0, b.jitter)
+	assert.Equal(t, time.Second, b.maxDelay)
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		name   string
+		random float64
+		delays []time.Duration
+	}{
+		{"no jitter", 0.5, []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}},
+		{"low jitter", 0, []time.Duration{750 * time.Millisecond, 1500 * time.Millisecond, 3 * time.Second, 3750 * time.Millisecond}},
+		{"high jitter", 1, []time.Duration{1250 * time.Millisecond, 2500 * time.Millisecond, 5 * time.Second, 6250 * time.Millisecond}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := NewBackoff(OptionMaxDelay(5*time.Second), OptionJitter(0.25))
+			b.random = func() float64 { return test.random }
+			for i, expected := range test.delays {
+				delay, ok := b.Next()
+				assert.True(t, ok)
+				assert.Equal(t, expected, delay)
+				assert.Equal(t, i+1, b.Attempts())
+			}
+		})
+	}
+}
+
+func TestMaxAttempts(t *testing.T) {
+	b := NewBackoff(OptionMaxAttempts(2))
+	_, ok := b.Next()
+	assert.True(t, ok)
+	_, ok = b.Next()
+	assert.True(t, ok)
+	_, ok = b.Next()
+	assert.False(t, ok)
+	b.Reset()
+	assert.Equal(t, 0, b.Attempts())
+	_, ok = b.Next()
+	assert.True(t, ok)
+}
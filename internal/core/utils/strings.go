@@ -43,7 +43,7 @@ func TruncateText(s string, max int) string {
 	if len(s) < max {
 		return s
 	}
-	return s[:strings.LastIndexAny(s[:max-3], "\t\n .,:;-")] + "..."
+	return truncate(s, max)
 }
 
 func TruncateLine(s string, offset int) string {
@@ -51,7 +51,16 @@ func TruncateLine(s string, offset int) string {
 	if len(s) < max {
 		return s
 	}
-	return s[:strings.LastIndexAny(s[:max-3], "\t\n .,:;-")] + "..."
+	return truncate(s, max)
+}
+
+// truncate cuts s at the last break before max, or at max when it has none
+func truncate(s string, max int) string {
+	index := strings.LastIndexAny(s[:max-3], "\t\n .,:;-")
+	if index < 1 {
+		index = max - 3
+	}
+	return s[:index] + "..."
 }
 
 func Wrap(s string, indent int) string {
@@ -84,6 +93,33 @@ func Wrap(s string, indent int) string {
 	return strings.Join(lines, "\n") + extra
 }
 
+// MatchWildcard matches s against a pattern where * matches any run of characters
+// and ? matches exactly one
+func MatchWildcard(pattern string, s string) bool {
+	star, mark := -1, 0
+	p, i := 0, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star != -1:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
 func VPrintf(template string, v ...interface{}) {
 	if config.VerboseFlag {
 		fmt.Printf(template, v...)
@@ -97,6 +133,12 @@ func Ask(prompt string, hidden bool, inline bool) (string, bool) {
 	if config.ForcedFlag {
 		return "yes", true
 	}
+	return Prompt(prompt, hidden, inline)
+}
+
+// Prompt reads an answer from the terminal.  Unlike Ask it is never answered by --force,
+// so it is the one secrets are read with
+func Prompt(prompt string, hidden bool, inline bool) (string, bool) {
 	fmt.Print(prompt)
 	if !inline {
 		fmt.Println()
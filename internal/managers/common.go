@@ -5,12 +5,16 @@
 package managers
 
 import (
+	"context"
+	"fmt"
 	"math/rand"
 	"strings"
 	"time"
 	"unsafe"
 
+	"us.figge.auto-ssh/internal/core/config"
 	"us.figge.auto-ssh/internal/core/utils/cache"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
 	"us.figge.auto-ssh/internal/rest/models"
 )
 
@@ -23,6 +27,8 @@ const (
 
 var (
 	src = rand.NewSource(time.Now().UnixNano())
+
+	ErrForbidden = fmt.Errorf("forbidden")
 )
 
 func Page[S any](items []S, p models.PaginationInput, listCache *cache.Cache[string, []S]) ([]S, *string) {
@@ -71,3 +77,60 @@ func ExtractTunnelOptions(opts []models.TunnelOptionFunc) *models.TunnelOptions
 	}
 	return options
 }
+
+func ExtractHostOptions(opts []models.HostOptionFunc) *models.HostOptions {
+	options := &models.HostOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+func ExtractConfigOptions(opts []models.ConfigOptionFunc) *models.ConfigOptions {
+	options := &models.ConfigOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+func metadataTags(metadata *config.Metadata) []string {
+	if metadata == nil {
+		return nil
+	}
+	return metadata.Tags
+}
+
+// visible reports whether the caller may see a resource carrying metadata
+func visible(ctx context.Context, metadata *config.Metadata) bool {
+	return models.IdentityFrom(ctx).Sees(metadataTags(metadata))
+}
+
+// scoped rejects a definition the caller would not be able to see once it was saved
+func scoped(ctx context.Context, kind string, metadata *config.Metadata) error {
+	if identity := models.IdentityFrom(ctx); !identity.Sees(metadataTags(metadata)) {
+		return fmt.Errorf("%w: token (%s) may only manage %ss tagged %s", ErrForbidden, identity.Name, kind, strings.Join(identity.Tags, ", "))
+	}
+	return nil
+}
+
+// scopedHost rejects a reference, by id or name, to a host the caller cannot see, so a
+// definition it can see cannot use a host outside its scope
+func scopedHost(ctx context.Context, hosts engineModels.HostEngine, field string, ref string) error {
+	if ref == "" {
+		return nil
+	}
+	host, ok := hosts.Host(ref)
+	if !ok {
+		for _, h := range hosts.Hosts() {
+			if h.Name() == ref {
+				host, ok = h, true
+				break
+			}
+		}
+	}
+	if identity := models.IdentityFrom(ctx); ok && !identity.Sees(metadataTags(host.Metadata())) {
+		return fmt.Errorf("%w: token (%s) may only use %s hosts tagged %s", ErrForbidden, identity.Name, field, strings.Join(identity.Tags, ", "))
+	}
+	return nil
+}
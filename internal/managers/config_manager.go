@@ -0,0 +1,101 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package managers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"us.figge.auto-ssh/internal/core/config"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+// ConfigManager writes the hosts and tunnels running in the engines back to the file
+// the configuration was loaded from, as they were configured rather than with the
+// defaults validation filled in.  Those imported from an ssh_config file are left out,
+// as they are imported again on every start
+type ConfigManager struct {
+	lock     sync.Mutex
+	filename string
+	hosts    engineModels.HostEngine
+	tunnels  engineModels.TunnelEngine
+}
+
+func NewConfigManager(
+	ctx context.Context, filename string, hosts engineModels.HostEngine, tunnels engineModels.TunnelEngine,
+) (*ConfigManager, error) {
+	manager := &ConfigManager{
+		filename: filename,
+		hosts:    hosts,
+		tunnels:  tunnels,
+	}
+	return manager, nil
+}
+
+func (m *ConfigManager) GetConfig(
+	ctx context.Context,
+	input *managerModels.GetConfigInput,
+	options ...managerModels.ConfigOptionFunc,
+) (*managerModels.GetConfigOutput, error) {
+	return &managerModels.GetConfigOutput{File: m.filename, Pid: os.Getpid()}, nil
+}
+
+func (m *ConfigManager) SaveConfig(
+	ctx context.Context,
+	input *managerModels.SaveConfigInput,
+	opts ...managerModels.ConfigOptionFunc,
+) (*managerModels.SaveConfigOutput, error) {
+	options := ExtractConfigOptions(opts)
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	snapshot := &config.Configuration{}
+	for _, host := range m.hosts.Configured() {
+		if host.Source == "" {
+			snapshot.Hosts = append(snapshot.Hosts, host)
+		}
+	}
+	for _, tunnel := range m.tunnels.Configured() {
+		if tunnel.Source == "" {
+			snapshot.Tunnels = append(snapshot.Tunnels, tunnel)
+		}
+	}
+	write := snapshot.WriteConfig
+	if options.Force() {
+		write = snapshot.OverwriteConfig
+	}
+	backup, err := write(m.filename)
+	if err != nil {
+		return nil, err
+	}
+	return &managerModels.SaveConfigOutput{
+		File:    m.filename,
+		Backup:  backup,
+		Hosts:   len(snapshot.Hosts),
+		Tunnels: len(snapshot.Tunnels),
+	}, nil
+}
+
+// autoSave persists a change made through the api.  Failures are reported but do not
+// undo the change, which is already running.  A file edited by hand since it was loaded
+// is not overwritten, so the change is not saved until it is saved with force
+func (m *ConfigManager) autoSave(ctx context.Context) {
+	if m == nil || m.filename == "" {
+		return
+	}
+	output, err := m.SaveConfig(ctx, &managerModels.SaveConfigInput{})
+	if errors.Is(err, config.ErrConfigEdited) {
+		fmt.Printf("  Warn  - %v.  The change is running, but is lost on the next reload or restart unless saved with POST /config/save?force=true once the file is reconciled\n", err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("  Error - failed to save configuration: %v\n", err)
+		return
+	}
+	fmt.Printf("  Info  - configuration saved to %s\n", output.File)
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package managers
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/resources/engine/enginetest"
+	"us.figge.auto-ssh/internal/resources/engine/host"
+	"us.figge.auto-ssh/internal/resources/engine/tunnel"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+// testManagers loads the engines from a configuration file written with contents, and
+// returns the managers saving back to it
+func testManagers(t *testing.T, contents string) (string, *ConfigManager, *HostManager) {
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(contents), 0600))
+	config.Applied(filename, sha256.Sum256([]byte(contents)))
+	c := config.NewConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(contents), c))
+
+	ctx := context.Background()
+	hosts := host.NewEngine(ctx, c.Hosts)
+	tunnels := tunnel.NewEngine(ctx, hosts, c.Tunnels)
+	configManager, err := NewConfigManager(ctx, filename, hosts, tunnels)
+	require.NoError(t, err)
+	hostManager, err := NewHostManager(ctx, hosts, configManager)
+	require.NoError(t, err)
+	return filename, configManager, hostManager
+}
+
+func TestSaveConfigWritesOnlyConfigured(t *testing.T) {
+	identity := enginetest.Identity(t)
+	filename, manager, _ := testManagers(t, `hosts:
+  - name: bastion
+    remote: 10.0.0.1
+    identity: `+identity+`
+tunnels:
+  - name: web
+    remote: 127.0.0.1:8080
+`)
+
+	output, err := manager.SaveConfig(context.Background(), &managerModels.SaveConfigInput{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, output.Hosts)
+	assert.Equal(t, 1, output.Tunnels)
+
+	bs, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	written := string(bs)
+	assert.Contains(t, written, "remote: 10.0.0.1\n")
+	assert.NotContains(t, written, "username:")
+	assert.NotContains(t, written, "type:")
+	assert.NotContains(t, written, "local:")
+	assert.NotContains(t, written, "id:")
+}
+
+func TestAutoSave(t *testing.T) {
+	identity := enginetest.Identity(t)
+	filename, _, hostManager := testManagers(t, `# hand written
+hosts:
+  - name: bastion # the way in
+    remote: 10.0.0.1:22
+    identity: `+identity+`
+`)
+
+	input := &managerModels.AddHostInput{Host: *enginetest.Host(identity, "", "db", "bastion")}
+	_, err := hostManager.AddHost(context.Background(), input)
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	saved := config.NewConfig()
+	require.NoError(t, yaml.Unmarshal(bs, saved))
+	require.Len(t, saved.Hosts, 2)
+	assert.Equal(t, "db", saved.Hosts[1].Name)
+	assert.Equal(t, "bastion", saved.Hosts[1].JumpHost)
+	assert.Contains(t, string(bs), "# hand written")
+	assert.Contains(t, string(bs), "name: bastion # the way in")
+}
+
+func TestAutoSaveKeepsEdits(t *testing.T) {
+	identity := enginetest.Identity(t)
+	filename, configManager, hostManager := testManagers(t, `hosts:
+  - name: bastion
+    remote: 10.0.0.1:22
+    identity: `+identity+`
+`)
+	edited := []byte("# edited by hand\nhosts: []\n")
+	require.NoError(t, os.WriteFile(filename, edited, 0600))
+
+	input := &managerModels.AddHostInput{Host: *enginetest.Host(identity, "", "db", "bastion")}
+	_, err := hostManager.AddHost(context.Background(), input)
+	require.NoError(t, err)
+
+	bs, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, edited, bs)
+
+	_, err = configManager.SaveConfig(context.Background(), &managerModels.SaveConfigInput{})
+	assert.ErrorIs(t, err, config.ErrConfigEdited)
+	output, err := configManager.SaveConfig(context.Background(), &managerModels.SaveConfigInput{}, managerModels.ConfigOptionForce(true))
+	require.NoError(t, err)
+	bs, err = os.ReadFile(output.Backup)
+	require.NoError(t, err)
+	assert.Equal(t, edited, bs, "the edits are kept in the backup")
+	bs, err = os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(bs), "name: db")
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package managers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"us.figge.auto-ssh/internal/core/events"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+type EventManager struct {
+}
+
+func NewEventManager(ctx context.Context) (*EventManager, error) {
+	manager := &EventManager{}
+	return manager, nil
+}
+
+// StreamEvents subscribes to the events the caller can see and that match the input's
+// filters, until ctx ends
+func (m *EventManager) StreamEvents(
+	ctx context.Context,
+	input *managerModels.StreamEventsInput,
+	options ...managerModels.EventOptionFunc,
+) (*managerModels.StreamEventsOutput, error) {
+	identity := managerModels.IdentityFrom(ctx)
+	match := func(event *events.Event) bool {
+		return identity.Sees(event.Tags) && eventFilter(input, event)
+	}
+
+	subscription := events.Subscribe(input.LastEventId)
+	output := &managerModels.StreamEventsOutput{Missed: subscription.Missed}
+	for _, event := range subscription.History {
+		if match(event) {
+			output.History = append(output.History, event)
+		}
+	}
+	stream := make(chan *events.Event)
+	output.Events = stream
+	go func() {
+		defer close(stream)
+		defer events.Unsubscribe(subscription)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-subscription.Events:
+				if !ok {
+					return
+				}
+				if !match(event) {
+					continue
+				}
+				select {
+				case stream <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return output, nil
+}
+
+func eventFilter(input *managerModels.StreamEventsInput, event *events.Event) bool {
+	if len(input.Tunnels) > 0 && !slices.Contains(input.Tunnels, event.Tunnel) {
+		return false
+	}
+	if len(input.Hosts) > 0 && !slices.Contains(input.Hosts, event.Host) {
+		return false
+	}
+	if len(input.Tags) > 0 && !contains(input.Tags, event.Tags) {
+		return false
+	}
+	if len(input.Types) > 0 && !slices.ContainsFunc(input.Types, func(t string) bool {
+		return event.Type == t || strings.HasPrefix(event.Type, t+".")
+	}) {
+		return false
+	}
+	return true
+}
@@ -6,6 +6,7 @@ package managers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"strconv"
@@ -24,13 +25,15 @@ var (
 
 type HostManager struct {
 	hosts               engineModels.HostEngine
+	config              *ConfigManager
 	listHostHeaderCache *cache.Cache[string, []*managerModels.HostHeader]
 	listKnownHostCache  *cache.Cache[string, []*managerModels.KnownHost]
 }
 
-func NewHostManager(ctx context.Context, hosts engineModels.HostEngine) (*HostManager, error) {
+func NewHostManager(ctx context.Context, hosts engineModels.HostEngine, configManager *ConfigManager) (*HostManager, error) {
 	manager := &HostManager{
 		hosts:               hosts,
+		config:              configManager,
 		listHostHeaderCache: cache.NewCache[string, []*managerModels.HostHeader](ctx, cache.OptionDefaultTTL(5*time.Minute)),
 		listKnownHostCache:  cache.NewCache[string, []*managerModels.KnownHost](ctx, cache.OptionDefaultTTL(5*time.Minute)),
 	}
@@ -40,14 +43,19 @@ func NewHostManager(ctx context.Context, hosts engineModels.HostEngine) (*HostMa
 func (m *HostManager) ListHosts(
 	ctx context.Context,
 	input *managerModels.ListHostInput,
-	options ...managerModels.HostOptionFunc,
+	opts ...managerModels.HostOptionFunc,
 ) (*managerModels.ListHostOutput, error) {
+	options := ExtractHostOptions(opts)
 	output := &managerModels.ListHostOutput{}
 	var items []*managerModels.HostHeader
 	if input.More == nil {
 		for _, host := range m.hosts.Hosts() {
-			if hostFilter(input.FiltersInput, host) {
-				items = append(items, &managerModels.HostHeader{Id: host.Id(), Name: host.Name(), Valid: host.Valid()})
+			if visible(ctx, host.Metadata()) && hostFilter(input.FiltersInput, host) {
+				item := &managerModels.HostHeader{Id: host.Id(), Name: host.Name(), Valid: host.Valid(), Running: host.Connected()}
+				if options.Status() {
+					item.Status = host.Status()
+				}
+				items = append(items, item)
 			}
 		}
 	} else {
@@ -61,23 +69,16 @@ func (m *HostManager) ListHosts(
 func (m *HostManager) GetHost(
 	ctx context.Context,
 	input *managerModels.GetHostInput,
-	options ...managerModels.HostOptionFunc,
+	opts ...managerModels.HostOptionFunc,
 ) (*managerModels.GetHostOutput, error) {
+	options := ExtractHostOptions(opts)
 	host, ok := m.hosts.Host(input.Id)
-	if !ok {
+	if !ok || !visible(ctx, host.Metadata()) {
 		return nil, fmt.Errorf("%w: %s", ErrHostNotFound, input.Id)
 	}
-	output := managerModels.GetHostOutput{
-		Host: config.Host{
-			Id:         host.Id(),
-			Name:       host.Name(),
-			Remote:     host.Remote(),
-			Username:   host.Username(),
-			Identity:   host.Identity(),
-			KnownHosts: host.KnownHosts(),
-			JumpHost:   host.JumpHost(),
-			Metadata:   host.Metadata(),
-		},
+	output := managerModels.GetHostOutput{Host: hostConfig(host)}
+	if options.Status() {
+		output.Status = host.Status()
 	}
 	return &output, nil
 }
@@ -87,7 +88,19 @@ func (m *HostManager) AddHost(
 	input *managerModels.AddHostInput,
 	options ...managerModels.HostOptionFunc,
 ) (*managerModels.AddHostOutput, error) {
-	return nil, nil
+	if err := scoped(ctx, "host", input.Metadata); err != nil {
+		return nil, err
+	}
+	if err := scopedHost(ctx, m.hosts, "jumpHost", input.JumpHost); err != nil {
+		return nil, err
+	}
+	input.Status = nil
+	host, err := m.hosts.AddHost(&input.Host)
+	if err != nil {
+		return nil, err
+	}
+	m.config.autoSave(ctx)
+	return &managerModels.AddHostOutput{Host: hostConfig(host)}, nil
 }
 
 func (m *HostManager) UpdateHost(
@@ -95,7 +108,24 @@ func (m *HostManager) UpdateHost(
 	input *managerModels.UpdateHostInput,
 	options ...managerModels.HostOptionFunc,
 ) (*managerModels.UpdateHostOutput, error) {
-	return nil, nil
+	if existing, ok := m.hosts.Host(input.Id); !ok || !visible(ctx, existing.Metadata()) {
+		return nil, fmt.Errorf("%w: %s", ErrHostNotFound, input.Id)
+	}
+	if err := scoped(ctx, "host", input.Metadata); err != nil {
+		return nil, err
+	}
+	if err := scopedHost(ctx, m.hosts, "jumpHost", input.JumpHost); err != nil {
+		return nil, err
+	}
+	input.Status = nil
+	host, err := m.hosts.UpdateHost(input.Id, &input.Host)
+	if errors.Is(err, engineModels.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrHostNotFound, input.Id)
+	} else if err != nil {
+		return nil, err
+	}
+	m.config.autoSave(ctx)
+	return &managerModels.UpdateHostOutput{Host: hostConfig(host)}, nil
 }
 
 func (m *HostManager) RemoveHost(
@@ -103,7 +133,17 @@ func (m *HostManager) RemoveHost(
 	input *managerModels.RemoveHostInput,
 	options ...managerModels.HostOptionFunc,
 ) (*managerModels.RemoveHostOutput, error) {
-	return nil, nil
+	if existing, ok := m.hosts.Host(input.Id); !ok || !visible(ctx, existing.Metadata()) {
+		return nil, fmt.Errorf("%w: %s", ErrHostNotFound, input.Id)
+	}
+	references, err := m.hosts.RemoveHost(input.Id, input.Force)
+	if errors.Is(err, engineModels.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrHostNotFound, input.Id)
+	} else if err != nil {
+		return nil, err
+	}
+	m.config.autoSave(ctx)
+	return &managerModels.RemoveHostOutput{Id: input.Id, References: references}, nil
 }
 
 func (m *HostManager) ListKnownHosts(
@@ -114,7 +154,7 @@ func (m *HostManager) ListKnownHosts(
 	output := &managerModels.ListKnownHostsOutput{}
 	var items []*managerModels.KnownHost
 	if input.More == nil {
-		for _, knownHost := range m.hosts.KnownHosts() {
+		for _, knownHost := range m.knownHosts(ctx) {
 			items = append(items, &managerModels.KnownHost{File: knownHost})
 		}
 	} else {
@@ -125,6 +165,36 @@ func (m *HostManager) ListKnownHosts(
 	return output, nil
 }
 
+// knownHosts lists the known_hosts files of the hosts the caller can see
+func (m *HostManager) knownHosts(ctx context.Context) []string {
+	if identity := managerModels.IdentityFrom(ctx); identity == nil || len(identity.Tags) == 0 {
+		return m.hosts.KnownHosts()
+	}
+	var knownHosts []string
+	for _, host := range m.hosts.Hosts() {
+		if visible(ctx, host.Metadata()) && host.KnownHosts() != "" && !slices.Contains(knownHosts, host.KnownHosts()) {
+			knownHosts = append(knownHosts, host.KnownHosts())
+		}
+	}
+	return knownHosts
+}
+
+func hostConfig(host engineModels.Host) config.Host {
+	return config.Host{
+		Id:         host.Id(),
+		Name:       host.Name(),
+		Remote:     host.Remote(),
+		Username:   host.Username(),
+		Identity:   host.Identity(),
+		KnownHosts: host.KnownHosts(),
+		JumpHost:   host.JumpHost(),
+		Auth:       host.Auth(),
+		KeepAlive:  host.KeepAlive(),
+		Retry:      host.Retry(),
+		Metadata:   host.Metadata(),
+	}
+}
+
 func hostFilter(input managerModels.FiltersInput, host engineModels.Host) bool {
 	for _, filter := range input.Filters {
 		match := false
@@ -134,7 +204,7 @@ func hostFilter(input managerModels.FiltersInput, host engineModels.Host) bool {
 		case "name":
 			match = slices.Contains(filter.Values, host.Name())
 		case "tags":
-			match = contains(filter.Values, host.Metadata().Tags)
+			match = contains(filter.Values, metadataTags(host.Metadata()))
 		case "address":
 			match = slices.Contains(filter.Values, host.Remote().String())
 		case "username":
@@ -28,14 +28,15 @@ func (m MetadataManager) ListStates(ctx context.Context, options ...managerModel
 	for _, enum := range engineModels.RunningEnums() {
 		output.States = append(output.States, enum)
 	}
+	output.Transitions = engineModels.RunningTransitions()
 	return output, nil
 }
 
 func (m MetadataManager) ListTags(ctx context.Context, input *managerModels.ListMetadataTagsInput, options ...managerModels.MetadataOptionFunc) (*managerModels.ListMetadataTagsOutput, error) {
 	output := &managerModels.ListMetadataTagsOutput{}
 	for _, tunnel := range m.tunnels.Tunnels() {
-		if tunnelFilter(input.FiltersInput, tunnel) {
-			for _, tag := range tunnel.Metadata().Tags {
+		if visible(ctx, tunnel.Metadata()) && tunnelFilter(input.FiltersInput, tunnel) {
+			for _, tag := range metadataTags(tunnel.Metadata()) {
 				if !slices.Contains(output.Tags, tag) {
 					output.Tags = append(output.Tags, tag)
 				}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package managers
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+type MetricsManager struct {
+	hosts   engineModels.HostEngine
+	tunnels engineModels.TunnelEngine
+}
+
+func NewMetricsManager(ctx context.Context, hosts engineModels.HostEngine, tunnels engineModels.TunnelEngine) (*MetricsManager, error) {
+	manager := &MetricsManager{
+		hosts:   hosts,
+		tunnels: tunnels,
+	}
+	return manager, nil
+}
+
+// GetMetrics collects the stats of the tunnels and hosts the caller can see, ordered by id
+func (m *MetricsManager) GetMetrics(
+	ctx context.Context,
+	input *managerModels.GetMetricsInput,
+	options ...managerModels.MetricsOptionFunc,
+) (*managerModels.GetMetricsOutput, error) {
+	output := &managerModels.GetMetricsOutput{}
+	for _, tunnel := range m.tunnels.Tunnels() {
+		if visible(ctx, tunnel.Metadata()) {
+			output.Tunnels = append(output.Tunnels, tunnelMetrics(tunnel))
+		}
+	}
+	for _, host := range m.hosts.Hosts() {
+		if !visible(ctx, host.Metadata()) {
+			continue
+		}
+		stats := host.Stats()
+		output.Hosts = append(output.Hosts, &managerModels.HostMetrics{
+			Id:         host.Id(),
+			Name:       host.Name(),
+			Tags:       metadataTags(host.Metadata()),
+			Valid:      host.Valid(),
+			Connected:  host.Connected(),
+			Failures:   stats.Failures,
+			Reconnects: stats.Reconnects,
+		})
+	}
+	slices.SortFunc(output.Tunnels, func(a, b *managerModels.TunnelMetrics) int { return strings.Compare(a.Id, b.Id) })
+	slices.SortFunc(output.Hosts, func(a, b *managerModels.HostMetrics) int { return strings.Compare(a.Id, b.Id) })
+	return output, nil
+}
+
+func tunnelMetrics(tunnel engineModels.Tunnel) *managerModels.TunnelMetrics {
+	metrics := &managerModels.TunnelMetrics{
+		Id:          tunnel.Id(),
+		Name:        tunnel.Name(),
+		Host:        tunnel.Host(),
+		Tags:        metadataTags(tunnel.Metadata()),
+		Running:     tunnel.Running(),
+		DialBuckets: engineModels.DialBuckets,
+		DialCounts:  make([]uint64, len(engineModels.DialBuckets)),
+	}
+	if stats := tunnel.Stats(); stats != nil {
+		snapshot := stats.Snapshot()
+		metrics.Received = snapshot.Received
+		metrics.Transmitted = snapshot.Transmitted
+		metrics.Open = snapshot.Open
+		metrics.Connections = snapshot.Connections
+		metrics.Failures = snapshot.Failures
+		metrics.Reconnects = snapshot.Reconnects
+		metrics.DialCounts = snapshot.DialCounts
+		metrics.DialCount = snapshot.DialCount
+		metrics.DialSeconds = snapshot.DialSeconds
+	}
+	return metrics
+}
@@ -6,6 +6,7 @@ package managers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"strconv"
@@ -25,13 +26,19 @@ var (
 )
 
 type TunnelManager struct {
+	hosts     engineModels.HostEngine
 	tunnels   engineModels.TunnelEngine
+	config    *ConfigManager
 	listCache *cache.Cache[string, []*managerModels.TunnelHeader]
 }
 
-func NewTunnelManager(ctx context.Context, tunnels engineModels.TunnelEngine) (*TunnelManager, error) {
+func NewTunnelManager(
+	ctx context.Context, hosts engineModels.HostEngine, tunnels engineModels.TunnelEngine, configManager *ConfigManager,
+) (*TunnelManager, error) {
 	manager := &TunnelManager{
+		hosts:     hosts,
 		tunnels:   tunnels,
+		config:    configManager,
 		listCache: cache.NewCache[string, []*managerModels.TunnelHeader](ctx, cache.OptionDefaultTTL(5*time.Minute)),
 	}
 	return manager, nil
@@ -47,16 +54,13 @@ func (m *TunnelManager) ListTunnels(
 	var items []*managerModels.TunnelHeader
 	if input.More == nil {
 		for _, tunnel := range m.tunnels.Tunnels() {
-			if tunnelFilter(input.FiltersInput, tunnel) {
+			if visible(ctx, tunnel.Metadata()) && tunnelFilter(input.FiltersInput, tunnel) {
 				item := &managerModels.TunnelHeader{
 					Id:   tunnel.Id(),
 					Name: tunnel.Name(),
 				}
 				if options.Status() {
-					item.Status = &config.Status{
-						Valid:   tunnel.Valid(),
-						Running: tunnel.Running(),
-					}
+					item.Status = tunnel.Status()
 				}
 				items = append(items, item)
 			}
@@ -76,54 +80,85 @@ func (m *TunnelManager) GetTunnel(
 ) (*managerModels.GetTunnelOutput, error) {
 	options := ExtractTunnelOptions(opts)
 	tunnel, ok := m.tunnels.Tunnel(input.Id)
-	if !ok {
+	if !ok || !visible(ctx, tunnel.Metadata()) {
 		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, input.Id)
 	}
-	output := managerModels.GetTunnelOutput{
-		Tunnel: config.Tunnel{
-			Id:     tunnel.Id(),
-			Name:   tunnel.Name(),
-			Local:  tunnel.Local(),
-			Remote: tunnel.Remote(),
-			Host:   tunnel.Host(),
-		},
-	}
-	if options.Metadata() {
-		output.Metadata = tunnel.Metadata()
-
-	}
-	if options.Status() {
-		output.Status = &config.Status{
-			Valid:   tunnel.Valid(),
-			Running: tunnel.Running(),
-		}
-
-	}
+	output := managerModels.GetTunnelOutput{Tunnel: tunnelConfig(tunnel, options)}
 	return &output, nil
 }
 
 func (m *TunnelManager) AddTunnel(
 	ctx context.Context,
 	input *managerModels.AddTunnelInput,
-	options ...managerModels.TunnelOptionFunc,
+	opts ...managerModels.TunnelOptionFunc,
 ) (*managerModels.AddTunnelOutput, error) {
-	return nil, nil
+	options := ExtractTunnelOptions(opts)
+	if err := scoped(ctx, "tunnel", input.Metadata); err != nil {
+		return nil, err
+	}
+	if err := scopedHost(ctx, m.hosts, "host", input.Host); err != nil {
+		return nil, err
+	}
+	input.Status = nil
+	tunnel, err := m.tunnels.AddTunnel(&input.Tunnel, input.Start)
+	if err != nil {
+		return nil, err
+	}
+	if input.Start {
+		waitWhile(tunnel, engineModels.Starting)
+	}
+	m.config.autoSave(ctx)
+	return &managerModels.AddTunnelOutput{Tunnel: tunnelConfig(tunnel, options)}, nil
 }
 
 func (m *TunnelManager) UpdateTunnel(
 	ctx context.Context,
 	input *managerModels.UpdateTunnelInput,
-	options ...managerModels.TunnelOptionFunc,
+	opts ...managerModels.TunnelOptionFunc,
 ) (*managerModels.UpdateTunnelOutput, error) {
-	return nil, nil
+	options := ExtractTunnelOptions(opts)
+	existing, ok := m.tunnels.Tunnel(input.Id)
+	if !ok || !visible(ctx, existing.Metadata()) {
+		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, input.Id)
+	}
+	if socks := existing.Socks(); input.Socks != nil && input.Socks.Password == config.Masked && socks != nil {
+		// The definition was read back from the api, which masks the password
+		input.Socks.Password = socks.Password
+	}
+	if err := scoped(ctx, "tunnel", input.Metadata); err != nil {
+		return nil, err
+	}
+	if err := scopedHost(ctx, m.hosts, "host", input.Host); err != nil {
+		return nil, err
+	}
+	input.Status = nil
+	tunnel, err := m.tunnels.UpdateTunnel(input.Id, &input.Tunnel)
+	if errors.Is(err, engineModels.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, input.Id)
+	} else if err != nil {
+		return nil, err
+	}
+	waitWhile(tunnel, engineModels.Starting)
+	m.config.autoSave(ctx)
+	return &managerModels.UpdateTunnelOutput{Tunnel: tunnelConfig(tunnel, options)}, nil
 }
 
 func (m *TunnelManager) RemoveTunnel(
 	ctx context.Context,
 	input *managerModels.RemoveTunnelInput,
-	options ...managerModels.TunnelOptionFunc,
+	opts ...managerModels.TunnelOptionFunc,
 ) (*managerModels.RemoveTunnelOutput, error) {
-	return nil, nil
+	if existing, ok := m.tunnels.Tunnel(input.Id); !ok || !visible(ctx, existing.Metadata()) {
+		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, input.Id)
+	}
+	err := m.tunnels.RemoveTunnel(input.Id)
+	if errors.Is(err, engineModels.ErrNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, input.Id)
+	} else if err != nil {
+		return nil, err
+	}
+	m.config.autoSave(ctx)
+	return &managerModels.RemoveTunnelOutput{Id: input.Id}, nil
 }
 
 func (m *TunnelManager) StartTunnel(
@@ -132,29 +167,19 @@ func (m *TunnelManager) StartTunnel(
 	opts ...managerModels.TunnelOptionFunc,
 ) (*managerModels.StartTunnelOutput, error) {
 	tunnel, ok := m.tunnels.Tunnel(input.Id)
-	if !ok {
+	if !ok || !visible(ctx, tunnel.Metadata()) {
 		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, input.Id)
 	}
 	if !tunnel.Valid() {
 		return nil, fmt.Errorf("%w: %s(%s)", ErrInvalidTunnel, tunnel.Name(), input.Id)
 	}
-	if strings.EqualFold(tunnel.Running(), "Running") {
+	if running := tunnel.Running(); running != engineModels.Stopped.String() && running != engineModels.Failed.String() {
 		return nil, fmt.Errorf("%w: %s(%s)", ErrTunnelRunning, tunnel.Name(), input.Id)
 	}
 	tunnel.Start()
-	// TODO Move to function and start with Stop
-	for range 5 {
-		tunnel, _ = m.tunnels.Tunnel(input.Id)
-		if tunnel.Running() != "Stopped" {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
-	}
+	waitWhile(tunnel, engineModels.Starting)
 	output := &managerModels.StartTunnelOutput{Id: input.Id}
-	output.Status = &config.Status{
-		Valid:   tunnel.Valid(),
-		Running: tunnel.Running(),
-	}
+	output.Status = tunnel.Status()
 	return output, nil
 }
 
@@ -165,19 +190,47 @@ func (m *TunnelManager) StopTunnel(
 ) (*managerModels.StopTunnelOutput, error) {
 	//options := ExtractTunnelOptions(opts)
 	tunnel, ok := m.tunnels.Tunnel(input.Id)
-	if !ok {
+	if !ok || !visible(ctx, tunnel.Metadata()) {
 		return nil, fmt.Errorf("%w: %s", ErrTunnelNotFound, input.Id)
 	}
 	tunnel.Stop()
-	tunnel, _ = m.tunnels.Tunnel(input.Id)
+	waitWhile(tunnel, engineModels.Stopping)
 	output := &managerModels.StopTunnelOutput{Id: input.Id}
-	output.Status = &config.Status{
-		Valid:   tunnel.Valid(),
-		Running: tunnel.Running(),
-	}
+	output.Status = tunnel.Status()
 	return output, nil
 }
 
+func tunnelConfig(tunnel engineModels.Tunnel, options *managerModels.TunnelOptions) config.Tunnel {
+	cfg := config.Tunnel{
+		Id:     tunnel.Id(),
+		Name:   tunnel.Name(),
+		Type:   tunnel.Type(),
+		Local:  tunnel.Local(),
+		Remote: tunnel.Remote(),
+		Host:   tunnel.Host(),
+		Socks:  tunnel.Socks().Masked(),
+		Retry:  tunnel.Retry(),
+	}
+	if options.Metadata() {
+		cfg.Metadata = tunnel.Metadata()
+	}
+	if options.Status() {
+		cfg.Status = tunnel.Status()
+	}
+	return cfg
+}
+
+// waitWhile gives a tunnel a moment to leave a transitional state, so the status returned
+// reflects the outcome of a start or stop where possible
+func waitWhile(tunnel engineModels.Tunnel, running engineModels.Running) {
+	for range 5 {
+		if tunnel.Running() != running.String() {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
 func tunnelFilter(input managerModels.FiltersInput, tunnel engineModels.Tunnel) bool {
 	for _, filter := range input.Filters {
 		match := false
@@ -187,7 +240,9 @@ func tunnelFilter(input managerModels.FiltersInput, tunnel engineModels.Tunnel)
 		case "name":
 			match = slices.Contains(filter.Values, tunnel.Name())
 		case "tags":
-			match = contains(filter.Values, tunnel.Metadata().Tags)
+			match = contains(filter.Values, metadataTags(tunnel.Metadata()))
+		case "type":
+			match = slices.Contains(filter.Values, tunnel.Type())
 		case "local":
 			match = slices.Contains(filter.Values, tunnel.Local().String())
 		case "remote":
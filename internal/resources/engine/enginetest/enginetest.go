@@ -0,0 +1,52 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+// Package enginetest holds the fixtures the engine tests share
+package enginetest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+// Identity writes a new, unencrypted ed25519 private key into a temporary directory and
+// returns its path
+func Identity(t testing.TB) string {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	block, err := ssh.MarshalPrivateKey(key, "")
+	require.NoError(t, err)
+	identity := filepath.Join(t.TempDir(), "id_ed25519")
+	require.NoError(t, os.WriteFile(identity, pem.EncodeToMemory(block), 0600))
+	return identity
+}
+
+// Host is a valid host definition using identity, jumping through jump if it is set
+func Host(identity string, id string, name string, jump string) *config.Host {
+	return &config.Host{
+		Id:       id,
+		Name:     name,
+		Remote:   config.NewAddress("127.0.0.1:22"),
+		Username: "ec2-user",
+		Identity: identity,
+		JumpHost: jump,
+	}
+}
+
+// Tunnel is a valid local tunnel definition, entering at local and exiting on the local host
+func Tunnel(name string, local string) *config.Tunnel {
+	return &config.Tunnel{
+		Name:   name,
+		Local:  config.NewAddress(local),
+		Remote: config.NewAddress("127.0.0.1:8080"),
+	}
+}
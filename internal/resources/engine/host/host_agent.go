@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package host
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"us.figge.auto-ssh/internal/core/utils"
+)
+
+const (
+	authSockEnv = "SSH_AUTH_SOCK"
+)
+
+// agentClient is a connection to a running ssh-agent, shared by every host that uses
+// the same socket.  The connection is re-established if the agent restarts
+type agentClient struct {
+	lock   sync.Mutex
+	socket string
+	conn   net.Conn
+	client agent.ExtendedAgent
+}
+
+// agentSocket resolves the configured socket, falling back to SSH_AUTH_SOCK
+func agentSocket(socket string) string {
+	path, _ := utils.ExpandHomeE(utils.DefaultString(strings.TrimSpace(socket), os.Getenv(authSockEnv)))
+	return path
+}
+
+func newAgentClient(socket string) (*agentClient, error) {
+	a := &agentClient{socket: socket}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if err := a.connect(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *agentClient) connect() error {
+	if a.conn != nil {
+		_ = a.conn.Close()
+	}
+	conn, err := net.Dial("unix", a.socket)
+	if err != nil {
+		a.conn, a.client = nil, nil
+		return err
+	}
+	a.conn = conn
+	a.client = agent.NewClient(conn)
+	return nil
+}
+
+// Close drops the connection to the agent
+func (a *agentClient) Close() {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.conn != nil {
+		_ = a.conn.Close()
+	}
+	a.conn, a.client = nil, nil
+}
+
+// Signers returns the agent keys matching any of the fingerprints or comments
+func (a *agentClient) Signers(fingerprints []string, comments []string) ([]ssh.Signer, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if a.client == nil {
+		if err := a.connect(); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := a.client.List()
+	if err != nil {
+		// The agent may have restarted, so try a fresh connection once
+		if err = a.connect(); err != nil {
+			return nil, err
+		}
+		if keys, err = a.client.List(); err != nil {
+			return nil, err
+		}
+	}
+	signers, err := a.client.Signers()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []ssh.Signer
+	for _, key := range keys {
+		if !agentKeyMatches(key, fingerprints, comments) {
+			continue
+		}
+		for _, signer := range signers {
+			if bytes.Equal(signer.PublicKey().Marshal(), key.Marshal()) {
+				matched = append(matched, signer)
+				break
+			}
+		}
+	}
+	return matched, nil
+}
+
+func agentKeyMatches(key *agent.Key, fingerprints []string, comments []string) bool {
+	if len(fingerprints) == 0 && len(comments) == 0 {
+		return true
+	}
+	if slices.Contains(comments, key.Comment) {
+		return true
+	}
+	sha256 := ssh.FingerprintSHA256(key)
+	md5 := ssh.FingerprintLegacyMD5(key)
+	for _, fingerprint := range fingerprints {
+		fingerprint = strings.TrimSpace(fingerprint)
+		if fingerprint == sha256 || strings.TrimPrefix(fingerprint, "MD5:") == md5 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,372 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package host
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/utils"
+)
+
+const (
+	secretEnv  = "env:"
+	secretFile = "file:"
+	certSuffix = "-cert.pub"
+)
+
+var (
+	promptedLock sync.RWMutex
+	prompted     = make(map[string]string)
+)
+
+var (
+	errNotCertificate     = errors.New("not an ssh certificate")
+	errNotUserCertificate = errors.New("not a user certificate")
+	errCertificateKey     = errors.New("certificate does not match the identity key")
+)
+
+// validateAuth loads the credentials for each configured authentication method, and
+// returns the methods offered to the server in order.  Keys from every source are
+// offered through a single publickey method, as the ssh client only attempts each
+// method type once
+func (h *Entry) validateAuth(
+	v *config.Validations,
+	identityMap map[string]ssh.Signer,
+	agentMap map[string]*agentClient,
+) []ssh.AuthMethod {
+	auth := h.hostData.Auth
+	if auth == nil {
+		auth = &config.Auth{}
+	}
+	methods := []string{config.AuthPublicKey}
+	if len(auth.Methods) > 0 {
+		methods = make([]string, len(auth.Methods))
+		for i, method := range auth.Methods {
+			methods[i] = strings.ToLower(strings.TrimSpace(method))
+		}
+	}
+
+	var authMethods []ssh.AuthMethod
+	var secret *string
+	for _, method := range methods {
+		switch method {
+		case config.AuthPublicKey:
+			authMethods = append(authMethods, h.validatePublicKey(v, auth, identityMap, agentMap))
+		case config.AuthPassword:
+			if secret == nil {
+				secret = utils.Ptr(h.validateSecret(v, auth, method))
+			}
+			authMethods = append(authMethods, ssh.Password(*secret))
+		case config.AuthKeyboardInteractive:
+			if secret == nil {
+				secret = utils.Ptr(h.validateSecret(v, auth, method))
+			}
+			authMethods = append(authMethods, ssh.KeyboardInteractive(h.challenge(*secret)))
+		default:
+			v.Errorf("host (%s) auth method (%s) is invalid.  Must be one of: %s, %s, %s", h.hostData.Name, method,
+				config.AuthPublicKey, config.AuthPassword, config.AuthKeyboardInteractive)
+			h.valid = false
+		}
+	}
+	if !slices.Contains(methods, config.AuthPublicKey) && h.hostData.Identity != "" {
+		v.Warnf("host (%s) identity file (%s) ignored.  publickey is not an auth method", h.hostData.Name, h.hostData.Identity)
+	}
+	return authMethods
+}
+
+func (h *Entry) validatePublicKey(
+	v *config.Validations,
+	auth *config.Auth,
+	identityMap map[string]ssh.Signer,
+	agentMap map[string]*agentClient,
+) ssh.AuthMethod {
+	h.hostData.Identity = strings.TrimSpace(h.hostData.Identity)
+	if h.hostData.Identity == "" {
+		if auth.Agent == nil {
+			v.Errorf("host (%s) missing identity file", h.hostData.Name)
+			h.valid = false
+		}
+	} else {
+		h.validateIdentity(v, identityMap)
+	}
+
+	var agentSigners func() ([]ssh.Signer, error)
+	if auth.Agent != nil {
+		agentSigners = h.validateAgent(v, auth.Agent, agentMap, h.hostData.Identity == "")
+	}
+
+	identity := h.hostData.Identity
+	certificate := h.validateCertificate(v, auth, identityMap[identity])
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		var signers []ssh.Signer
+		if signer, ok := identityMap[identity]; ok {
+			// Certificates are short-lived and renewed on disk, so are read on each connection
+			if certSigner, err := loadCertSigner(certificate, signer); err == nil {
+				signers = append(signers, certSigner)
+			} else if !os.IsNotExist(err) {
+				fmt.Printf("  Warn  - host (%s) certificate (%s) not offered: %v\n", h.hostData.Name, certificate, err)
+			}
+			signers = append(signers, signer)
+		}
+		if agentSigners != nil {
+			keys, err := agentSigners()
+			if err != nil && len(signers) == 0 {
+				return nil, err
+			}
+			signers = append(signers, keys...)
+		}
+		return signers, nil
+	})
+}
+
+// validateCertificate locates the user certificate for the host's identity.  A missing
+// default certificate is expected, but one that is configured must exist
+func (h *Entry) validateCertificate(v *config.Validations, auth *config.Auth, signer ssh.Signer) string {
+	certificate := strings.TrimSpace(auth.Certificate)
+	if certificate == "" {
+		if h.hostData.Identity == "" {
+			return ""
+		}
+		certificate = h.hostData.Identity + certSuffix
+		if _, err := os.Stat(certificate); os.IsNotExist(err) {
+			return certificate
+		}
+	} else if h.hostData.Identity == "" {
+		v.Errorf("host (%s) certificate (%s) requires an identity file", h.hostData.Name, certificate)
+		h.valid = false
+		return ""
+	}
+	if signer == nil {
+		return certificate
+	}
+
+	cert, err := loadCertificate(certificate, signer)
+	if err != nil {
+		v.Errorf("host (%s) certificate (%s) cannot be used: %v", h.hostData.Name, certificate, err)
+		h.valid = false
+	} else if before := time.Unix(int64(cert.ValidBefore), 0); cert.ValidBefore != ssh.CertTimeInfinity && time.Now().After(before) {
+		v.Warnf("host (%s) certificate (%s) expired at %s", h.hostData.Name, certificate, before.Format(time.DateTime))
+	}
+	return certificate
+}
+
+func loadCertificate(certificate string, signer ssh.Signer) (*ssh.Certificate, error) {
+	bs, err := os.ReadFile(certificate)
+	if err != nil {
+		return nil, err
+	}
+	pk, _, _, _, err := ssh.ParseAuthorizedKey(bs)
+	if err != nil {
+		return nil, err
+	}
+	cert, ok := pk.(*ssh.Certificate)
+	if !ok {
+		return nil, errNotCertificate
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, errNotUserCertificate
+	}
+	if !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+		return nil, errCertificateKey
+	}
+	return cert, nil
+}
+
+func loadCertSigner(certificate string, signer ssh.Signer) (ssh.Signer, error) {
+	if certificate == "" {
+		return nil, os.ErrNotExist
+	}
+	cert, err := loadCertificate(certificate, signer)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewCertSigner(cert, signer)
+}
+
+// validateSecret reads the host's password from its secret source, or from the answer
+// given when auto-ssh started with --prompt.  Validation never prompts itself, as it is
+// also run by the api and reloads, which have no terminal
+func (h *Entry) validateSecret(v *config.Validations, auth *config.Auth, method string) string {
+	source := strings.TrimSpace(auth.Secret)
+	switch {
+	case strings.HasPrefix(source, secretEnv):
+		name := strings.TrimPrefix(source, secretEnv)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			v.Errorf("host (%s) secret environment variable (%s) is not set", h.hostData.Name, name)
+			h.valid = false
+		}
+		return secret
+	case strings.HasPrefix(source, secretFile):
+		filename, _ := utils.ExpandHomeE(strings.TrimPrefix(source, secretFile))
+		bs, err := os.ReadFile(filename)
+		if err != nil {
+			v.Errorf("host (%s) secret file (%s) cannot be read: %v", h.hostData.Name, filename, err)
+			h.valid = false
+		}
+		return strings.TrimRight(string(bs), "\r\n")
+	case source != "":
+		v.Errorf("host (%s) secret must reference %sNAME or %s/path", h.hostData.Name, secretEnv, secretFile)
+		h.valid = false
+	default:
+		if secret, ok := promptedSecret(h.hostData.Host); ok {
+			return secret
+		}
+		v.Errorf("host (%s) %s auth requires a secret, or --prompt when auto-ssh starts", h.hostData.Name, method)
+		h.valid = false
+	}
+	return ""
+}
+
+// challenge answers keyboard-interactive questions.  Hidden questions are assumed to be
+// asking for the password.  Anything else cannot be answered, as connections are made
+// in the background
+func (h *Entry) challenge(secret string) ssh.KeyboardInteractiveChallenge {
+	return func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+		answers := make([]string, len(questions))
+		for i, question := range questions {
+			if echos[i] || secret == "" {
+				return nil, fmt.Errorf("host (%s) keyboard-interactive question cannot be answered: %s", h.hostData.Name, question)
+			}
+			answers[i] = secret
+		}
+		return answers, nil
+	}
+}
+
+// PromptSecrets asks for the password of each host that authenticates with one but has
+// no secret source.  It is called once, as auto-ssh starts with --prompt, and the answers
+// are kept for as long as it runs
+func PromptSecrets(hosts []*config.Host) {
+	for _, cfgHost := range hosts {
+		if !needsSecret(cfgHost) {
+			continue
+		}
+		if _, ok := promptedSecret(cfgHost); ok {
+			continue
+		}
+		secret, ok := utils.Prompt(fmt.Sprintf("%s@%s's password: ", cfgHost.Username, cfgHost.Name), true, true)
+		fmt.Println()
+		if ok {
+			promptedLock.Lock()
+			prompted[secretKey(cfgHost)] = secret
+			promptedLock.Unlock()
+		}
+	}
+}
+
+func needsSecret(cfgHost *config.Host) bool {
+	if cfgHost.Auth == nil || strings.TrimSpace(cfgHost.Auth.Secret) != "" {
+		return false
+	}
+	for _, method := range cfgHost.Auth.Methods {
+		method = strings.ToLower(strings.TrimSpace(method))
+		if method == config.AuthPassword || method == config.AuthKeyboardInteractive {
+			return true
+		}
+	}
+	return false
+}
+
+func promptedSecret(cfgHost *config.Host) (string, bool) {
+	promptedLock.RLock()
+	defer promptedLock.RUnlock()
+	secret, ok := prompted[secretKey(cfgHost)]
+	return secret, ok
+}
+
+// secretKey is the id a host's prompted secret is kept under.  Ids default to the name
+func secretKey(cfgHost *config.Host) string {
+	return utils.DefaultString(strings.TrimSpace(cfgHost.Id), strings.TrimSpace(cfgHost.Name))
+}
+
+func (h *Entry) validateIdentity(v *config.Validations, identityMap map[string]ssh.Signer) {
+	if _, ok := identityMap[h.hostData.Identity]; ok {
+		return
+	}
+	if fi, err := os.Stat(h.hostData.Identity); os.IsNotExist(err) {
+		v.Errorf("host (%s) identity file (%s) cannot be read: file not found", h.hostData.Name, h.hostData.Identity)
+		h.valid = false
+	} else if fi.IsDir() {
+		v.Errorf("host (%s) identity file (%s) cannot be read: file is a directory", h.hostData.Name, h.hostData.Identity)
+		h.valid = false
+	} else {
+		var key []byte
+		key, err = os.ReadFile(h.hostData.Identity)
+		if os.IsPermission(err) {
+			v.Errorf("host (%s) identity file (%s) cannot be read: permission denied", h.hostData.Name, h.hostData.Identity)
+			h.valid = false
+		} else if err != nil {
+			v.Errorf("host (%s) identity file (%s) cannot be read: %v", h.hostData.Name, h.hostData.Identity, err)
+			h.valid = false
+		} else {
+			var signer ssh.Signer
+			h.hostData.Passphrase = strings.TrimSpace(h.hostData.Passphrase)
+			if h.hostData.Passphrase != "" {
+				signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(h.hostData.Passphrase))
+			} else {
+				signer, err = ssh.ParsePrivateKey(key)
+			}
+			if err != nil {
+				v.Errorf("host (%s) identity file (%s) cannot be decode: %v", h.hostData.Name, h.hostData.Identity, err)
+				h.valid = false
+			} else {
+				identityMap[h.hostData.Identity] = signer
+			}
+		}
+	}
+}
+
+// validateAgent connects to the ssh-agent, sharing the connection between hosts using the
+// same socket.  An unavailable agent is only an error when it is the host's sole key source
+func (h *Entry) validateAgent(
+	v *config.Validations,
+	agentConfig *config.Agent,
+	agentMap map[string]*agentClient,
+	required bool,
+) func() ([]ssh.Signer, error) {
+	report := v.Warnf
+	if required {
+		report = func(msg string, args ...any) {
+			v.Errorf(msg, args...)
+			h.valid = false
+		}
+	}
+
+	socket := agentSocket(agentConfig.Socket)
+	if socket == "" {
+		report("host (%s) ssh-agent unavailable: %s is not set", h.hostData.Name, authSockEnv)
+		return nil
+	}
+	client, ok := agentMap[socket]
+	if !ok {
+		var err error
+		if client, err = newAgentClient(socket); err != nil {
+			report("host (%s) ssh-agent (%s) unavailable: %v", h.hostData.Name, socket, err)
+			return nil
+		}
+		agentMap[socket] = client
+	}
+
+	fingerprints, comments := agentConfig.Fingerprints, agentConfig.Comments
+	if signers, err := client.Signers(fingerprints, comments); err != nil {
+		report("host (%s) ssh-agent (%s) keys cannot be listed: %v", h.hostData.Name, socket, err)
+		return nil
+	} else if len(signers) == 0 {
+		v.Warnf("host (%s) ssh-agent (%s) holds no matching keys", h.hostData.Name, socket)
+	}
+	return func() ([]ssh.Signer, error) {
+		return client.Signers(fingerprints, comments)
+	}
+}
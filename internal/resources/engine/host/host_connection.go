@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package host
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/events"
+	"us.figge.auto-ssh/internal/core/utils"
+	"us.figge.auto-ssh/internal/core/utils/backoff"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+)
+
+const (
+	keepAliveRequest  = "keepalive@openssh.com"
+	keepAliveCountMax = 3
+
+	listenProbeTimeout = 5 * time.Second
+)
+
+// keepAlive probes the server until the client is replaced or closed.  Once too many
+// probes in a row go unanswered the client is closed, which is picked up by watch
+func (h *Entry) keepAlive(client *ssh.Client) {
+	keepAlive := h.KeepAlive()
+	if keepAlive == nil || keepAlive.Interval <= 0 {
+		return
+	}
+	interval := time.Duration(keepAlive.Interval) * time.Second
+	countMax := utils.DefaultInt(keepAlive.CountMax, keepAliveCountMax)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	missed := 0
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !h.isClient(client) {
+			return
+		}
+		if sendKeepAlive(client, interval) {
+			missed = 0
+			continue
+		}
+		missed++
+		if config.VerboseFlag {
+			fmt.Printf("  Warn  - host (%s) keepalive %d of %d unanswered\n", h.Name(), missed, countMax)
+		}
+		if missed >= countMax {
+			fmt.Printf("  Error - host (%s) unresponsive after %d keepalives.  Disconnecting\n", h.Name(), missed)
+			_ = client.Close()
+			return
+		}
+	}
+}
+
+// sendKeepAlive reports whether the server answered within the timeout.  Servers that do
+// not recognise the request still reply with a failure, which proves the connection is alive
+func sendKeepAlive(client *ssh.Client, timeout time.Duration) bool {
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest(keepAliveRequest, true, nil)
+		result <- err
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-result:
+		return err == nil
+	case <-timer.C:
+		return false
+	}
+}
+
+// watch waits for the client connection to end, however that happens, and marks the
+// host disconnected if it is still the current client
+func (h *Entry) watch(client *ssh.Client) {
+	_ = client.Wait()
+	h.lock.Lock()
+	current := h.client == client
+	if current {
+		h.client = nil
+	}
+	h.lock.Unlock()
+	if current {
+		fmt.Printf("  Info  - host (%s) disconnected\n", h.Name())
+		h.notify(false)
+		if h.ctx.Err() == nil && h.hasListeners() {
+			go h.reconnect()
+		}
+	}
+}
+
+// reconnect re-establishes a connection that was lost, or never made, in the background,
+// backing off between attempts, so the tunnels using the host find it ready for their
+// next connection.  Hosts no tunnel follows are left until something opens them
+func (h *Entry) reconnect() {
+	h.listenerLock.Lock()
+	if h.reconnecting || len(h.listeners) == 0 {
+		h.listenerLock.Unlock()
+		return
+	}
+	h.reconnecting = true
+	h.listenerLock.Unlock()
+	defer func() {
+		h.listenerLock.Lock()
+		h.reconnecting = false
+		h.attempts = 0
+		h.listenerLock.Unlock()
+	}()
+
+	retry := backoff.NewBackoff(backoff.OptionRetry(h.Retry()))
+	for {
+		delay, ok := retry.Next()
+		if !ok {
+			fmt.Printf("  Error - host (%s) giving up reconnecting after %d attempts\n", h.Name(), retry.Attempts())
+			return
+		}
+		fmt.Printf("  Info  - host (%s) reconnect %d in %s\n", h.Name(), retry.Attempts(), delay.Round(time.Millisecond))
+		h.reconnects.Add(1)
+		h.listenerLock.Lock()
+		h.attempts, h.nextRetry = retry.Attempts(), time.Now().Add(delay)
+		h.listenerLock.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-h.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if h.Connected() || h.isRemoved() {
+			return
+		}
+		h.lock.Lock()
+		opened := h.open()
+		h.lock.Unlock()
+		if opened {
+			fmt.Printf("  Info  - host (%s) reconnected\n", h.Name())
+			return
+		}
+	}
+}
+
+func (h *Entry) isRemoved() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.removed
+}
+
+func (h *Entry) isClient(client *ssh.Client) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.client == client
+}
+
+func (h *Entry) AddListener(id string, listener engineModels.HostListener) {
+	h.listenerLock.Lock()
+	defer h.listenerLock.Unlock()
+	if h.listeners == nil {
+		h.listeners = make(map[string]engineModels.HostListener)
+	}
+	h.listeners[id] = listener
+}
+
+func (h *Entry) RemoveListener(id string) {
+	h.listenerLock.Lock()
+	defer h.listenerLock.Unlock()
+	delete(h.listeners, id)
+}
+
+func (h *Entry) hasListeners() bool {
+	h.listenerLock.Lock()
+	defer h.listenerLock.Unlock()
+	return len(h.listeners) > 0
+}
+
+func (h *Entry) notify(connected bool) {
+	event := &events.Event{Type: events.HostDisconnected, Host: h.Id(), Tags: events.Tags(h.Metadata())}
+	if connected {
+		event.Type = events.HostConnected
+	}
+	events.Publish(event)
+	h.tell(connected)
+}
+
+// tell passes a change in the host's connection on to the tunnels following it
+func (h *Entry) tell(connected bool) {
+	h.listenerLock.Lock()
+	listeners := make([]engineModels.HostListener, 0, len(h.listeners))
+	for _, listener := range h.listeners {
+		listeners = append(listeners, listener)
+	}
+	h.listenerLock.Unlock()
+	for _, listener := range listeners {
+		listener(h, connected)
+	}
+}
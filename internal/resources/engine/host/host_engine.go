@@ -8,43 +8,305 @@ import (
 	"context"
 	"fmt"
 	"slices"
+	"strings"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/events"
+	"us.figge.auto-ssh/internal/core/utils"
 	engineModels "us.figge.auto-ssh/internal/resources/models"
 )
 
 type Engine struct {
-	hostEntries map[string]*Entry
-	identityMap map[string]ssh.Signer
-	hostKeysMap map[string]*HostKeyManager
+	ctx          context.Context
+	lock         sync.RWMutex
+	hostEntries  map[string]*Entry
+	validateLock sync.Mutex
+	identityMap  map[string]ssh.Signer
+	hostKeysMap  map[string]*HostKeyManager
+	agentMap     map[string]*agentClient
 }
 
 func NewEngine(ctx context.Context, hosts []*config.Host) *Engine {
+	engine, v := ValidateHosts(ctx, hosts)
+	_ = v.Output(nil)
+	return engine
+}
+
+// ValidateHosts checks a set of host definitions as a whole, including the jump chains
+// between them, without connecting to any.  The engine returned can validate tunnels
+func ValidateHosts(ctx context.Context, hosts []*config.Host) (*Engine, config.Validations) {
 	engine := &Engine{
+		ctx:         ctx,
 		hostEntries: make(map[string]*Entry),
 		identityMap: make(map[string]ssh.Signer),
 		hostKeysMap: make(map[string]*HostKeyManager),
+		agentMap:    make(map[string]*agentClient),
 	}
+	v := config.NewValidations()
 	for _, cfgHost := range hosts {
-		if _, ok := engine.hostEntries[cfgHost.Name]; ok {
-			fmt.Printf("  Error - host name (%s) redfined\n", cfgHost.Name)
+		host := engine.newEntry(cfgHost)
+		if engine.exists(cfgHost, nil) != nil {
+			v.Errorf("host name (%s) redfined", cfgHost.Name)
 			continue
 		}
-		host := &Entry{
-			hostData: &hostData{
-				Host:  cfgHost,
-				valid: true,
-				inUse: false,
-			},
-		}
-		host.Validate("", engine.identityMap, engine.hostKeysMap)
+		host.Validate(&v, "", engine.identityMap, engine.hostKeysMap, engine.agentMap)
 		engine.hostEntries[cfgHost.Id] = host
 	}
-	return engine
+	engine.resolveJumpHosts(&v)
+	return engine, v
+}
+
+// Close releases the ssh-agent connections opened while validating.  Engines returned
+// by ValidateHosts only to check a configuration are closed once checked
+func (he *Engine) Close() {
+	he.validateLock.Lock()
+	defer he.validateLock.Unlock()
+	for socket, client := range he.agentMap {
+		client.Close()
+		delete(he.agentMap, socket)
+	}
+}
+
+// newEntry wraps a host definition, defaulting its id to its name.  A copy is kept as
+// configured, since validation fills in defaults that have no place in a saved configuration
+func (he *Engine) newEntry(cfgHost *config.Host) *Entry {
+	configured := *cfgHost
+	cfgHost.Id = utils.DefaultString(strings.TrimSpace(cfgHost.Id), strings.TrimSpace(cfgHost.Name))
+	return &Entry{
+		hostData: &hostData{
+			Host:       cfgHost,
+			configured: &configured,
+			ctx:        he.ctx,
+			valid:      true,
+			inUse:      false,
+		},
+	}
+}
+
+// resolveJumpHosts links each host to the host it jumps through.  Chains may be of any
+// depth, but cannot loop back on themselves, and are only valid if every hop is valid
+func (he *Engine) resolveJumpHosts(v *config.Validations) {
+	for _, entry := range he.hostEntries {
+		if entry.hostData.JumpHost == "" {
+			continue
+		}
+		jump, ok := he.lookup(entry.hostData.JumpHost)
+		if !ok {
+			v.Errorf("host (%s) jump host (%s) undefined", entry.hostData.Name, entry.hostData.JumpHost)
+			entry.valid = false
+			continue
+		}
+		entry.jump = jump
+		jump.isJumpHost = true
+	}
+
+	circular := make(map[*Entry]bool)
+	for _, entry := range he.hostEntries {
+		visited := []*Entry{entry}
+		for hop := entry.jump; hop != nil; hop = hop.jump {
+			if slices.Contains(visited, hop) {
+				names := make([]string, 0, len(visited)+1)
+				for _, v := range visited {
+					names = append(names, v.hostData.Name)
+				}
+				names = append(names, hop.hostData.Name)
+				v.Errorf("host (%s) jump chain is circular: %s", entry.hostData.Name, strings.Join(names, " -> "))
+				circular[entry] = true
+				break
+			}
+			visited = append(visited, hop)
+		}
+	}
+	for entry := range circular {
+		entry.valid = false
+	}
+
+	for _, entry := range he.hostEntries {
+		if circular[entry] {
+			continue
+		}
+		for hop := entry.jump; hop != nil; hop = hop.jump {
+			if !hop.valid {
+				v.Errorf("host (%s) jump host (%s) is invalid", entry.hostData.Name, hop.hostData.Name)
+				entry.valid = false
+				break
+			}
+		}
+	}
+}
+
+// linkJump resolves the jump host of a single host against those already defined.  self
+// is the entry being replaced, if any, so a chain leading back to it is seen as circular
+func (he *Engine) linkJump(v *config.Validations, entry *Entry, self *Entry) {
+	entry.jump = nil
+	if entry.hostData.JumpHost == "" {
+		return
+	}
+	jump, ok := he.lookup(entry.hostData.JumpHost)
+	if !ok {
+		v.Errorf("host (%s) jump host (%s) undefined", entry.hostData.Name, entry.hostData.JumpHost)
+		entry.valid = false
+		return
+	}
+	for hop := jump; hop != nil; hop = hop.jump {
+		if hop == self || hop == entry {
+			v.Errorf("host (%s) jump chain is circular through %s", entry.hostData.Name, hop.hostData.Name)
+			entry.valid = false
+			return
+		}
+		if !hop.valid {
+			v.Errorf("host (%s) jump host (%s) is invalid", entry.hostData.Name, hop.hostData.Name)
+			entry.valid = false
+			return
+		}
+	}
+	entry.jump = jump
+	jump.isJumpHost = true
+}
+
+// AddHost validates a new host and adds it.  Nothing changes if it is invalid.  The id
+// defaults to the host's name
+func (he *Engine) AddHost(cfgHost *config.Host) (engineModels.Host, error) {
+	entry := he.newEntry(cfgHost)
+	he.lock.RLock()
+	err := he.exists(cfgHost, nil)
+	he.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	v := config.NewValidations()
+	he.validate(&v, entry)
+
+	he.lock.Lock()
+	defer he.lock.Unlock()
+	if err = he.exists(cfgHost, nil); err != nil {
+		return nil, err
+	}
+	he.linkJump(&v, entry, nil)
+	_ = v.Output(nil)
+	if err = v.Err(engineModels.ErrInvalid); err != nil {
+		events.Publish(&events.Event{Type: events.ValidationError, Host: cfgHost.Id, Tags: events.Tags(cfgHost.Metadata), Error: err.Error()})
+		return nil, err
+	}
+	he.hostEntries[cfgHost.Id] = entry
+	return entry, nil
+}
+
+// UpdateHost validates a replacement configuration for a host, then applies it to the
+// existing entry so the tunnels and hosts using it follow the change.  Nothing changes
+// if the replacement is invalid
+func (he *Engine) UpdateHost(id string, cfgHost *config.Host) (engineModels.Host, error) {
+	cfgHost.Id = id
+	he.lock.RLock()
+	entry, ok := he.hostEntries[id]
+	err := he.exists(cfgHost, entry)
+	he.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: host (%s)", engineModels.ErrNotFound, id)
+	} else if err != nil {
+		return nil, err
+	}
+
+	v := config.NewValidations()
+	replacement := he.newEntry(cfgHost)
+	entry.configLock.RLock()
+	// The id cannot change, so it stays however it was configured
+	replacement.configured.Id = entry.configured.Id
+	entry.configLock.RUnlock()
+	he.validate(&v, replacement)
+
+	he.lock.Lock()
+	defer he.lock.Unlock()
+	if he.hostEntries[id] != entry {
+		// Removed, and perhaps added again, while the replacement was validated
+		return nil, fmt.Errorf("%w: host (%s)", engineModels.ErrNotFound, id)
+	}
+	if err = he.exists(cfgHost, entry); err != nil {
+		return nil, err
+	}
+	he.linkJump(&v, replacement, entry)
+	_ = v.Output(nil)
+	if err = v.Err(engineModels.ErrInvalid); err != nil {
+		events.Publish(&events.Event{Type: events.ValidationError, Host: cfgHost.Id, Tags: events.Tags(cfgHost.Metadata), Error: err.Error()})
+		return nil, err
+	}
+	entry.apply(replacement)
+	return entry, nil
+}
+
+// exists reports an error when another host than self already uses the id or name of cfgHost
+func (he *Engine) exists(cfgHost *config.Host, self *Entry) error {
+	for _, ref := range []string{cfgHost.Id, strings.TrimSpace(cfgHost.Name)} {
+		if other, ok := he.lookup(ref); ok && other != self {
+			return fmt.Errorf("%w: host (%s)", engineModels.ErrExists, ref)
+		}
+	}
+	return nil
+}
+
+// validate checks a host on its own, reading its keys and known_hosts file.  That is done
+// outside the engine's lock, so only the caches the checks share are held
+func (he *Engine) validate(v *config.Validations, entry *Entry) {
+	he.validateLock.Lock()
+	defer he.validateLock.Unlock()
+	entry.Validate(v, "", he.identityMap, he.hostKeysMap, he.agentMap)
+}
+
+// RemoveHost removes a host, returning whatever still used it.  A host in use by tunnels
+// or other hosts is only removed when forced, after which it can no longer be opened and
+// the tunnels that used it are stopped
+func (he *Engine) RemoveHost(id string, force bool) ([]string, error) {
+	he.lock.Lock()
+	defer he.lock.Unlock()
+	entry, ok := he.hostEntries[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: host (%s)", engineModels.ErrNotFound, id)
+	}
+	references := he.references(entry)
+	if len(references) > 0 && !force {
+		return references, fmt.Errorf("%w: host (%s) used by %s", engineModels.ErrInUse, id, strings.Join(references, ", "))
+	}
+	delete(he.hostEntries, id)
+	entry.remove()
+	return references, nil
+}
+
+// references lists the tunnels and hosts that depend on a host
+func (he *Engine) references(entry *Entry) []string {
+	var references []string
+	entry.listenerLock.Lock()
+	for id := range entry.listeners {
+		references = append(references, fmt.Sprintf("tunnel (%s)", id))
+	}
+	entry.listenerLock.Unlock()
+	for _, other := range he.hostEntries {
+		if other.jump == entry {
+			references = append(references, fmt.Sprintf("host (%s)", other.hostData.Name))
+		}
+	}
+	slices.Sort(references)
+	return references
+}
+
+// lookup finds a host by id, falling back to its name
+func (he *Engine) lookup(ref string) (*Entry, bool) {
+	if entry, ok := he.hostEntries[ref]; ok {
+		return entry, true
+	}
+	for _, entry := range he.hostEntries {
+		if entry.hostData.Name == ref {
+			return entry, true
+		}
+	}
+	return nil, false
 }
 
 func (he *Engine) Hosts() []engineModels.Host {
+	he.lock.RLock()
+	defer he.lock.RUnlock()
 	hosts := make([]engineModels.Host, 0, len(he.hostEntries))
 	for _, hostEntry := range he.hostEntries {
 		hosts = append(hosts, hostEntry)
@@ -52,12 +314,45 @@ func (he *Engine) Hosts() []engineModels.Host {
 	return hosts
 }
 
+// Configs returns a copy of the configuration of every host, ordered by id
+func (he *Engine) Configs() []*config.Host {
+	he.lock.RLock()
+	defer he.lock.RUnlock()
+	hosts := make([]*config.Host, 0, len(he.hostEntries))
+	for _, hostEntry := range he.hostEntries {
+		copied := *hostEntry.current()
+		hosts = append(hosts, &copied)
+	}
+	slices.SortFunc(hosts, func(a, b *config.Host) int { return strings.Compare(a.Id, b.Id) })
+	return hosts
+}
+
+// Configured returns a copy of every host as it was configured, before validation filled
+// in its defaults, ordered by id
+func (he *Engine) Configured() []*config.Host {
+	he.lock.RLock()
+	defer he.lock.RUnlock()
+	hosts := make([]*config.Host, 0, len(he.hostEntries))
+	for _, hostEntry := range he.hostEntries {
+		hostEntry.configLock.RLock()
+		copied := *hostEntry.configured
+		hostEntry.configLock.RUnlock()
+		hosts = append(hosts, &copied)
+	}
+	slices.SortFunc(hosts, func(a, b *config.Host) int { return strings.Compare(a.Id, b.Id) })
+	return hosts
+}
+
 func (he *Engine) Host(id string) (engineModels.Host, bool) {
+	he.lock.RLock()
+	defer he.lock.RUnlock()
 	host, ok := he.hostEntries[id]
 	return host, ok
 }
 
 func (he *Engine) KnownHosts() []string {
+	he.lock.RLock()
+	defer he.lock.RUnlock()
 	knownHosts := make([]string, 0)
 	for _, hostEntry := range he.hostEntries {
 		if hostEntry.hostData.KnownHosts != "" && !slices.Contains(knownHosts, hostEntry.hostData.KnownHosts) {
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package host
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh/agent"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/resources/engine/enginetest"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+)
+
+func TestEngineMutations(t *testing.T) {
+	identity := enginetest.Identity(t)
+	newHost := func(id, name, jump string) *config.Host {
+		return enginetest.Host(identity, id, name, jump)
+	}
+	engine := NewEngine(context.Background(), []*config.Host{newHost("bastion", "Bastion", "")})
+
+	_, err := engine.AddHost(newHost("", "Bastion", ""))
+	assert.ErrorIs(t, err, engineModels.ErrExists)
+	_, err = engine.AddHost(newHost("db", "Database", "missing"))
+	assert.ErrorIs(t, err, engineModels.ErrInvalid)
+	_, ok := engine.Host("db")
+	assert.False(t, ok)
+
+	added, err := engine.AddHost(newHost("", "Database", "Bastion"))
+	require.NoError(t, err)
+	assert.Equal(t, "Database", added.Id())
+
+	_, err = engine.UpdateHost("bastion", newHost("", "Bastion", "Database"))
+	assert.ErrorIs(t, err, engineModels.ErrInvalid)
+	updated, err := engine.UpdateHost("bastion", newHost("", "Bastion 2", ""))
+	require.NoError(t, err)
+	assert.Equal(t, "Bastion 2", updated.Name())
+	_, err = engine.UpdateHost("missing", newHost("", "Missing", ""))
+	assert.ErrorIs(t, err, engineModels.ErrNotFound)
+
+	references, err := engine.RemoveHost("bastion", false)
+	assert.ErrorIs(t, err, engineModels.ErrInUse)
+	assert.Equal(t, []string{"host (Database)"}, references)
+	_, ok = engine.Host("bastion")
+	assert.True(t, ok)
+
+	_, err = engine.RemoveHost("bastion", true)
+	require.NoError(t, err)
+	_, ok = engine.Host("bastion")
+	assert.False(t, ok)
+	assert.False(t, updated.(*Entry).Open())
+}
+
+func TestCloseReleasesAgents(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	served := make(chan struct{})
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_ = agent.ServeAgent(agent.NewKeyring(), conn)
+		close(served)
+	}()
+
+	cfgHost := enginetest.Host("", "", "Agent", "")
+	cfgHost.Auth = &config.Auth{Agent: &config.Agent{Socket: socket}}
+	engine, v := ValidateHosts(context.Background(), []*config.Host{cfgHost})
+	require.NoError(t, v.Err(nil))
+	require.Len(t, engine.agentMap, 1)
+
+	engine.Close()
+	assert.Empty(t, engine.agentMap)
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent connection not closed")
+	}
+}
+
+func TestPromptedSecret(t *testing.T) {
+	newHost := func() *config.Host {
+		return &config.Host{
+			Name:     "Password",
+			Remote:   config.NewAddress("127.0.0.1:22"),
+			Username: "ec2-user",
+			Auth:     &config.Auth{Methods: []string{config.AuthPassword}},
+		}
+	}
+	engine := NewEngine(context.Background(), nil)
+	_, err := engine.AddHost(newHost())
+	assert.ErrorIs(t, err, engineModels.ErrInvalid)
+
+	promptedLock.Lock()
+	prompted["Password"] = "secret"
+	promptedLock.Unlock()
+	defer func() {
+		promptedLock.Lock()
+		delete(prompted, "Password")
+		promptedLock.Unlock()
+	}()
+	_, err = engine.AddHost(newHost())
+	assert.NoError(t, err)
+}
@@ -5,59 +5,127 @@
 package host
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"os"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 	"us.figge.auto-ssh/internal/core/config"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
 )
 
 type hostData struct {
 	*config.Host
+	configured *config.Host
+	ctx        context.Context
+	configLock sync.RWMutex
 	lock       sync.Mutex
 	valid      bool
 	inUse      bool
 	referenced bool
 	isJumpHost bool
+	jump       *Entry
 	client     *ssh.Client
 	config     *ssh.ClientConfig
+	lastError  string
+
+	listenerLock sync.Mutex
+	listeners    map[string]engineModels.HostListener
+	reconnecting bool
+	attempts     int
+	nextRetry    time.Time
+	removed      bool
+
+	failures   atomic.Int64
+	reconnects atomic.Int64
 }
 type Entry struct {
 	*hostData
 }
 
+// current is the configuration the host runs with.  Updates replace it, so anything
+// reading it outside the engine's lock goes through here
+func (h *Entry) current() *config.Host {
+	h.configLock.RLock()
+	defer h.configLock.RUnlock()
+	return h.hostData.Host
+}
+
 func (h *Entry) Id() string {
-	return h.hostData.Id
+	return h.current().Id
 }
 func (h *Entry) Name() string {
-	return h.hostData.Name
+	return h.current().Name
 }
 func (h *Entry) Remote() *config.Address {
-	return h.hostData.Remote
+	return h.current().Remote
 }
 func (h *Entry) Username() string {
-	return h.hostData.Username
+	return h.current().Username
 }
 func (h *Entry) Passphrase() string {
-	return h.hostData.Passphrase
+	return h.current().Passphrase
 }
 func (h *Entry) Identity() string {
-	return h.hostData.Identity
+	return h.current().Identity
 }
 func (h *Entry) KnownHosts() string {
-	return h.hostData.KnownHosts
+	return h.current().KnownHosts
 }
 func (h *Entry) JumpHost() string {
-	return h.hostData.JumpHost
+	return h.current().JumpHost
+}
+func (h *Entry) Auth() *config.Auth {
+	return h.current().Auth
+}
+func (h *Entry) KeepAlive() *config.KeepAlive {
+	return h.current().KeepAlive
+}
+func (h *Entry) Retry() *config.Retry {
+	return h.current().Retry
 }
 func (h *Entry) Valid() bool {
+	h.configLock.RLock()
+	defer h.configLock.RUnlock()
 	return h.hostData.valid
 }
+func (h *Entry) Connected() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.client != nil
+}
 func (h *Entry) Metadata() *config.Metadata {
-	return h.hostData.Metadata
+	return h.current().Metadata
+}
+func (h *Entry) Stats() engineModels.HostStats {
+	return engineModels.HostStats{
+		Failures:   h.failures.Load(),
+		Reconnects: h.reconnects.Load(),
+	}
+}
+
+// Status reports whether the host is connected and, while it is being reconnected, the
+// attempts made so far and when the next one is due
+func (h *Entry) Status() *config.HostStatus {
+	h.lock.Lock()
+	status := &config.HostStatus{Connected: h.client != nil}
+	if h.client == nil {
+		status.LastError = h.lastError
+	}
+	h.lock.Unlock()
+	h.listenerLock.Lock()
+	defer h.listenerLock.Unlock()
+	if h.reconnecting && h.attempts > 0 {
+		next := h.nextRetry
+		status.Attempts, status.NextRetry = h.attempts, &next
+	}
+	return status
 }
 func (h *Entry) Referenced() {
 	h.referenced = true
@@ -70,23 +138,119 @@ func (h *Entry) Referenced() {
 //	return h.hostData.inUse
 //}
 
+// Open connects to the host if it is not already connected.  A host that cannot be reached
+// is handed to the same supervisor that re-establishes lost connections
 func (h *Entry) Open() bool {
 	h.lock.Lock()
-	defer h.lock.Unlock()
-	return h.open()
+	ok := h.open()
+	removed := h.removed
+	h.lock.Unlock()
+	if !ok && !removed && h.ctx.Err() == nil {
+		go h.reconnect()
+	}
+	return ok
 }
 func (h *Entry) open() bool {
+	if h.removed {
+		fmt.Printf("  Error - host (%s) has been removed\n", h.hostData.Name)
+		return false
+	}
 	if h.client == nil {
 		var err error
-		h.client, err = ssh.Dial("tcp", h.hostData.Remote.String(), h.config)
+		if h.jump != nil {
+			h.client, err = h.jump.dialClient(h.hostData.Remote.String(), h.config)
+		} else {
+			h.client, err = ssh.Dial("tcp", h.hostData.Remote.String(), h.config)
+		}
 		if err != nil {
 			fmt.Printf("  Error - failed to connect to remote address: %v\n", err)
+			h.failures.Add(1)
+			h.lastError = err.Error()
 			return false
 		}
+		h.lastError = ""
+		go h.watch(h.client)
+		go h.keepAlive(h.client)
+		go h.notify(true)
 	}
 	return true
 }
 
+// apply takes on the configuration of a validated replacement.  The connection is only
+// dropped when something it was established with has changed
+func (h *Entry) apply(replacement *Entry) {
+	h.lock.Lock()
+	changed := h.jump != replacement.jump || connectionChanged(h.hostData.Host, replacement.hostData.Host)
+	h.configLock.Lock()
+	h.hostData.Host = replacement.hostData.Host
+	h.configured = replacement.configured
+	h.valid = replacement.valid
+	h.configLock.Unlock()
+	h.config = replacement.config
+	h.jump = replacement.jump
+	client := h.client
+	if changed {
+		h.client = nil
+	}
+	h.lock.Unlock()
+
+	if changed && client != nil {
+		fmt.Printf("  Info  - host (%s) configuration changed.  Reconnecting\n", h.Name())
+		_ = client.Close()
+		h.notify(false)
+		if h.hasListeners() {
+			go h.reconnect()
+		}
+	}
+}
+
+// connectionChanged reports whether two configurations of a host connect differently
+func connectionChanged(current *config.Host, replacement *config.Host) bool {
+	a, b := *current, *replacement
+	a.Name, b.Name = "", ""
+	a.Retry, b.Retry = nil, nil
+	a.Metadata, b.Metadata = nil, nil
+	a.Status, b.Status = nil, nil
+	a.Source, b.Source = "", ""
+	return !reflect.DeepEqual(a, b)
+}
+
+func (h *Entry) remove() {
+	h.lock.Lock()
+	h.removed = true
+	h.configLock.Lock()
+	h.valid = false
+	h.configLock.Unlock()
+	client := h.client
+	h.client = nil
+	h.lock.Unlock()
+	if client != nil {
+		_ = client.Close()
+		h.notify(false)
+	} else {
+		// Tunnels still following the host learn it is gone even if it never connected
+		h.tell(false)
+	}
+}
+
+// dialClient opens an ssh client to address, tunnelled through this host.  Host keys
+// of the new connection are verified by the callback in clientConfig
+func (h *Entry) dialClient(address string, clientConfig *ssh.ClientConfig) (*ssh.Client, error) {
+	if !h.Open() {
+		return nil, fmt.Errorf("jump host (%s) cannot be reached", h.Name())
+	}
+	conn, ok := h.Dial(address)
+	if !ok {
+		return nil, fmt.Errorf("jump host (%s) cannot reach %s", h.Name(), address)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, address, clientConfig)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
 func (h *Entry) Dial(address string) (net.Conn, bool) {
 	h.lock.Lock()
 	defer h.lock.Unlock()
@@ -105,48 +269,84 @@ func (h *Entry) redial(address string, redialing bool) (net.Conn, bool) {
 				return nil, false
 			}
 		}
-		fmt.Printf("  Error - Host (%s) failed to call forward address: %v\n", h.hostData.Name, err)
+		fmt.Printf("  Error - Host (%s) failed to call forward address: %v\n", h.Name(), err)
 		return nil, false
 	}
 	return conn, true
 }
 
+func (h *Entry) Listen(address string) (net.Listener, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if !h.open() {
+		return nil, false
+	}
+	return h.relisten(address, false)
+}
+
+// relisten asks the server to forward address.  A refusal on a connection that still
+// answers, such as a port already in use, fails only this forward.  Otherwise the
+// connection is replaced and the forward tried once more
+func (h *Entry) relisten(address string, relistening bool) (net.Listener, bool) {
+	ln, err := h.client.Listen("tcp", address)
+	if err != nil && sendKeepAlive(h.client, listenProbeTimeout) {
+		fmt.Printf("  Error - Host (%s) failed to listen on remote address %s: %v\n", h.Name(), address, err)
+		return nil, false
+	}
+	if err != nil {
+		_ = h.client.Close()
+		h.client = nil
+		if !relistening {
+			if h.open() {
+				return h.relisten(address, true)
+			} else {
+				return nil, false
+			}
+		}
+		fmt.Printf("  Error - Host (%s) failed to listen on remote address %s: %v\n", h.Name(), address, err)
+		return nil, false
+	}
+	return ln, true
+}
+
 func (h *Entry) Validate(
+	v *config.Validations,
 	defaultUsername string,
 	identityMap map[string]ssh.Signer,
 	hostKeysMap map[string]*HostKeyManager,
+	agentMap map[string]*agentClient,
 ) bool {
 	warning := false
 	h.hostData.Name = strings.TrimSpace(h.hostData.Name)
 	if h.hostData.Name == "" {
-		fmt.Printf("  Error - host name cannot be blank\n")
+		v.Errorf("host name cannot be blank")
 		h.valid = false
 	}
 
 	h.hostData.Username = strings.TrimSpace(h.hostData.Username)
-	if strings.TrimSpace(h.hostData.Username) == "" && config.VerboseFlag {
-		fmt.Printf("  Info  - host (%s) will use default username: %s\n", h.hostData.Name, defaultUsername)
+	if h.hostData.Username == "" {
+		v.Infof("host (%s) will use default username: %s", h.hostData.Name, defaultUsername)
 		h.hostData.Username = defaultUsername
 	}
 
 	h.hostData.KnownHosts = strings.TrimSpace(h.hostData.KnownHosts)
 	if h.hostData.KnownHosts == "" {
-		fmt.Printf("  Warn  - host (%s) not using a known_hosts file\n", h.hostData.Name)
+		fmt.Printf("  Warn  - host (%s) not using a known_hosts file\n", h.Name())
 		warning = true
 	} else if _, ok := hostKeysMap[h.hostData.KnownHosts]; !ok {
 		if fi, err := os.Stat(h.hostData.KnownHosts); os.IsNotExist(err) {
-			fmt.Printf("  Error - host (%s) known_hosts file (%s) cannot be read: file not found\n", h.hostData.Name, h.hostData.KnownHosts)
+			v.Errorf("host (%s) known_hosts file (%s) cannot be read: file not found", h.hostData.Name, h.hostData.KnownHosts)
 			h.valid = false
 		} else if fi.IsDir() {
-			fmt.Printf("  Error - host (%s) known_hosts file (%s) cannot be read: file is a directory\n", h.hostData.Name, h.hostData.KnownHosts)
+			v.Errorf("host (%s) known_hosts file (%s) cannot be read: file is a directory", h.hostData.Name, h.hostData.KnownHosts)
 			h.valid = false
 		} else {
 			var hkManager *HostKeyManager
 			if hkManager, err = NewHostKeyManager(h.hostData.KnownHosts); os.IsPermission(err) {
-				fmt.Printf("  Error - host (%s) known_hosts file (%s) cannot be read: permission denied\n", h.hostData.Name, h.hostData.KnownHosts)
+				v.Errorf("host (%s) known_hosts file (%s) cannot be read: permission denied", h.hostData.Name, h.hostData.KnownHosts)
 				h.valid = false
 			} else if err != nil {
-				fmt.Printf("  Error - host (%s) known_hosts file (%s) cannot be read: %v\n", h.hostData.Name, h.hostData.KnownHosts, err)
+				v.Errorf("host (%s) known_hosts file (%s) cannot be read: %v", h.hostData.Name, h.hostData.KnownHosts, err)
 				h.valid = false
 			} else {
 				hostKeysMap[h.hostData.KnownHosts] = hkManager
@@ -154,70 +354,34 @@ func (h *Entry) Validate(
 		}
 	}
 
-	h.hostData.Identity = strings.TrimSpace(h.hostData.Identity)
-	if h.hostData.Identity == "" {
-		fmt.Printf("  Error - host (%s) missing identity file\n", h.hostData.Name)
-		h.valid = false
-	}
-	if _, ok := identityMap[h.hostData.Identity]; !ok {
-		if fi, err := os.Stat(h.hostData.Identity); os.IsNotExist(err) {
-			fmt.Printf("  Error - host (%s) identity file (%s) cannot be read: file not found\n", h.hostData.Name, h.hostData.Identity)
-			h.valid = false
-		} else if fi.IsDir() {
-			fmt.Printf("  Error - host (%s) identity file (%s) cannot be read: file is a directory\n", h.hostData.Name, h.hostData.Identity)
-			h.valid = false
-		} else {
-			var key []byte
-			key, err = os.ReadFile(h.hostData.Identity)
-			if os.IsPermission(err) {
-				fmt.Printf("  Error - host (%s) identity file (%s) cannot be read: permission denied\n", h.hostData.Name, h.hostData.Identity)
-				h.valid = false
-			} else if err != nil {
-				fmt.Printf("  Error - host (%s) identity file (%s) cannot be read: %v\n", h.hostData.Name, h.hostData.Identity, err)
-				h.valid = false
-			} else {
-				var signer ssh.Signer
-				h.hostData.Passphrase = strings.TrimSpace(h.hostData.Passphrase)
-				if h.hostData.Passphrase != "" {
-					signer, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(h.hostData.Passphrase))
-				} else {
-					signer, err = ssh.ParsePrivateKey(key)
-				}
-				if err != nil {
-					fmt.Printf("  Error - host (%s) identity file (%s) cannot be decode: %v\n", h.hostData.Name, h.hostData.Identity, err)
-					h.valid = false
-				} else {
-					identityMap[h.hostData.Identity] = signer
-				}
-			}
-		}
-	}
+	authMethods := h.validateAuth(v, identityMap, agentMap)
 
 	if h.hostData.Remote == nil || h.hostData.Remote.IsBlank() {
-		fmt.Printf("  Error - host (%s) requires an address\n", h.hostData.Name)
+		v.Errorf("host (%s) requires an address", h.hostData.Name)
 		h.valid = false
 	} else if !h.hostData.Remote.Validate("host", h.hostData.Name, "address", h.hostData.JumpHost != "", true) {
+		v.Errorf("host (%s) address (%s) is invalid", h.hostData.Name, h.hostData.Remote.Configured())
 		h.valid = false
 	}
 
-	if h.hostData.JumpHost != "" {
-		if h.hostData.JumpHost == h.hostData.Name {
-			fmt.Printf("  Error - host (%s) jump_host cannot reference itself\n", h.hostData.Name)
-			h.valid = false
-		} else {
-			h.hostData.KnownHosts = ""
-		}
+	h.hostData.JumpHost = strings.TrimSpace(h.hostData.JumpHost)
+	if h.hostData.JumpHost != "" && (h.hostData.JumpHost == h.hostData.Name || h.hostData.JumpHost == h.hostData.Id) {
+		v.Errorf("host (%s) jump_host cannot reference itself", h.hostData.Name)
+		h.valid = false
+	}
+
+	hostKeys, ok := hostKeysMap[h.hostData.KnownHosts]
+	if !ok {
+		hostKeys = InsecureHostKey
 	}
 	h.config = &ssh.ClientConfig{
-		User: h.hostData.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.PublicKeys(identityMap[h.hostData.Identity]),
-		},
-		HostKeyCallback: hostKeysMap[h.hostData.KnownHosts].Callback,
+		User:            h.hostData.Username,
+		Auth:            authMethods,
+		HostKeyCallback: hostKeys.Callback,
 	}
 
-	if config.VerboseFlag && h.valid && !warning {
-		fmt.Printf("  Info  - host (%s) validated\n", h.hostData.Name)
+	if h.valid && !warning {
+		v.Infof("host (%s) validated", h.hostData.Name)
 	}
 	return h.valid
 }
@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package host
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"us.figge.auto-ssh/internal/core/config"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+)
+
+// testClient connects to an in memory server that refuses every forward and channel, but
+// answers every other request
+func testClient(t *testing.T) *ssh.Client {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	serverConfig := &ssh.ServerConfig{NoClientAuth: true}
+	serverConfig.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		serverConn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_, chans, reqs, err := ssh.NewServerConn(serverConn, serverConfig)
+		if err != nil {
+			return
+		}
+		go func() {
+			for newChannel := range chans {
+				_ = newChannel.Reject(ssh.Prohibited, "refused")
+			}
+		}()
+		for req := range reqs {
+			_ = req.Reply(req.Type != "tcpip-forward", nil)
+		}
+	}()
+	client, err := ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestListenRefusedKeepsConnection(t *testing.T) {
+	engine := NewEngine(context.Background(), nil)
+	entry := engine.newEntry(&config.Host{Id: "bastion", Name: "Bastion"})
+	client := testClient(t)
+	entry.client = client
+
+	ln, ok := entry.Listen("127.0.0.1:8080")
+	assert.False(t, ok)
+	assert.Nil(t, ln)
+	assert.True(t, entry.isClient(client))
+}
+
+func TestFailedOpenIsSupervised(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+	_ = ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine := NewEngine(ctx, nil)
+	entry := engine.newEntry(&config.Host{
+		Id:     "bastion",
+		Name:   "Bastion",
+		Remote: config.NewAddress(address),
+		Retry:  &config.Retry{InitialDelay: 60, MaxDelay: 60},
+	})
+	entry.config = &ssh.ClientConfig{User: "test", HostKeyCallback: ssh.InsecureIgnoreHostKey()}
+	entry.AddListener("tunnel", func(engineModels.Host, bool) {})
+
+	assert.False(t, entry.Open())
+	require.Eventually(t, func() bool { return entry.Status().Attempts == 1 }, time.Second, 10*time.Millisecond)
+	status := entry.Status()
+	assert.False(t, status.Connected)
+	assert.NotEmpty(t, status.LastError)
+	require.NotNil(t, status.NextRetry)
+	assert.True(t, status.NextRetry.After(time.Now()))
+}
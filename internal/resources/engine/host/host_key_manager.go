@@ -9,10 +9,17 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"strings"
 	"sync"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
+	"us.figge.auto-ssh/internal/core/utils"
+)
+
+const (
+	markerCertAuthority = "cert-authority"
+	markerRevoked       = "revoked"
 )
 
 type hostKeyEntry struct {
@@ -20,10 +27,19 @@ type hostKeyEntry struct {
 	line int
 }
 
+// hostAuthority is a @cert-authority key, trusted to sign the keys of hosts matching
+// any of its patterns
+type hostAuthority struct {
+	patterns []string
+	hash     string
+}
+
 type HostKeyManager struct {
 	lock          sync.Mutex
 	knownHostFile string
 	knownKeys     map[string]map[string]hostKeyEntry
+	authorities   []hostAuthority
+	revoked       map[string]bool
 	lines         int
 }
 
@@ -38,22 +54,35 @@ func NewHostKeyManager(knownHostFile string) (*HostKeyManager, error) {
 	if err != nil {
 		return nil, err
 	}
+	var marker string
 	var hs []string
 	var pk ssh.PublicKey
 
 	knownKeys := make(map[string]map[string]hostKeyEntry)
+	var authorities []hostAuthority
+	revoked := make(map[string]bool)
 	line := 1
-	for _, hs, pk, _, bs, err = ssh.ParseKnownHosts(bs); err == nil; _, hs, pk, _, bs, err = ssh.ParseKnownHosts(bs) {
-		key := hostKeyEntry{hash: base64.StdEncoding.EncodeToString(pk.Marshal())}
+	for marker, hs, pk, _, bs, err = ssh.ParseKnownHosts(bs); err == nil; marker, hs, pk, _, bs, err = ssh.ParseKnownHosts(bs) {
+		key := hostKeyEntry{hash: base64.StdEncoding.EncodeToString(pk.Marshal()), line: line}
+		switch marker {
+		case markerCertAuthority:
+			authorities = append(authorities, hostAuthority{patterns: hs, hash: key.hash})
+			line++
+			continue
+		case markerRevoked:
+			revoked[key.hash] = true
+			line++
+			continue
+		}
 		for _, h := range hs {
 			if types, ok := knownKeys[h]; !ok {
 				knownKeys[h] = map[string]hostKeyEntry{pk.Type(): key}
 			} else if knownKey, ok2 := types[pk.Type()]; !ok2 {
 				types[pk.Type()] = key
 			} else if knownKey.hash == key.hash {
-				fmt.Printf("  Info  - known_hosts (%s) duplicate entries on lines %d and %d\n", knownHostFile, key.line, line)
+				fmt.Printf("  Info  - known_hosts (%s) duplicate entries on lines %d and %d\n", knownHostFile, knownKey.line, line)
 			} else {
-				return nil, fmt.Errorf("known_hosts (%s) inconsistent entries on lines %d and %d\n", knownHostFile, key.line, line)
+				return nil, fmt.Errorf("known_hosts (%s) inconsistent entries on lines %d and %d\n", knownHostFile, knownKey.line, line)
 			}
 		}
 		line++
@@ -64,14 +93,31 @@ func NewHostKeyManager(knownHostFile string) (*HostKeyManager, error) {
 	return &HostKeyManager{
 		knownHostFile: knownHostFile,
 		knownKeys:     knownKeys,
+		authorities:   authorities,
+		revoked:       revoked,
 		lines:         line,
 	}, nil
 }
 
+// Callback verifies a host key.  Certificates signed by a trusted authority are accepted
+// without a known_hosts entry for the host, any other key is checked against the file
 func (h *HostKeyManager) Callback(hostname string, remote net.Addr, key ssh.PublicKey) error {
 	if h.knownKeys == nil {
 		return nil
 	}
+	if cert, ok := key.(*ssh.Certificate); ok && h.hasAuthority(hostname) {
+		checker := &ssh.CertChecker{
+			IsHostAuthority: h.isHostAuthority,
+			IsRevoked:       h.isRevoked,
+		}
+		return checker.CheckHostKey(hostname, remote, cert)
+	} else if ok {
+		// No authority vouches for the host, so fall back to the certified key itself
+		key = cert.Key
+	}
+	if h.revoked[base64.StdEncoding.EncodeToString(key.Marshal())] {
+		return fmt.Errorf("host key for '%s' has been revoked", knownhosts.Normalize(hostname))
+	}
 	h.lock.Lock()
 	defer h.lock.Unlock()
 	ip := knownhosts.Normalize(hostname)
@@ -100,6 +146,48 @@ func (h *HostKeyManager) Callback(hostname string, remote net.Addr, key ssh.Publ
 	return fmt.Errorf("the authenticity of host '%s' can't be established", ip)
 }
 
+func (h *HostKeyManager) hasAuthority(address string) bool {
+	host := knownhosts.Normalize(address)
+	for _, authority := range h.authorities {
+		if matchHostPatterns(authority.patterns, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *HostKeyManager) isHostAuthority(auth ssh.PublicKey, address string) bool {
+	host := knownhosts.Normalize(address)
+	hash := base64.StdEncoding.EncodeToString(auth.Marshal())
+	for _, authority := range h.authorities {
+		if authority.hash == hash && matchHostPatterns(authority.patterns, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *HostKeyManager) isRevoked(cert *ssh.Certificate) bool {
+	return h.revoked[base64.StdEncoding.EncodeToString(cert.Key.Marshal())] ||
+		h.revoked[base64.StdEncoding.EncodeToString(cert.SignatureKey.Marshal())]
+}
+
+// matchHostPatterns reports whether host matches any of the known_hosts patterns,
+// and none of the negated (!) ones
+func matchHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		if negated := strings.HasPrefix(pattern, "!"); negated {
+			if utils.MatchWildcard(pattern[1:], host) {
+				return false
+			}
+		} else if utils.MatchWildcard(pattern, host) {
+			matched = true
+		}
+	}
+	return matched
+}
+
 func (h *HostKeyManager) appendHostKey(hostname string, key ssh.PublicKey) error {
 	if h.knownHostFile == "" {
 		return nil
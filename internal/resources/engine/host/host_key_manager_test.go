@@ -0,0 +1,81 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package host
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestMatchHostPatterns(t *testing.T) {
+	tests := map[string]struct {
+		patterns []string
+		host     string
+		match    bool
+	}{
+		"exact":         {patterns: []string{"bastion.example.com"}, host: "bastion.example.com", match: true},
+		"wildcard":      {patterns: []string{"*.example.com"}, host: "db.example.com", match: true},
+		"wildcard-miss": {patterns: []string{"*.example.com"}, host: "example.org", match: false},
+		"single":        {patterns: []string{"db?.example.com"}, host: "db1.example.com", match: true},
+		"port":          {patterns: []string{"[*.example.com]:2222"}, host: "[db.example.com]:2222", match: true},
+		"port-miss":     {patterns: []string{"*.example.com"}, host: "[db.example.com]:2222", match: false},
+		"negated":       {patterns: []string{"*.example.com", "!db.example.com"}, host: "db.example.com", match: false},
+		"negated-other": {patterns: []string{"*.example.com", "!db.example.com"}, host: "web.example.com", match: true},
+		"list":          {patterns: []string{"a.example.com", "b.example.com"}, host: "b.example.com", match: true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(tt *testing.T) {
+			assert.Equal(tt, test.match, matchHostPatterns(test.patterns, test.host))
+		})
+	}
+}
+
+func TestCallbackCertAuthority(t *testing.T) {
+	ca := newTestSigner(t)
+	other := newTestSigner(t)
+	hostKey := newTestSigner(t)
+
+	dir := t.TempDir()
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := "@cert-authority *.example.com " + string(ssh.MarshalAuthorizedKey(ca.PublicKey()))
+	assert.NoError(t, os.WriteFile(knownHosts, []byte(line), 0600))
+
+	manager, err := NewHostKeyManager(knownHosts)
+	assert.NoError(t, err)
+	assert.Len(t, manager.authorities, 1)
+
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 22}
+	assert.NoError(t, manager.Callback("db.example.com:22", remote, newTestHostCert(t, ca, hostKey, "db.example.com")))
+	assert.Error(t, manager.Callback("db.example.com:22", remote, newTestHostCert(t, other, hostKey, "db.example.com")))
+	assert.Error(t, manager.Callback("db.example.com:22", remote, newTestHostCert(t, ca, hostKey, "web.example.com")))
+}
+
+func newTestSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.NoError(t, err)
+	return signer
+}
+
+func newTestHostCert(t *testing.T, ca ssh.Signer, hostKey ssh.Signer, principal string) *ssh.Certificate {
+	cert := &ssh.Certificate{
+		Key:             hostKey.PublicKey(),
+		CertType:        ssh.HostCert,
+		ValidPrincipals: []string{principal},
+		ValidAfter:      uint64(time.Now().Add(-time.Hour).Unix()),
+		ValidBefore:     uint64(time.Now().Add(time.Hour).Unix()),
+	}
+	assert.NoError(t, cert.SignCert(rand.Reader, ca))
+	return cert
+}
@@ -0,0 +1,323 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package reload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/events"
+	"us.figge.auto-ssh/internal/core/utils"
+	"us.figge.auto-ssh/internal/resources/engine/host"
+	"us.figge.auto-ssh/internal/resources/engine/tunnel"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+)
+
+var (
+	ErrReload = errors.New("configuration not reloaded")
+)
+
+// LoadFn reads a configuration file the way it was read on start up
+type LoadFn func(filename string) (*config.Configuration, error)
+
+type OptFn func(e *Engine)
+
+func OptionInterval(interval time.Duration) OptFn {
+	return func(e *Engine) {
+		e.interval = interval
+	}
+}
+
+// Engine reloads the configuration file when it changes, applying only the differences
+// to the hosts and tunnels that are running
+type Engine struct {
+	lock     sync.Mutex
+	ctx      context.Context
+	filename string
+	load     LoadFn
+	hosts    engineModels.HostEngine
+	tunnels  engineModels.TunnelEngine
+	current  *config.Configuration
+	interval time.Duration
+	modTime  time.Time
+	checksum [sha256.Size]byte
+}
+
+func NewEngine(
+	ctx context.Context,
+	filename string,
+	current *config.Configuration,
+	load LoadFn,
+	hosts engineModels.HostEngine,
+	tunnels engineModels.TunnelEngine,
+	options ...OptFn,
+) *Engine {
+	engine := &Engine{
+		ctx:      ctx,
+		filename: filename,
+		load:     load,
+		hosts:    hosts,
+		tunnels:  tunnels,
+		current:  current,
+		interval: 5 * time.Second,
+	}
+	for _, option := range options {
+		option(engine)
+	}
+	engine.modTime, engine.checksum, _ = engine.fingerprint()
+	return engine
+}
+
+// Watch polls the configuration file, reloading it whenever its content changes, until
+// the context ends
+func (e *Engine) Watch() {
+	if e.filename == "" {
+		return
+	}
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			if e.changed() {
+				_ = e.Reload()
+			}
+		}
+	}
+}
+
+// changed reports whether the file holds content that is not yet running.  Content the
+// api saved is already running, and reloading it could revert a change still being saved
+func (e *Engine) changed() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	fi, err := os.Stat(e.filename)
+	if err != nil || fi.ModTime().Equal(e.modTime) {
+		return false
+	}
+	modTime, checksum, err := e.fingerprint()
+	if err != nil {
+		return false
+	}
+	e.modTime = modTime
+	if checksum == e.checksum {
+		return false
+	}
+	if config.IsApplied(e.filename, checksum) {
+		e.checksum = checksum
+		return false
+	}
+	return true
+}
+
+func (e *Engine) fingerprint() (time.Time, [sha256.Size]byte, error) {
+	fi, err := os.Stat(e.filename)
+	if err != nil {
+		return time.Time{}, [sha256.Size]byte{}, err
+	}
+	bs, err := os.ReadFile(e.filename)
+	if err != nil {
+		return time.Time{}, [sha256.Size]byte{}, err
+	}
+	return fi.ModTime(), sha256.Sum256(bs), nil
+}
+
+// Reload reads the configuration file and applies it.  Nothing changes if any host or
+// tunnel in it is invalid
+func (e *Engine) Reload() error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.filename == "" {
+		return fmt.Errorf("%w: %w", ErrReload, config.ErrNoConfigFile)
+	}
+	e.modTime, e.checksum, _ = e.fingerprint()
+	fmt.Printf("Reloading config from %s\n", e.filename)
+	c, err := e.load(e.filename)
+	if err != nil {
+		fmt.Printf("  Error - %v\n", err)
+		events.Publish(&events.Event{Type: events.ValidationError, Message: e.filename, Error: err.Error()})
+		return fmt.Errorf("%w: %w", ErrReload, err)
+	}
+
+	// Validation fills in defaults, so copies are validated and the definitions applied
+	// as they were written
+	he, v := host.ValidateHosts(e.ctx, copies(c.Hosts))
+	defer he.Close()
+	tunnel.ValidateTunnels(&v, he, copies(c.Tunnels))
+	if err = v.Output(ErrReload); err != nil {
+		fmt.Printf("  Error - %s is invalid.  The running configuration is unchanged\n", e.filename)
+		err = v.Err(ErrReload)
+		events.Publish(&events.Event{Type: events.ValidationError, Message: e.filename, Error: err.Error()})
+		return err
+	}
+
+	e.apply(c)
+	config.Applied(e.filename, e.checksum)
+	events.Publish(&events.Event{Type: events.ConfigReloaded, Message: e.filename})
+	if e.current != nil && (changed(e.current.Web, c.Web) || changed(e.current.Monitor, c.Monitor)) {
+		fmt.Printf("  Warn  - web and monitor changes take effect on restart\n")
+	}
+	return nil
+}
+
+// apply brings the engines in line with c.  Tunnels are removed before the hosts they
+// use, and hosts added before the tunnels and hosts that use them
+func (e *Engine) apply(c *config.Configuration) {
+	runningHosts := make(map[string]*config.Host)
+	for _, cfgHost := range e.hosts.Configured() {
+		runningHosts[idOf(cfgHost.Id, cfgHost.Name)] = cfgHost
+	}
+	runningTunnels := make(map[string]*config.Tunnel)
+	for _, cfgTunnel := range e.tunnels.Configured() {
+		runningTunnels[idOf(cfgTunnel.Id, cfgTunnel.Name)] = cfgTunnel
+	}
+	hosts := make(map[string]*config.Host)
+	for _, cfgHost := range c.Hosts {
+		hosts[idOf(cfgHost.Id, cfgHost.Name)] = cfgHost
+	}
+	tunnels := make(map[string]*config.Tunnel)
+	for _, cfgTunnel := range c.Tunnels {
+		tunnels[idOf(cfgTunnel.Id, cfgTunnel.Name)] = cfgTunnel
+	}
+
+	for id := range runningTunnels {
+		if _, ok := tunnels[id]; !ok {
+			report("tunnel", id, "removed", e.tunnels.RemoveTunnel(id))
+		}
+	}
+
+	var added []*config.Host
+	for _, cfgHost := range c.Hosts {
+		if _, ok := runningHosts[idOf(cfgHost.Id, cfgHost.Name)]; !ok {
+			added = append(added, cfgHost)
+		}
+	}
+	e.addHosts(added)
+	for _, cfgHost := range c.Hosts {
+		id := idOf(cfgHost.Id, cfgHost.Name)
+		if running, ok := runningHosts[id]; ok && changed(running, cfgHost) {
+			_, err := e.hosts.UpdateHost(id, cfgHost)
+			report("host", id, "updated", err)
+		}
+	}
+
+	for _, cfgTunnel := range c.Tunnels {
+		id := idOf(cfgTunnel.Id, cfgTunnel.Name)
+		if running, ok := runningTunnels[id]; !ok {
+			_, err := e.tunnels.AddTunnel(cfgTunnel, true)
+			report("tunnel", id, "added", err)
+		} else if changed(running, cfgTunnel) {
+			_, err := e.tunnels.UpdateTunnel(id, cfgTunnel)
+			report("tunnel", id, "updated", err)
+		}
+	}
+
+	var removed []string
+	for id := range runningHosts {
+		if _, ok := hosts[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	e.removeHosts(removed)
+	e.current = c
+}
+
+// addHosts adds hosts once the host they jump through exists
+func (e *Engine) addHosts(added []*config.Host) {
+	for len(added) > 0 {
+		var waiting []*config.Host
+		for _, cfgHost := range added {
+			if cfgHost.JumpHost != "" && defines(added, cfgHost.JumpHost) {
+				waiting = append(waiting, cfgHost)
+				continue
+			}
+			_, err := e.hosts.AddHost(cfgHost)
+			report("host", cfgHost.Id, "added", err)
+		}
+		if len(waiting) == len(added) {
+			// The jump chains loop, which validation has already reported
+			return
+		}
+		added = waiting
+	}
+}
+
+// removeHosts removes hosts once nothing jumps through them
+func (e *Engine) removeHosts(removed []string) {
+	for len(removed) > 0 {
+		var waiting []string
+		for _, id := range removed {
+			if _, err := e.hosts.RemoveHost(id, false); errors.Is(err, engineModels.ErrInUse) {
+				waiting = append(waiting, id)
+			} else {
+				report("host", id, "removed", err)
+			}
+		}
+		if len(waiting) == len(removed) {
+			for _, id := range waiting {
+				references, err := e.hosts.RemoveHost(id, true)
+				if err == nil && len(references) > 0 {
+					fmt.Printf("  Warn  - host (%s) removed while still used by %s\n", id, strings.Join(references, ", "))
+				} else {
+					report("host", id, "removed", err)
+				}
+			}
+			return
+		}
+		removed = waiting
+	}
+}
+
+// defines reports whether ref names one of hosts
+func defines(hosts []*config.Host, ref string) bool {
+	for _, cfgHost := range hosts {
+		if cfgHost.Id == ref || cfgHost.Name == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// idOf is the id a host or tunnel is known by, which defaults to its name
+func idOf(id string, name string) string {
+	return utils.DefaultString(strings.TrimSpace(id), strings.TrimSpace(name))
+}
+
+// copies clones each definition, so validating them leaves the originals as written
+func copies[T any](items []*T) []*T {
+	cloned := make([]*T, len(items))
+	for i, item := range items {
+		copied := *item
+		cloned[i] = &copied
+	}
+	return cloned
+}
+
+// changed compares two definitions as they would be written to the configuration file,
+// so runtime state such as resolved addresses and status is ignored
+func changed(a any, b any) bool {
+	as, aErr := yaml.Marshal(a)
+	bs, bErr := yaml.Marshal(b)
+	return aErr != nil || bErr != nil || !bytes.Equal(as, bs)
+}
+
+func report(kind string, id string, action string, err error) {
+	if err != nil {
+		fmt.Printf("  Error - %s (%s) not %s: %v\n", kind, id, action, err)
+	} else {
+		fmt.Printf("  Info  - %s (%s) %s\n", kind, id, action)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package reload
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/managers"
+	"us.figge.auto-ssh/internal/resources/engine/enginetest"
+	"us.figge.auto-ssh/internal/resources/engine/host"
+	"us.figge.auto-ssh/internal/resources/engine/tunnel"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+const (
+	testConfig = `
+tunnels:
+  - id: web
+    name: web
+    local: 127.0.0.1:18080
+    remote: 127.0.0.1:8080
+  - id: api
+    name: api
+    local: 127.0.0.1:18081
+    remote: 127.0.0.1:8081
+`
+	testReloaded = `
+tunnels:
+  - id: web
+    name: web
+    local: 127.0.0.1:18080
+    remote: 127.0.0.1:8080
+  - id: db
+    name: db
+    local: 127.0.0.1:15432
+    remote: 127.0.0.1:5432
+`
+	testInvalid = `
+tunnels:
+  - id: web
+    name: ""
+    local: 127.0.0.1:18080
+`
+)
+
+func load(filename string) (*config.Configuration, error) {
+	bs, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c := config.NewConfig()
+	return c, yaml.Unmarshal(bs, c)
+}
+
+func TestReload(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(testConfig), 0600))
+
+	c, err := load(filename)
+	require.NoError(t, err)
+	hostEngine := host.NewEngine(ctx, c.Hosts)
+	tunnelEngine := tunnel.NewEngine(ctx, hostEngine, c.Tunnels)
+	web, _ := tunnelEngine.Tunnel("web")
+	engine := NewEngine(ctx, filename, c, load, hostEngine, tunnelEngine)
+
+	require.NoError(t, os.WriteFile(filename, []byte(testReloaded), 0600))
+	require.NoError(t, engine.Reload())
+	unchanged, ok := tunnelEngine.Tunnel("web")
+	assert.True(t, ok)
+	assert.Same(t, web, unchanged)
+	_, ok = tunnelEngine.Tunnel("api")
+	assert.False(t, ok)
+	_, ok = tunnelEngine.Tunnel("db")
+	assert.True(t, ok)
+
+	require.NoError(t, os.WriteFile(filename, []byte(testInvalid), 0600))
+	assert.ErrorIs(t, engine.Reload(), ErrReload)
+	assert.Len(t, tunnelEngine.Tunnels(), 2)
+	unchanged, _ = tunnelEngine.Tunnel("web")
+	assert.Same(t, web, unchanged)
+}
+
+func TestApiSaveNotReloaded(t *testing.T) {
+	ctx := context.Background()
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(testConfig), 0600))
+	config.Applied(filename, sha256.Sum256([]byte(testConfig)))
+	c, err := load(filename)
+	require.NoError(t, err)
+	hostEngine := host.NewEngine(ctx, c.Hosts)
+	tunnelEngine := tunnel.NewEngine(ctx, hostEngine, c.Tunnels)
+	configManager, err := managers.NewConfigManager(ctx, filename, hostEngine, tunnelEngine)
+	require.NoError(t, err)
+	tunnelManager, err := managers.NewTunnelManager(ctx, hostEngine, tunnelEngine, configManager)
+	require.NoError(t, err)
+	engine := NewEngine(ctx, filename, c, load, hostEngine, tunnelEngine)
+
+	input := &managerModels.AddTunnelInput{Tunnel: *enginetest.Tunnel("db", "127.0.0.1:15432")}
+	_, err = tunnelManager.AddTunnel(ctx, input)
+	require.NoError(t, err)
+	saved, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Contains(t, string(saved), "name: db")
+
+	engine.modTime = time.Time{}
+	assert.False(t, engine.changed(), "the api's own save is already running")
+	assert.Equal(t, sha256.Sum256(saved), engine.checksum)
+	_, ok := tunnelEngine.Tunnel("db")
+	assert.True(t, ok)
+
+	require.NoError(t, os.WriteFile(filename, []byte(testReloaded), 0600))
+	engine.modTime = time.Time{}
+	assert.True(t, engine.changed(), "a hand edit is reloaded")
+}
@@ -10,10 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"slices"
 	"sync"
 	"time"
 
-	"us.figge.auto-ssh/internal/core/config"
 	engineModels "us.figge.auto-ssh/internal/resources/models"
 )
 
@@ -22,6 +22,10 @@ var (
 	interval = time.Second * 5
 )
 
+const (
+	writeTimeout = 2 * time.Second
+)
+
 type Engine struct {
 	lock          sync.Mutex
 	statsAddress  string
@@ -41,24 +45,38 @@ func NewEngine() *Engine {
 }
 
 func (s *Engine) StartStatsTunnel(ctx context.Context, port int) error {
-	if config.C.Monitor.StatsPort != -1 {
-		var err error
-		s.statsAddress = fmt.Sprintf("127.0.0.1:%d", port)
-		s.statsListener, err = net.Listen("tcp", s.statsAddress)
-		if err != nil {
-			fmt.Printf("Warn - Failed to initialize stats monitor: %v\n", err)
-			return err
-		}
+	if port == -1 {
+		return nil
+	}
+	var err error
+	s.statsAddress = fmt.Sprintf("127.0.0.1:%d", port)
+	s.statsListener, err = net.Listen("tcp", s.statsAddress)
+	if err != nil {
+		fmt.Printf("Warn - Failed to initialize stats monitor: %v\n", err)
+		return err
 	}
 	go s.statsTransmitter(ctx, port)
 	return nil
 }
 
 func (s *Engine) NewEntry() engineModels.Stats {
-	return &Entry{
-		statsData:  &statsData{},
+	entry := &Entry{
+		Data:       &Data{},
 		updateChan: s.updateChan,
 	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tunnelStats = append(s.tunnelStats, entry)
+	return entry
+}
+
+// RemoveEntry stops reporting the stats of a tunnel that has been removed
+func (s *Engine) RemoveEntry(stats engineModels.Stats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.tunnelStats = slices.DeleteFunc(s.tunnelStats, func(entry *Entry) bool {
+		return entry == stats
+	})
 }
 
 func (s *Engine) statsTransmitter(ctx context.Context, port int) {
@@ -103,29 +121,27 @@ func (s *Engine) statsBroadcaster(ctx context.Context) {
 					} else {
 						<-time.NewTimer(time.Second).C
 					}
-					bs, err := json.Marshal(s.tunnelStats)
 					lastBroadcast = time.Now()
-					if err == nil {
-						s.writeUpdate(bs)
-					}
 					s.updated = false
+					s.writeUpdate()
 				}()
 			}
 		}
 	}
 }
 
-func (s *Engine) writeUpdate(update []byte) {
-	if !s.lock.TryLock() {
+// writeUpdate sends every client the current stats.  Each update is a json array padded
+// with zeros to a multiple of 256 bytes, so the zeros mark where one update ends
+func (s *Engine) writeUpdate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.encode(); err != nil {
+		fmt.Printf("  Error - Unable to encode stats: %v\n", err)
 		return
 	}
-	defer s.lock.Unlock()
-
-	x := 256 - (len(update) % 256)
-	s.lastUpdate = append(update, zeros[256-x:]...)
 	var alive []net.Conn
 	for _, conn := range s.connections {
-		if _, err := conn.Write(s.lastUpdate); err != nil {
+		if err := s.send(conn); err != nil {
 			fmt.Printf("  Info  - Disconnected stats client\n")
 			_ = conn.Close()
 		} else {
@@ -138,10 +154,30 @@ func (s *Engine) writeUpdate(update []byte) {
 	}
 }
 
+func (s *Engine) encode() error {
+	update, err := json.Marshal(s.tunnelStats)
+	if err != nil {
+		return err
+	}
+	x := 256 - (len(update) % 256)
+	s.lastUpdate = append(update, zeros[256-x:]...)
+	return nil
+}
+
+// send writes the last update, giving up on a client that is not reading
+func (s *Engine) send(conn net.Conn) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := conn.Write(s.lastUpdate)
+	return err
+}
+
 func (s *Engine) addConnection(conn net.Conn) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
-	_, err := conn.Write(s.lastUpdate)
+	err := s.encode()
+	if err == nil {
+		err = s.send(conn)
+	}
 	if err != nil {
 		fmt.Printf("  Error - Unable to send current update to new client: %v\n", err)
 	}
@@ -5,18 +5,18 @@
 package stats
 
 import (
-	"fmt"
-	"sync/atomic"
+	"encoding/json"
+	"sync"
 	"time"
-)
 
-var (
-	currentConnections = atomic.Int32{}
-	totalConnections   = atomic.Int32{}
+	"us.figge.auto-ssh/internal/core/config"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
 )
 
-type statsData struct {
-	Id          int       `json:"i" title:"Id"   format:"%%%ds "  sort:"%[2]s%[1]s"`
+// Data is a tunnel's stats as broadcast on the stats port.  The title, format and sort tags
+// describe how the monitor shows each metric
+type Data struct {
+	Id          string    `json:"i" title:"Id"   format:"%%%ds "  sort:"%[2]s%[1]s"`
 	Name        string    `json:"n" title:"Name" format:"%%-%ds " sort:"%[1]s%[2]s"`
 	Port        int       `json:"p" title:"Port" format:"%%%ds "  sort:"%[2]s%[1]s"`
 	In          int64     `json:"r" title:"Rcvd" format:"%%%ds "  sort:"%[2]s%[1]s"`
@@ -25,34 +25,168 @@ type statsData struct {
 	Connections int       `json:"c" title:"Used" format:"%%%ds "  sort:"%[2]s%[1]s"`
 	JumpTunnel  bool      `json:"j" title:"Jump" format:"%%%ds "  sort:"%[2]s%[1]s"`
 	LastUpdate  time.Time `json:"u" title:"Last" format:"%%-%ds " sort:"%[1]s%[2]s"`
+	State       string    `json:"s" title:"State" format:"%%-%ds " sort:"%[1]s%[2]s"`
+	Color       string    `json:"k,omitempty"`
+	Highlight   string    `json:"h,omitempty"`
+
+	lock         sync.Mutex
+	Destinations map[string]int `json:"d,omitempty"`
+
+	failures    int
+	reconnects  int
+	dialCounts  []uint64
+	dialCount   uint64
+	dialSeconds float64
 }
 
+const (
+	maxDestinations = 20
+)
+
 type Entry struct {
-	*statsData
+	*Data
 	updateChan chan struct{}
 }
 
+// Connected counts a connection opened, returning the number of connections the tunnel
+// has now made, which identifies it
 func (e Entry) Connected() int {
-	currentConnections.Add(1)
-	totalConnections.Add(1)
-	return int(currentConnections.Load())
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.Data.Connected++
+	e.Connections++
+	e.changed()
+	return e.Connections
 }
 
 func (e Entry) Disconnected() {
-	currentConnections.Add(-1)
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.Data.Connected--
+	e.changed()
 }
 
 func (e Entry) Received(n int64) {
-	fmt.Printf("  Info  - Recieved %d\n", n)
+	e.lock.Lock()
+	defer e.lock.Unlock()
 	e.In += n
 }
 
 func (e Entry) Transmitted(n int64) {
-	fmt.Printf("  Info  - Transmitted %d\n", n)
+	e.lock.Lock()
+	defer e.lock.Unlock()
 	e.Out += n
 }
 
+// Destination counts connections made to an address chosen by the client, such as
+// through a dynamic (socks) tunnel.  Only maxDestinations are kept, as every broadcast
+// carries them.  A new address displaces the least used, so the busiest stay
+func (e Entry) Destination(address string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.Destinations == nil {
+		e.Destinations = make(map[string]int)
+	}
+	if _, ok := e.Destinations[address]; !ok && len(e.Destinations) >= maxDestinations {
+		least, fewest := "", 0
+		for destination, count := range e.Destinations {
+			if least == "" || count < fewest {
+				least, fewest = destination, count
+			}
+		}
+		delete(e.Destinations, least)
+	}
+	e.Destinations[address]++
+	e.changed()
+}
+
 func (e Entry) Updated() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
 	e.LastUpdate = time.Now()
+	e.changed()
+}
+
+// Describe names the tunnel the stats belong to.  Jump tunnels reach their remote through
+// a host that is itself reached through a jump host
+func (e Entry) Describe(id string, name string, port int, jump bool, metadata *config.Metadata) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.Id, e.Name, e.Port, e.JumpTunnel = id, name, port, jump
+	e.Color, e.Highlight = "", ""
+	if metadata != nil {
+		e.Color, e.Highlight = metadata.Color, metadata.Highlight
+	}
+	e.changed()
+}
+
+// State records the running state of the tunnel
+func (e Entry) State(state string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.Data.State = state
+	e.changed()
+}
+
+// changed asks for the stats to be broadcast, unless a broadcast is already pending
+func (e Entry) changed() {
+	select {
+	case e.updateChan <- struct{}{}:
+	default:
+	}
+}
+
+func (e Entry) MarshalJSON() ([]byte, error) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return json.Marshal(e.Data)
+}
+
+// Failed counts a connection that could not be forwarded
+func (e Entry) Failed() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.failures++
+}
+
+// Dialed records how long it took to reach the far end of a connection
+func (e Entry) Dialed(latency time.Duration) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if e.dialCounts == nil {
+		e.dialCounts = make([]uint64, len(engineModels.DialBuckets))
+	}
+	seconds := latency.Seconds()
+	for i, bucket := range engineModels.DialBuckets {
+		if seconds <= bucket {
+			e.dialCounts[i]++
+		}
+	}
+	e.dialCount++
+	e.dialSeconds += seconds
+}
+
+// Reconnecting counts an attempt to reopen the tunnel's entrance
+func (e Entry) Reconnecting() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.reconnects++
+}
 
+func (e Entry) Snapshot() *engineModels.StatsSnapshot {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	dialCounts := make([]uint64, len(engineModels.DialBuckets))
+	copy(dialCounts, e.dialCounts)
+	return &engineModels.StatsSnapshot{
+		Received:    e.In,
+		Transmitted: e.Out,
+		Open:        e.Data.Connected,
+		Connections: e.Connections,
+		Failures:    e.failures,
+		Reconnects:  e.reconnects,
+		DialCounts:  dialCounts,
+		DialCount:   e.dialCount,
+		DialSeconds: e.dialSeconds,
+	}
 }
@@ -0,0 +1,32 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package stats
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestinationsBounded(t *testing.T) {
+	updates := make(chan struct{}, 1)
+	entry := Entry{Data: &Data{}, updateChan: updates}
+
+	for range 5 {
+		entry.Destination("busy.example.com:443")
+	}
+	select {
+	case <-updates:
+	default:
+		assert.Fail(t, "a destination counted is broadcast")
+	}
+	for i := range 10 * maxDestinations {
+		entry.Destination(fmt.Sprintf("host%d.example.com:443", i))
+	}
+
+	assert.Len(t, entry.Destinations, maxDestinations)
+	assert.Equal(t, 5, entry.Destinations["busy.example.com:443"], "the busiest destination is kept")
+}
@@ -7,41 +7,84 @@ package tunnel
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"sync"
 
 	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/events"
+	"us.figge.auto-ssh/internal/core/utils"
 	engineModels "us.figge.auto-ssh/internal/resources/models"
 )
 
 type Engine struct {
+	lock          sync.RWMutex
+	ctx           context.Context
+	he            engineModels.HostEngineInternal
+	statsEngine   engineModels.StatsEngine
+	wg            *sync.WaitGroup
 	tunnelEntries map[string]*Entry
 }
 
 func NewEngine(ctx context.Context, he engineModels.HostEngineInternal, tunnels []*config.Tunnel) *Engine {
 	engine := &Engine{
+		ctx:           ctx,
+		he:            he,
 		tunnelEntries: make(map[string]*Entry),
 	}
+	v := config.NewValidations()
+	engine.validate(&v, tunnels)
+	_ = v.Output(nil)
+	return engine
+}
+
+// ValidateTunnels checks a set of tunnel definitions against the hosts of he, without
+// starting any.  Nothing is registered with the hosts of the engine in use, so he should
+// be one returned by host.ValidateHosts
+func ValidateTunnels(v *config.Validations, he engineModels.HostEngineInternal, tunnels []*config.Tunnel) {
+	engine := &Engine{
+		he:            he,
+		tunnelEntries: make(map[string]*Entry),
+	}
+	engine.validate(v, tunnels)
+}
+
+func (te *Engine) validate(v *config.Validations, tunnels []*config.Tunnel) {
 	for _, cfgTunnel := range tunnels {
-		if _, ok := engine.tunnelEntries[cfgTunnel.Name]; ok {
-			fmt.Printf("  Error - tunnel name (%s) redfined\n", cfgTunnel.Name)
+		tunnel := newEntry(cfgTunnel)
+		if te.exists(cfgTunnel) {
+			v.Errorf("tunnel name (%s) redfined", cfgTunnel.Name)
 			continue
 		}
-		tunnel := &Entry{
-			tunnelData: &tunnelData{
-				Tunnel: cfgTunnel,
-			},
-		}
-		tunnel.Status = &config.Status{
-			Running: "Stopped",
-			Valid:   true,
-		}
-		tunnel.Validate(he)
-		engine.tunnelEntries[tunnel.tunnelData.Id] = tunnel
+		tunnel.Validate(v, te.he)
+		te.tunnelEntries[tunnel.tunnelData.Id] = tunnel
+		tunnel.attach()
 	}
-	return engine
+}
+
+// newEntry wraps a tunnel definition, defaulting its id to its name.  A copy is kept as
+// configured, since validation fills in defaults that have no place in a saved configuration
+func newEntry(cfgTunnel *config.Tunnel) *Entry {
+	configured := *cfgTunnel
+	configured.Status = nil
+	cfgTunnel.Id = utils.DefaultString(strings.TrimSpace(cfgTunnel.Id), strings.TrimSpace(cfgTunnel.Name))
+	tunnel := &Entry{
+		tunnelData: &tunnelData{
+			Tunnel:     cfgTunnel,
+			configured: &configured,
+			state:      newState(),
+		},
+	}
+	tunnel.tunnelData.Status = &config.Status{
+		Valid: true,
+	}
+	tunnel.state.notify = tunnel.transitioned
+	return tunnel
 }
 
 func (te *Engine) Tunnels() []engineModels.Tunnel {
+	te.lock.RLock()
+	defer te.lock.RUnlock()
 	tunnels := make([]engineModels.Tunnel, 0, len(te.tunnelEntries))
 	for _, tunnelEntry := range te.tunnelEntries {
 		tunnels = append(tunnels, tunnelEntry)
@@ -49,18 +92,215 @@ func (te *Engine) Tunnels() []engineModels.Tunnel {
 	return tunnels
 }
 
+// Configs returns a copy of the configuration of every tunnel, ordered by id
+func (te *Engine) Configs() []*config.Tunnel {
+	te.lock.RLock()
+	defer te.lock.RUnlock()
+	tunnels := make([]*config.Tunnel, 0, len(te.tunnelEntries))
+	for _, tunnelEntry := range te.tunnelEntries {
+		tunnels = append(tunnels, tunnelEntry.config())
+	}
+	slices.SortFunc(tunnels, func(a, b *config.Tunnel) int { return strings.Compare(a.Id, b.Id) })
+	return tunnels
+}
+
+// Configured returns a copy of every tunnel as it was configured, before validation filled
+// in its defaults, ordered by id
+func (te *Engine) Configured() []*config.Tunnel {
+	te.lock.RLock()
+	defer te.lock.RUnlock()
+	tunnels := make([]*config.Tunnel, 0, len(te.tunnelEntries))
+	for _, tunnelEntry := range te.tunnelEntries {
+		tunnelEntry.configLock.RLock()
+		copied := *tunnelEntry.configured
+		tunnelEntry.configLock.RUnlock()
+		tunnels = append(tunnels, &copied)
+	}
+	slices.SortFunc(tunnels, func(a, b *config.Tunnel) int { return strings.Compare(a.Id, b.Id) })
+	return tunnels
+}
+
 func (te *Engine) Tunnel(id string) (engineModels.Tunnel, bool) {
+	te.lock.RLock()
+	defer te.lock.RUnlock()
 	tunnel, ok := te.tunnelEntries[id]
 	return tunnel, ok
 }
 
 func (te *Engine) StartTunnels(ctx context.Context, statsEngine engineModels.StatsEngine, wg *sync.WaitGroup) {
+	te.lock.Lock()
+	defer te.lock.Unlock()
+	te.ctx, te.statsEngine, te.wg = ctx, statsEngine, wg
 	for _, tunnel := range te.tunnelEntries {
-		statsEntry := statsEngine.NewEntry()
-		tunnel.init(ctx, statsEntry, wg)
+		te.initEntry(tunnel, nil)
 		if !tunnel.Valid() {
 			continue
 		}
 		tunnel.Start()
 	}
 }
+
+// initEntry readies a tunnel to be started, once the engine has been.  A tunnel replacing
+// another takes over its stats
+func (te *Engine) initEntry(tunnel *Entry, stats engineModels.Stats) bool {
+	if te.statsEngine == nil {
+		return false
+	}
+	if stats == nil {
+		stats = te.statsEngine.NewEntry()
+	}
+	tunnel.init(te.ctx, stats, te.wg)
+	return true
+}
+
+// unique reports an error when a tunnel other than self already uses the id or name of
+// cfgTunnel
+func (te *Engine) unique(cfgTunnel *config.Tunnel, self *Entry) error {
+	for _, ref := range []string{cfgTunnel.Id, strings.TrimSpace(cfgTunnel.Name)} {
+		if other, ok := te.lookup(ref); ok && other != self {
+			return fmt.Errorf("%w: tunnel (%s)", engineModels.ErrExists, ref)
+		}
+	}
+	return nil
+}
+
+// exists reports whether a tunnel already uses the id or name of cfgTunnel
+func (te *Engine) exists(cfgTunnel *config.Tunnel) bool {
+	for _, ref := range []string{cfgTunnel.Id, strings.TrimSpace(cfgTunnel.Name)} {
+		if _, ok := te.lookup(ref); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup finds a tunnel by id, falling back to its name
+func (te *Engine) lookup(ref string) (*Entry, bool) {
+	if entry, ok := te.tunnelEntries[ref]; ok {
+		return entry, true
+	}
+	for _, entry := range te.tunnelEntries {
+		if entry.tunnelData.Name == ref {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// AddTunnel validates a new tunnel and adds it, starting it if asked.  Nothing changes if
+// it is invalid.  The id defaults to the tunnel's name.  Validation resolves addresses,
+// so it happens outside the lock, and the id and name are checked again once it is held
+func (te *Engine) AddTunnel(cfgTunnel *config.Tunnel, start bool) (engineModels.Tunnel, error) {
+	tunnel := newEntry(cfgTunnel)
+	te.lock.RLock()
+	err := te.unique(cfgTunnel, nil)
+	te.lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	v := config.NewValidations()
+	tunnel.Validate(&v, te.he)
+	_ = v.Output(nil)
+	if err = v.Err(engineModels.ErrInvalid); err != nil {
+		invalid(cfgTunnel, err)
+		return nil, err
+	}
+
+	te.lock.Lock()
+	defer te.lock.Unlock()
+	if err = te.unique(cfgTunnel, nil); err != nil {
+		return nil, err
+	}
+	te.tunnelEntries[cfgTunnel.Id] = tunnel
+	tunnel.attach()
+	if te.initEntry(tunnel, nil) && start {
+		tunnel.Start()
+	}
+	return tunnel, nil
+}
+
+// UpdateTunnel validates a replacement configuration for a tunnel.  Changes that do not
+// affect how it forwards are applied in place, anything else replaces the tunnel, which
+// is restarted if it was running.  Nothing changes if the replacement is invalid.  As with
+// AddTunnel, the replacement is validated outside the lock
+func (te *Engine) UpdateTunnel(id string, cfgTunnel *config.Tunnel) (engineModels.Tunnel, error) {
+	cfgTunnel.Id = id
+	te.lock.RLock()
+	tunnel, ok := te.tunnelEntries[id]
+	err := te.unique(cfgTunnel, tunnel)
+	te.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: tunnel (%s)", engineModels.ErrNotFound, id)
+	} else if err != nil {
+		return nil, err
+	}
+
+	v := config.NewValidations()
+	replacement := newEntry(cfgTunnel)
+	// The id cannot change, so it stays however it was configured
+	replacement.configured.Id = tunnel.configuredId()
+	replacement.Validate(&v, te.he)
+	_ = v.Output(nil)
+	if err = v.Err(engineModels.ErrInvalid); err != nil {
+		invalid(cfgTunnel, err)
+		return nil, err
+	}
+
+	te.lock.Lock()
+	if te.tunnelEntries[id] != tunnel {
+		// Removed, and perhaps added again, while the replacement was validated
+		te.lock.Unlock()
+		return nil, fmt.Errorf("%w: tunnel (%s)", engineModels.ErrNotFound, id)
+	}
+	if err = te.unique(cfgTunnel, tunnel); err != nil {
+		te.lock.Unlock()
+		return nil, err
+	}
+
+	if !forwardingChanged(tunnel.config(), replacement.Tunnel) {
+		tunnel.apply(replacement)
+		tunnel.attach()
+		te.lock.Unlock()
+		return tunnel, nil
+	}
+
+	// The replacement takes the tunnel's place straight away, but is only started once
+	// the tunnel has let go of its entrance, which is waited for outside the lock
+	running := tunnel.state.get()
+	tunnel.detach()
+	te.tunnelEntries[id] = replacement
+	replacement.attach()
+	initialized := te.initEntry(replacement, tunnel.Stats())
+	te.lock.Unlock()
+	tunnel.stopAndWait()
+	if initialized && running != engineModels.Stopped && running != engineModels.Failed {
+		replacement.Start()
+	}
+	return replacement, nil
+}
+
+func invalid(cfgTunnel *config.Tunnel, err error) {
+	events.Publish(&events.Event{Type: events.ValidationError, Tunnel: cfgTunnel.Id, Tags: events.Tags(cfgTunnel.Metadata), Error: err.Error()})
+}
+
+// RemoveTunnel forgets a tunnel, along with its stats, once it has stopped.  The wait
+// for it to stop happens outside the lock
+func (te *Engine) RemoveTunnel(id string) error {
+	te.lock.Lock()
+	tunnel, ok := te.tunnelEntries[id]
+	if !ok {
+		te.lock.Unlock()
+		return fmt.Errorf("%w: tunnel (%s)", engineModels.ErrNotFound, id)
+	}
+	delete(te.tunnelEntries, id)
+	tunnel.detach()
+	statsEngine := te.statsEngine
+	te.lock.Unlock()
+
+	tunnel.stopAndWait()
+	if stats := tunnel.Stats(); statsEngine != nil && stats != nil {
+		statsEngine.RemoveEntry(stats)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/resources/engine/enginetest"
+	"us.figge.auto-ssh/internal/resources/engine/host"
+	"us.figge.auto-ssh/internal/resources/engine/stats"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+)
+
+func TestEngineMutations(t *testing.T) {
+	newTunnel := enginetest.Tunnel
+	engine := NewEngine(context.Background(), nil, nil)
+
+	added, err := engine.AddTunnel(newTunnel("web", "127.0.0.1:18080"), false)
+	require.NoError(t, err)
+	assert.Equal(t, "web", added.Id())
+	_, err = engine.AddTunnel(newTunnel("web", "127.0.0.1:18081"), false)
+	assert.ErrorIs(t, err, engineModels.ErrExists)
+	_, err = engine.AddTunnel(&config.Tunnel{Name: "blank"}, false)
+	assert.ErrorIs(t, err, engineModels.ErrInvalid)
+
+	renamed, err := engine.UpdateTunnel("web", newTunnel("web server", "127.0.0.1:18080"))
+	require.NoError(t, err)
+	assert.Same(t, added, renamed)
+	assert.Equal(t, "web server", renamed.Name())
+
+	moved, err := engine.UpdateTunnel("web", newTunnel("web server", "127.0.0.1:18082"))
+	require.NoError(t, err)
+	assert.NotSame(t, added, moved)
+	assert.Equal(t, "127.0.0.1:18082", moved.Local().String())
+
+	require.NoError(t, engine.RemoveTunnel("web"))
+	_, ok := engine.Tunnel("web")
+	assert.False(t, ok)
+	assert.ErrorIs(t, engine.RemoveTunnel("web"), engineModels.ErrNotFound)
+}
+
+func TestForcedHostRemoveStopsTunnels(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	hosts := host.NewEngine(ctx, []*config.Host{enginetest.Host(enginetest.Identity(t), "bastion", "Bastion", "")})
+	engine := NewEngine(ctx, hosts, nil)
+	wg := &sync.WaitGroup{}
+	engine.StartTunnels(ctx, stats.NewEngine(), wg)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	local := ln.Addr().String()
+	_ = ln.Close()
+	cfgTunnel := enginetest.Tunnel("web", local)
+	cfgTunnel.Host = "bastion"
+	tunnel, err := engine.AddTunnel(cfgTunnel, true)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return tunnel.Running() == engineModels.Started.String() }, time.Second, 10*time.Millisecond)
+
+	references, err := hosts.RemoveHost("bastion", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tunnel (web)"}, references)
+	require.Eventually(t, func() bool { return tunnel.Running() == engineModels.Stopped.String() }, time.Second, 10*time.Millisecond)
+	assert.Contains(t, tunnel.Status().LastError, "host (Bastion) removed")
+	wg.Wait()
+}
+
+func TestUpdateTunnelConcurrentReads(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	engine := NewEngine(ctx, nil, nil)
+	wg := &sync.WaitGroup{}
+	engine.StartTunnels(ctx, stats.NewEngine(), wg)
+	tunnel, err := engine.AddTunnel(enginetest.Tunnel("web", "127.0.0.1:18083"), true)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = tunnel.Name()
+			_ = tunnel.Metadata()
+			_ = engine.Configs()
+		}
+	}()
+	_, err = engine.UpdateTunnel("web", enginetest.Tunnel("web server", "127.0.0.1:18083"))
+	require.NoError(t, err)
+	moved, err := engine.UpdateTunnel("web", enginetest.Tunnel("web server", "127.0.0.1:18084"))
+	require.NoError(t, err)
+	<-done
+	require.Eventually(t, func() bool { return moved.Running() == engineModels.Started.String() }, time.Second, 10*time.Millisecond)
+	require.NoError(t, engine.RemoveTunnel("web"))
+	assert.Equal(t, engineModels.Stopped.String(), moved.Running())
+	wg.Wait()
+}
+
+func TestAddTunnelConcurrent(t *testing.T) {
+	engine := NewEngine(context.Background(), nil, nil)
+	var added sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		added.Add(1)
+		go func(port int) {
+			defer added.Done()
+			_, err := engine.AddTunnel(enginetest.Tunnel("web", fmt.Sprintf("127.0.0.1:%d", port)), false)
+			errs <- err
+		}(18090 + i)
+	}
+	added.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			assert.ErrorIs(t, err, engineModels.ErrExists)
+		}
+	}
+	assert.Equal(t, 1, succeeded, "the id is checked again once validated")
+	assert.Len(t, engine.Tunnels(), 1)
+}
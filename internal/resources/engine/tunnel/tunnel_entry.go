@@ -8,26 +8,38 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/events"
+	"us.figge.auto-ssh/internal/core/utils/backoff"
 	engineModels "us.figge.auto-ssh/internal/resources/models"
 )
 
 var (
 	errInvalidWrite = errors.New("invalid write result")
+	errEntranceLost = errors.New("entrance lost")
 )
 
 type tunnelData struct {
 	*config.Tunnel
-	lock   sync.Mutex
-	host   engineModels.HostInternal
-	conns  []net.Conn
-	stats  engineModels.Stats
-	cancel context.CancelFunc
-	wg     *sync.WaitGroup
+	configured *config.Tunnel
+	// configLock guards the fields apply changes in place: Name, Metadata, Retry and configured
+	configLock sync.RWMutex
+	lock       sync.Mutex
+	host       engineModels.HostInternal
+	conns      []net.Conn
+	stats      engineModels.Stats
+	cancel     context.CancelFunc
+	wg         *sync.WaitGroup
+	wake       chan struct{}
+	done       chan struct{}
+	state      *state
 }
 
 type Entry struct {
@@ -39,39 +51,217 @@ func (t *Entry) init(ctx context.Context, stats engineModels.Stats, wg *sync.Wai
 	t.appCtx = ctx
 	t.stats = stats
 	t.wg = wg
+	t.wake = make(chan struct{}, 1)
+	t.describe()
 }
 
-func (t *Entry) Start() {
-	if t.Status.Running != "Stopped" {
+// describe tells the tunnel's stats what it is, for the monitor
+func (t *Entry) describe() {
+	if t.stats == nil {
 		return
 	}
-	t.Status.Running = "Starting"
-	var ctx context.Context
-	ctx, t.cancel = context.WithCancel(t.appCtx)
-	localListener, err := net.Listen("tcp", t.Local().String())
-	if err != nil {
-		fmt.Printf("  Error - tunnel (%s) entrance (%s) cannot be created: %v\n", t.Name(), t.Local().String(), err)
+	jump := t.host != nil && t.host.JumpHost() != ""
+	t.stats.Describe(t.Id(), t.Name(), t.Local().Port(), jump, t.Metadata())
+	t.stats.State(t.Running())
+}
+
+func (t *Entry) Start() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if !t.state.transitionFrom(engineModels.Starting, nil, engineModels.Stopped, engineModels.Failed) {
 		return
 	}
-	fmt.Printf("  Info  - tunnel (%s) entrance opened at %s\n", t.Name(), t.Local().String())
+	ctx, cancel := context.WithCancel(t.appCtx)
+	t.cancel = cancel
+	t.done = make(chan struct{})
 	t.wg.Add(1)
-	go t.waitForTermination(ctx, localListener)
-	go t.runningAcceptLoop(ctx, localListener)
-	t.Status.Running = "Started"
+	go t.supervise(ctx, cancel, t.done)
+}
+
+// supervise keeps the tunnel's entrance open until it is stopped, backing off between
+// attempts whenever the listener cannot be created or is lost along with its host.  The
+// context is cancelled however the tunnel ends, whether stopped or failed
+func (t *Entry) supervise(ctx context.Context, cancel context.CancelFunc, done chan struct{}) {
+	defer close(done)
+	defer func() {
+		// Application shutdown cancels the context without going through Stop
+		t.state.transitionFrom(engineModels.Stopping, nil,
+			engineModels.Starting, engineModels.Started, engineModels.Degraded, engineModels.Reconnecting)
+		t.state.transitionFrom(engineModels.Stopped, nil, engineModels.Stopping)
+		cancel()
+		t.lock.Lock()
+		if t.done == done {
+			// A restart after failing has its own context by now
+			t.cancel = nil
+		}
+		t.lock.Unlock()
+		t.wg.Done()
+	}()
+	retry := backoff.NewBackoff(backoff.OptionRetry(t.Retry()))
+	for {
+		listener, err := t.listen()
+		if err == nil {
+			retry.Reset()
+			fmt.Printf("  Info  - tunnel (%s) entrance opened at %s\n", t.Name(), t.entrance().String())
+			t.state.transition(engineModels.Started, nil)
+			t.serve(ctx, listener)
+			if ctx.Err() != nil {
+				return
+			}
+			err = fmt.Errorf("%w: %s", errEntranceLost, t.entrance().String())
+			fmt.Printf("  Warn  - tunnel (%s) %v\n", t.Name(), err)
+		} else {
+			fmt.Printf("  Error - tunnel (%s) %v\n", t.Name(), err)
+		}
+
+		delay, ok := retry.Next()
+		if !ok {
+			t.state.transition(engineModels.Failed, fmt.Errorf("gave up after %d attempts: %w", retry.Attempts(), err))
+			fmt.Printf("  Error - tunnel (%s) giving up after %d attempts\n", t.Name(), retry.Attempts())
+			return
+		}
+		if t.state.get() != engineModels.Reconnecting && !t.state.transitionFrom(engineModels.Reconnecting, err,
+			engineModels.Starting, engineModels.Started, engineModels.Degraded) {
+			// Stop was called while the entrance was being opened
+			return
+		}
+		t.state.retrying(retry.Attempts(), time.Now().Add(delay), err)
+		t.stats.Reconnecting()
+		fmt.Printf("  Info  - tunnel (%s) retry %d in %s\n", t.Name(), retry.Attempts(), delay.Round(time.Millisecond))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-t.wake:
+			// The host reconnected, so there is no reason to wait out the delay
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// serve accepts connections until the tunnel is stopped or the listener fails
+func (t *Entry) serve(ctx context.Context, listener net.Listener) {
+	done := make(chan struct{})
+	go func() {
+		t.acceptLoop(ctx, listener)
+		close(done)
+	}()
+	select {
+	case <-ctx.Done():
+		fmt.Printf("  Info  - tunnel (%s) stopped listening on %s\n", t.Name(), t.entrance().String())
+		_ = listener.Close()
+		t.closeConnections()
+		<-done
+	case <-done:
+		_ = listener.Close()
+	}
+}
+
+func (t *Entry) listen() (net.Listener, error) {
+	if t.tunnelData.Type == config.TunnelRemote {
+		if !t.host.Open() {
+			return nil, fmt.Errorf("host (%s) cannot be reached", t.host.Name())
+		}
+		listener, ok := t.host.Listen(t.Remote().String())
+		if !ok {
+			return nil, fmt.Errorf("remote entrance (%s) cannot be created", t.Remote().String())
+		}
+		return listener, nil
+	}
+	listener, err := net.Listen("tcp", t.Local().String())
+	if err != nil {
+		return nil, fmt.Errorf("entrance (%s) cannot be created: %w", t.Local().String(), err)
+	}
+	return listener, nil
+}
+
+// entrance is the address connections arrive on, and exit the address they are forwarded to.
+// Remote tunnels listen on the ssh server and exit on the local host.
+func (t *Entry) entrance() *config.Address {
+	if t.tunnelData.Type == config.TunnelRemote {
+		return t.Remote()
+	}
+	return t.Local()
+}
+func (t *Entry) exit() *config.Address {
+	if t.tunnelData.Type == config.TunnelRemote {
+		return t.Local()
+	}
+	return t.Remote()
 }
 
 func (t *Entry) Stop() {
-	if t.cancel != nil {
-		t.Status.Running = "Stopping"
+	t.stop(nil)
+}
+
+// stop cancels the tunnel, recording err as the reason when it was not asked for.  It
+// reports false if the tunnel was already stopped
+func (t *Entry) stop(err error) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.state.transitionFrom(engineModels.Stopped, err, engineModels.Failed) {
+		return true
+	}
+	if t.cancel != nil && t.state.transition(engineModels.Stopping, err) {
 		t.cancel()
+		return true
 	}
+	return false
 }
 
-func (t *Entry) runningAcceptLoop(ctx context.Context, localListener net.Listener) {
-	defer func() {
-		t.Status.Running = "Stopped"
-		t.wg.Done()
-	}()
+// stopAndWait stops the tunnel and waits for its entrance to close, so the address can
+// be reused straight away
+func (t *Entry) stopAndWait() {
+	t.lock.Lock()
+	done := t.done
+	t.lock.Unlock()
+	t.Stop()
+	if done != nil {
+		<-done
+	}
+}
+
+// attach follows the tunnel's host, once the tunnel is the one the engine holds for its id
+func (t *Entry) attach() {
+	if t.host != nil {
+		t.host.AddListener(t.Id(), t.hostChanged)
+	}
+}
+
+// detach stops following the tunnel's host
+func (t *Entry) detach() {
+	if t.host != nil {
+		t.host.RemoveListener(t.Id())
+	}
+}
+
+// apply takes on the parts of a replacement that do not change how the tunnel forwards
+func (t *Entry) apply(replacement *Entry) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.configLock.Lock()
+	t.tunnelData.Name = replacement.tunnelData.Name
+	t.tunnelData.Metadata = replacement.tunnelData.Metadata
+	t.tunnelData.Retry = replacement.tunnelData.Retry
+	t.configured = replacement.configured
+	t.configLock.Unlock()
+	t.describe()
+}
+
+// forwardingChanged reports whether two configurations of a tunnel forward differently
+func forwardingChanged(current *config.Tunnel, replacement *config.Tunnel) bool {
+	a, b := *current, *replacement
+	a.Name, b.Name = "", ""
+	a.Retry, b.Retry = nil, nil
+	a.Metadata, b.Metadata = nil, nil
+	a.Status, b.Status = nil, nil
+	a.Source, b.Source = "", ""
+	return !reflect.DeepEqual(a, b)
+}
+
+func (t *Entry) acceptLoop(ctx context.Context, localListener net.Listener) {
 	for {
 		localConn, err := localListener.Accept()
 		if err != nil {
@@ -79,6 +269,9 @@ func (t *Entry) runningAcceptLoop(ctx context.Context, localListener net.Listene
 			if errors.As(err, &opErr) && opErr.Op == "accept" && opErr.Err.Error() == "use of closed network connection" {
 				// Close quietly and we're likely shutting down
 				return
+			} else if errors.Is(err, io.EOF) {
+				// Remote listeners report a closed ssh channel as EOF
+				return
 			}
 			fmt.Printf("  Error - tunnel (%s) listener accept failed: %v\n", t.Name(), err)
 			return
@@ -91,73 +284,139 @@ func (t *Entry) runningAcceptLoop(ctx context.Context, localListener net.Listene
 func (t *Entry) forward(ctx context.Context, localConn net.Conn) {
 	id := t.addConnection(localConn)
 	defer t.removeConnection(localConn)
-	if config.VerboseFlag {
-		fmt.Printf("  Info  - tunnel (%s) id:%s conneting to forward server %s\n", t.Name(), t.Id(), t.Remote().String())
-	}
 
 	var sshConn net.Conn
-	if t.host != nil {
-		if !t.host.(engineModels.HostInternal).Open() {
-			// TODO Failed to connect
-			return
-		}
-		var ok bool
-		sshConn, ok = t.host.(engineModels.HostInternal).Dial(t.Remote().String())
-		if !ok {
-			// TODO failed to connect
-			return
-		}
-	} else {
-		// Direct forward
-		var err error
-		sshConn, err = net.Dial("tcp", t.Remote().String())
-		if err != nil {
-			fmt.Printf("  Error - tunnel (%s) id:%d unable to forward to server %s\n", t.Name(), id, t.Remote().String())
-			return
-		}
+	var ok bool
+	started := time.Now()
+	switch t.tunnelData.Type {
+	case config.TunnelRemote:
+		// Reverse forward, exiting on the local host
+		sshConn, ok = t.dialDirect(id, t.Local().String())
+	case config.TunnelDynamic:
+		sshConn, ok = t.dialSocks(id, localConn)
+	default:
+		sshConn, ok = t.dial(id, t.Remote().String())
+	}
+	if !ok {
+		t.stats.Failed()
+		return
 	}
+	t.stats.Dialed(time.Since(started))
+	t.publish(events.ConnectionOpened, localConn.RemoteAddr().String())
 	NewTunnelConnection(t.Name(), t.Id(), t.stats, sshConn, localConn).Start(ctx)
+	t.publish(events.ConnectionClosed, localConn.RemoteAddr().String())
 }
 
-func (t *Entry) Validate(he engineModels.HostEngineInternal) bool {
-	t.tunnelData.Name = strings.TrimSpace(t.tunnelData.Name)
-	if t.tunnelData.Name == "" {
-		fmt.Printf("  Error - tunnel name cannot be blank\n")
-		t.Status.Valid = false
+func (t *Entry) publish(eventType string, address string) {
+	events.Publish(&events.Event{Type: eventType, Tunnel: t.Id(), Tags: events.Tags(t.Metadata()), Address: address})
+}
+
+// transitioned publishes each change of the tunnel's state
+func (t *Entry) transitioned(transition *config.Transition) {
+	if t.stats != nil {
+		t.stats.State(transition.To)
 	}
-	if t.tunnelData.Remote == nil || t.tunnelData.Remote.IsBlank() {
-		fmt.Printf("  Error - tunnel (%s) requires a forward address\n", t.tunnelData.Name)
-		t.Status.Valid = false
-	} else if !t.tunnelData.Remote.Validate("tunnel", t.tunnelData.Name, "forward address", true, false) {
-		t.Status.Valid = false
+	events.Publish(&events.Event{
+		Type:   events.TunnelState,
+		Time:   transition.At,
+		Tunnel: t.Id(),
+		Tags:   events.Tags(t.Metadata()),
+		From:   transition.From,
+		To:     transition.To,
+		Error:  transition.Error,
+	})
+}
+
+func (t *Entry) dial(id int, address string) (net.Conn, bool) {
+	if t.host == nil {
+		return t.dialDirect(id, address)
 	}
+	if config.VerboseFlag {
+		fmt.Printf("  Info  - tunnel (%s) id:%s conneting to forward server %s\n", t.Name(), t.Id(), address)
+	}
+	if !t.host.Open() {
+		// TODO Failed to connect
+		return nil, false
+	}
+	// TODO failed to connect
+	return t.host.Dial(address)
+}
 
-	if (t.tunnelData.Local == nil || t.tunnelData.Local.IsBlank()) && t.tunnelData.Remote != nil && t.tunnelData.Remote.IsValid() {
-		fmt.Printf("  Warn  - tunnel (%s) Local entrance undefined. Defaulting to 127.0.0.1:%d\n", t.tunnelData.Name, t.tunnelData.Remote.Port())
-		t.tunnelData.Local = config.NewAddress(fmt.Sprintf("127.0.0.1:%d", t.tunnelData.Remote.Port()))
+func (t *Entry) dialDirect(id int, address string) (net.Conn, bool) {
+	if config.VerboseFlag {
+		fmt.Printf("  Info  - tunnel (%s) id:%s conneting to forward server %s\n", t.Name(), t.Id(), address)
 	}
-	if t.tunnelData.Local == nil || t.tunnelData.Local.IsBlank() {
-		fmt.Printf("  Error - tunnel (%s) missing a local address that cannot be derived\n", t.tunnelData.Name)
-	} else if !t.tunnelData.Local.Validate("tunnel", t.tunnelData.Name, "local address", true, false) {
-		t.Status.Valid = false
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		fmt.Printf("  Error - tunnel (%s) id:%d unable to forward to server %s: %v\n", t.Name(), id, address, err)
+		return nil, false
+	}
+	return conn, true
+}
+
+// dialSocks reads the destination from a SOCKS5 request on the entrance connection,
+// and dials it through the tunnel host
+func (t *Entry) dialSocks(id int, localConn net.Conn) (net.Conn, bool) {
+	destination, err := socksHandshake(localConn, t.tunnelData.Socks)
+	if err != nil {
+		fmt.Printf("  Error - tunnel (%s) id:%d socks negotiation failed: %v\n", t.Name(), id, err)
+		return nil, false
+	}
+	conn, ok := t.dial(id, destination)
+	if !ok {
+		_ = socksReply(localConn, socksReplyHostUnreachable)
+		return nil, false
+	}
+	if err = socksReply(localConn, socksReplySucceeded); err != nil {
+		_ = conn.Close()
+		return nil, false
+	}
+	t.stats.Destination(destination)
+	return conn, true
+}
+
+func (t *Entry) Validate(v *config.Validations, he engineModels.HostEngineInternal) bool {
+	t.tunnelData.Name = strings.TrimSpace(t.tunnelData.Name)
+	if t.tunnelData.Name == "" {
+		v.Errorf("tunnel name cannot be blank")
+		t.tunnelData.Status.Valid = false
+	}
+
+	t.tunnelData.Type = strings.ToLower(strings.TrimSpace(t.tunnelData.Type))
+	switch t.tunnelData.Type {
+	case "", config.TunnelLocal:
+		t.tunnelData.Type = config.TunnelLocal
+		t.validateLocal(v)
+	case config.TunnelRemote:
+		t.validateRemote(v)
+	case config.TunnelDynamic:
+		t.validateDynamic(v)
+	default:
+		v.Errorf("tunnel (%s) type (%s) is invalid.  Must be one of: local, remote, dynamic", t.tunnelData.Name, t.tunnelData.Type)
+		t.tunnelData.Status.Valid = false
 	}
 
 	t.tunnelData.Host = strings.TrimSpace(t.tunnelData.Host)
 	if t.tunnelData.Host == "" {
-		fmt.Printf("  Info  - tunnel (%s) exits on the local host\n", t.tunnelData.Name)
+		if t.tunnelData.Type == config.TunnelRemote {
+			v.Errorf("tunnel (%s) remote forwarding requires a host", t.tunnelData.Name)
+			t.tunnelData.Status.Valid = false
+		} else {
+			v.Infof("tunnel (%s) exits on the local host", t.tunnelData.Name)
+		}
 	} else if host, ok := he.Host(t.tunnelData.Host); !ok {
-		fmt.Printf("  Error - tunnel (%s) remote host (%s) undefined\n", t.tunnelData.Name, t.tunnelData.Host)
-		t.Status.Valid = false
+		v.Errorf("tunnel (%s) remote host (%s) undefined", t.tunnelData.Name, t.tunnelData.Host)
+		t.tunnelData.Status.Valid = false
 	} else if !host.Valid() {
-		fmt.Printf("  Error - tunnel (%s) remote host (%s) is invalid\n", t.tunnelData.Name, t.tunnelData.Host)
-		t.Status.Valid = false
-	} else if t.Status.Valid {
+		v.Errorf("tunnel (%s) remote host (%s) is invalid", t.tunnelData.Name, t.tunnelData.Host)
+		t.tunnelData.Status.Valid = false
+	} else if t.tunnelData.Status.Valid {
 		t.host = host.(engineModels.HostInternal)
 		t.host.Referenced()
 	}
 
-	if config.VerboseFlag && t.Status.Valid {
-		fmt.Printf("  Info  - tunnel (%s) validated\n", t.tunnelData.Name)
+	if t.tunnelData.Status.Valid {
+		v.Infof("tunnel (%s) validated", t.tunnelData.Name)
 	}
 
 	//t.stats = &TunnelStats{
@@ -165,15 +424,84 @@ func (t *Entry) Validate(he engineModels.HostEngineInternal) bool {
 	//	Port: t.Local.Port(),
 	//}
 
-	return t.Status.Valid
+	return t.tunnelData.Status.Valid
+}
+
+func (t *Entry) validateLocal(v *config.Validations) {
+	if t.tunnelData.Remote == nil || t.tunnelData.Remote.IsBlank() {
+		v.Errorf("tunnel (%s) requires a forward address", t.tunnelData.Name)
+		t.tunnelData.Status.Valid = false
+	} else if !t.tunnelData.Remote.Validate("tunnel", t.tunnelData.Name, "forward address", true, false) {
+		v.Errorf("tunnel (%s) forward address (%s) is invalid", t.tunnelData.Name, t.tunnelData.Remote.Configured())
+		t.tunnelData.Status.Valid = false
+	}
+
+	if (t.tunnelData.Local == nil || t.tunnelData.Local.IsBlank()) && t.tunnelData.Remote != nil && t.tunnelData.Remote.IsValid() {
+		fmt.Printf("  Warn  - tunnel (%s) Local entrance undefined. Defaulting to 127.0.0.1:%d\n", t.tunnelData.Name, t.tunnelData.Remote.Port())
+		t.tunnelData.Local = config.NewAddress(fmt.Sprintf("127.0.0.1:%d", t.tunnelData.Remote.Port()))
+	}
+	if t.tunnelData.Local == nil || t.tunnelData.Local.IsBlank() {
+		v.Errorf("tunnel (%s) missing a local address that cannot be derived", t.tunnelData.Name)
+	} else if !t.tunnelData.Local.Validate("tunnel", t.tunnelData.Name, "local address", true, false) {
+		v.Errorf("tunnel (%s) local address (%s) is invalid", t.tunnelData.Name, t.tunnelData.Local.Configured())
+		t.tunnelData.Status.Valid = false
+	}
+}
+
+// validateRemote checks a reverse tunnel, where remote is the address the ssh server listens
+// on and local is the address accepted connections are forwarded to
+func (t *Entry) validateRemote(v *config.Validations) {
+	if t.tunnelData.Remote == nil || t.tunnelData.Remote.IsBlank() {
+		v.Errorf("tunnel (%s) requires a remote entrance address", t.tunnelData.Name)
+		t.tunnelData.Status.Valid = false
+	} else if !t.tunnelData.Remote.Validate("tunnel", t.tunnelData.Name, "remote entrance", true, false) {
+		v.Errorf("tunnel (%s) remote entrance (%s) is invalid", t.tunnelData.Name, t.tunnelData.Remote.Configured())
+		t.tunnelData.Status.Valid = false
+	}
+
+	if (t.tunnelData.Local == nil || t.tunnelData.Local.IsBlank()) && t.tunnelData.Remote != nil && t.tunnelData.Remote.IsValid() {
+		fmt.Printf("  Warn  - tunnel (%s) Local exit undefined. Defaulting to 127.0.0.1:%d\n", t.tunnelData.Name, t.tunnelData.Remote.Port())
+		t.tunnelData.Local = config.NewAddress(fmt.Sprintf("127.0.0.1:%d", t.tunnelData.Remote.Port()))
+	}
+	if t.tunnelData.Local == nil || t.tunnelData.Local.IsBlank() {
+		v.Errorf("tunnel (%s) missing a local exit address that cannot be derived", t.tunnelData.Name)
+		t.tunnelData.Status.Valid = false
+	} else if !t.tunnelData.Local.Validate("tunnel", t.tunnelData.Name, "local exit", false, false) {
+		v.Errorf("tunnel (%s) local exit (%s) is invalid", t.tunnelData.Name, t.tunnelData.Local.Configured())
+		t.tunnelData.Status.Valid = false
+	}
+}
+
+// validateDynamic checks a socks tunnel, where the destination is chosen by the client
+// so a forward address is optional
+func (t *Entry) validateDynamic(v *config.Validations) {
+	if t.tunnelData.Remote != nil && !t.tunnelData.Remote.IsBlank() {
+		fmt.Printf("  Warn  - tunnel (%s) forward address (%s) is ignored by dynamic tunnels\n", t.tunnelData.Name, t.tunnelData.Remote.Configured())
+	}
+	if t.tunnelData.Local == nil || t.tunnelData.Local.IsBlank() {
+		v.Errorf("tunnel (%s) dynamic forwarding requires a local address", t.tunnelData.Name)
+		t.tunnelData.Status.Valid = false
+	} else if !t.tunnelData.Local.Validate("tunnel", t.tunnelData.Name, "local address", true, false) {
+		v.Errorf("tunnel (%s) local address (%s) is invalid", t.tunnelData.Name, t.tunnelData.Local.Configured())
+		t.tunnelData.Status.Valid = false
+	}
+	if t.tunnelData.Socks != nil && t.tunnelData.Socks.Username == "" && t.tunnelData.Socks.Password != "" {
+		v.Errorf("tunnel (%s) socks password requires a username", t.tunnelData.Name)
+		t.tunnelData.Status.Valid = false
+	}
 }
 
 func (t *Entry) Id() string {
 	return t.tunnelData.Id
 }
 func (t *Entry) Name() string {
+	t.configLock.RLock()
+	defer t.configLock.RUnlock()
 	return t.tunnelData.Name
 }
+func (t *Entry) Type() string {
+	return t.tunnelData.Type
+}
 func (t *Entry) Local() *config.Address {
 	return t.tunnelData.Local
 }
@@ -187,23 +515,84 @@ func (t *Entry) Valid() bool {
 	return t.tunnelData.Status.Valid
 }
 func (t *Entry) Running() string {
-	return t.tunnelData.Status.Running
+	return t.state.get().String()
+}
+func (t *Entry) Socks() *config.Socks {
+	t.configLock.RLock()
+	defer t.configLock.RUnlock()
+	return t.tunnelData.Socks
+}
+func (t *Entry) Retry() *config.Retry {
+	t.configLock.RLock()
+	defer t.configLock.RUnlock()
+	return t.tunnelData.Retry
+}
+func (t *Entry) Status() *config.Status {
+	return t.state.status(t.tunnelData.Status.Valid)
 }
 func (t *Entry) Metadata() *config.Metadata {
+	t.configLock.RLock()
+	defer t.configLock.RUnlock()
 	return t.tunnelData.Metadata
 }
 
-func (t *Entry) waitForTermination(ctx context.Context, localListener net.Listener) {
-	<-ctx.Done()
-	fmt.Printf("  Info  - tunnel (%s) stopped listening on %s\n", t.Name(), t.Local().String())
-	_ = localListener.Close()
+func (t *Entry) configuredId() string {
+	t.configLock.RLock()
+	defer t.configLock.RUnlock()
+	return t.configured.Id
+}
+
+// config is a copy of the tunnel's configuration, safe from apply changing it
+func (t *Entry) config() *config.Tunnel {
+	t.configLock.RLock()
+	defer t.configLock.RUnlock()
+	copied := *t.tunnelData.Tunnel
+	return &copied
+}
+
+// Stats returns the tunnel's stats, which are nil until the tunnels are started
+func (t *Entry) Stats() engineModels.Stats {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.stats
+}
+
+// hostChanged closes the tunnel's connections once its host is lost, rather than leaving
+// them to hang until the tcp timeout, and marks the tunnel degraded until it returns.
+// Remote tunnels lose their listener with the host, and are woken from any backoff
+// once it reconnects
+func (t *Entry) hostChanged(host engineModels.Host, connected bool) {
+	if connected {
+		t.state.transitionFrom(engineModels.Started, nil, engineModels.Degraded)
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+		return
+	}
+	if !host.Valid() {
+		// Only a host that has been removed turns invalid, so there is nothing to wait for
+		err := fmt.Errorf("host (%s) removed", host.Name())
+		if t.stop(err) {
+			fmt.Printf("  Warn  - tunnel (%s) %v.  Stopped\n", t.Name(), err)
+		}
+		return
+	}
+	err := fmt.Errorf("host (%s) disconnected", host.Name())
+	if !t.state.transitionFrom(engineModels.Degraded, err, engineModels.Started) {
+		return
+	}
+	fmt.Printf("  Warn  - tunnel (%s) host (%s) disconnected.  Closing connections\n", t.Name(), host.Name())
+	t.closeConnections()
+}
+
+func (t *Entry) closeConnections() {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 	for _, conn := range t.conns {
 		_ = conn.Close()
 	}
 	t.conns = []net.Conn{}
-	t.cancel = nil
 }
 
 func (t *Entry) addConnection(conn net.Conn) int {
@@ -216,7 +605,7 @@ func (t *Entry) addConnection(conn net.Conn) int {
 func (t *Entry) removeConnection(conn net.Conn) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
-	conns := make([]net.Conn, 0, len(t.conns)-1)
+	conns := make([]net.Conn, 0, len(t.conns))
 	for _, c := range t.conns {
 		if conn != c {
 			conns = append(conns, c)
@@ -0,0 +1,172 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package tunnel
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+const (
+	socksVersion     = 0x05
+	socksAuthVersion = 0x01
+
+	socksMethodNone         = 0x00
+	socksMethodPassword     = 0x02
+	socksMethodNoAcceptable = 0xff
+
+	socksCmdConnect = 0x01
+
+	socksAddrIPv4   = 0x01
+	socksAddrDomain = 0x03
+	socksAddrIPv6   = 0x04
+
+	socksReplySucceeded           = 0x00
+	socksReplyFailure             = 0x01
+	socksReplyHostUnreachable     = 0x04
+	socksReplyCommandNotSupported = 0x07
+	socksReplyAddressNotSupported = 0x08
+)
+
+var (
+	errSocksVersion     = errors.New("unsupported socks version")
+	errSocksMethod      = errors.New("no acceptable socks authentication method")
+	errSocksAuth        = errors.New("socks authentication failed")
+	errSocksCommand     = errors.New("unsupported socks command")
+	errSocksAddressType = errors.New("unsupported socks address type")
+
+	socksHandshakeTimeout = 30 * time.Second
+)
+
+// socksHandshake negotiates a SOCKS5 CONNECT request on conn, and returns the
+// destination address requested by the client
+func socksHandshake(conn net.Conn, auth *config.Socks) (string, error) {
+	_ = conn.SetDeadline(time.Now().Add(socksHandshakeTimeout))
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	// Greeting: version, method count, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socksVersion {
+		return "", fmt.Errorf("%w: %d", errSocksVersion, header[0])
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+
+	method := byte(socksMethodNone)
+	if auth != nil && auth.Username != "" {
+		method = socksMethodPassword
+	}
+	if !slices.Contains(methods, method) {
+		_, _ = conn.Write([]byte{socksVersion, socksMethodNoAcceptable})
+		return "", errSocksMethod
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+	if method == socksMethodPassword {
+		if err := socksAuthenticate(conn, auth); err != nil {
+			return "", err
+		}
+	}
+
+	// Request: version, command, reserved, address type
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return "", err
+	}
+	if request[0] != socksVersion {
+		return "", fmt.Errorf("%w: %d", errSocksVersion, request[0])
+	}
+	if request[1] != socksCmdConnect {
+		_ = socksReply(conn, socksReplyCommandNotSupported)
+		return "", fmt.Errorf("%w: %d", errSocksCommand, request[1])
+	}
+
+	var host string
+	switch request[3] {
+	case socksAddrIPv4, socksAddrIPv6:
+		size := net.IPv4len
+		if request[3] == socksAddrIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socksAddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		_ = socksReply(conn, socksReplyAddressNotSupported)
+		return "", fmt.Errorf("%w: %d", errSocksAddressType, request[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksAuthenticate performs the RFC 1929 username/password sub-negotiation
+func socksAuthenticate(conn net.Conn, auth *config.Socks) error {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socksAuthVersion {
+		return fmt.Errorf("%w: %d", errSocksVersion, header[0])
+	}
+	username := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, username); err != nil {
+		return err
+	}
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return err
+	}
+	password := make([]byte, length[0])
+	if _, err := io.ReadFull(conn, password); err != nil {
+		return err
+	}
+
+	userOk := subtle.ConstantTimeCompare(username, []byte(auth.Username))
+	passOk := subtle.ConstantTimeCompare(password, []byte(auth.Password))
+	if userOk&passOk != 1 {
+		_, _ = conn.Write([]byte{socksAuthVersion, socksReplyFailure})
+		return errSocksAuth
+	}
+	_, err := conn.Write([]byte{socksAuthVersion, socksReplySucceeded})
+	return err
+}
+
+// socksReply answers a CONNECT request.  The bound address is not meaningful for a
+// forwarded connection, so it is always reported as 0.0.0.0:0
+func socksReply(conn net.Conn, reply byte) error {
+	_, err := conn.Write([]byte{socksVersion, reply, 0x00, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
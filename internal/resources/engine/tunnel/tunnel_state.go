@@ -0,0 +1,130 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package tunnel
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+)
+
+var (
+	maxTransitions = 20
+)
+
+// state is where a tunnel is in its lifecycle.  Every change goes through transition,
+// which refuses moves the lifecycle does not allow and records when each one happened
+type state struct {
+	lock        sync.Mutex
+	current     engineModels.Running
+	since       time.Time
+	lastError   string
+	attempts    int
+	nextRetry   *time.Time
+	transitions []*config.Transition
+	now         func() time.Time
+	notify      func(transition *config.Transition)
+}
+
+func newState() *state {
+	return &state{
+		current: engineModels.Stopped,
+		since:   time.Now(),
+		now:     time.Now,
+	}
+}
+
+func (s *state) get() engineModels.Running {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.current
+}
+
+// transition moves to a new state, reporting false if the current state cannot move there
+func (s *state) transition(to engineModels.Running, err error) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.transitionLocked(to, err)
+}
+
+// transitionFrom moves to a new state only if the tunnel is currently in one of from
+func (s *state) transitionFrom(to engineModels.Running, err error, from ...engineModels.Running) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, f := range from {
+		if s.current == f {
+			return s.transitionLocked(to, err)
+		}
+	}
+	return false
+}
+
+func (s *state) transitionLocked(to engineModels.Running, err error) bool {
+	if !s.current.CanTransition(to) {
+		if config.VerboseFlag {
+			fmt.Printf("  Warn  - tunnel state %s cannot move to %s\n", s.current, to)
+		}
+		return false
+	}
+	record := &config.Transition{
+		From: s.current.String(),
+		To:   to.String(),
+		At:   s.now(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+		s.lastError = record.Error
+	}
+	s.transitions = append(s.transitions, record)
+	if len(s.transitions) > maxTransitions {
+		s.transitions = s.transitions[len(s.transitions)-maxTransitions:]
+	}
+	s.current = to
+	s.since = record.At
+	if s.notify != nil {
+		s.notify(record)
+	}
+	if to != engineModels.Reconnecting {
+		s.attempts, s.nextRetry = 0, nil
+	}
+	return true
+}
+
+// retrying records the next attempt to recover, and the error that made it necessary
+func (s *state) retrying(attempts int, next time.Time, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err != nil {
+		s.lastError = err.Error()
+	}
+	s.attempts = attempts
+	s.nextRetry = &next
+}
+
+func (s *state) status(valid bool) *config.Status {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	since := s.since
+	status := &config.Status{
+		Valid:       valid,
+		Running:     s.current.String(),
+		Since:       &since,
+		LastError:   s.lastError,
+		Attempts:    s.attempts,
+		Transitions: make([]*config.Transition, len(s.transitions)),
+	}
+	if s.nextRetry != nil {
+		next := *s.nextRetry
+		status.NextRetry = &next
+	}
+	for i, transition := range s.transitions {
+		copied := *transition
+		status.Transitions[i] = &copied
+	}
+	return status
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package tunnel
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+)
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     engineModels.Running
+		to       engineModels.Running
+		expected bool
+	}{
+		{"start", engineModels.Stopped, engineModels.Starting, true},
+		{"started", engineModels.Starting, engineModels.Started, true},
+		{"degrade", engineModels.Started, engineModels.Degraded, true},
+		{"recover", engineModels.Degraded, engineModels.Started, true},
+		{"lost entrance", engineModels.Started, engineModels.Reconnecting, true},
+		{"give up", engineModels.Reconnecting, engineModels.Failed, true},
+		{"restart failed", engineModels.Failed, engineModels.Starting, true},
+		{"stop failed", engineModels.Failed, engineModels.Stopped, true},
+		{"stop", engineModels.Started, engineModels.Stopping, true},
+		{"stopped", engineModels.Stopping, engineModels.Stopped, true},
+		{"start twice", engineModels.Started, engineModels.Starting, false},
+		{"skip stopping", engineModels.Started, engineModels.Stopped, false},
+		{"stopped to started", engineModels.Stopped, engineModels.Started, false},
+		{"fail while stopping", engineModels.Stopping, engineModels.Failed, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newState()
+			s.current = test.from
+			assert.Equal(t, test.expected, s.transition(test.to, nil))
+			if test.expected {
+				assert.Equal(t, test.to, s.get())
+			} else {
+				assert.Equal(t, test.from, s.get())
+			}
+		})
+	}
+}
+
+func TestTransitionStatus(t *testing.T) {
+	at := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := newState()
+	s.now = func() time.Time { return at }
+
+	assert.True(t, s.transition(engineModels.Starting, nil))
+	assert.True(t, s.transition(engineModels.Reconnecting, errors.New("address in use")))
+	s.retrying(1, at.Add(time.Second), nil)
+	status := s.status(true)
+	assert.Equal(t, "Reconnecting", status.Running)
+	assert.Equal(t, "address in use", status.LastError)
+	assert.Equal(t, 1, status.Attempts)
+	assert.Equal(t, at.Add(time.Second), *status.NextRetry)
+	assert.Equal(t, at, *status.Since)
+
+	assert.True(t, s.transition(engineModels.Started, nil))
+	status = s.status(true)
+	assert.Equal(t, "Started", status.Running)
+	assert.Equal(t, "address in use", status.LastError)
+	assert.Equal(t, 0, status.Attempts)
+	assert.Nil(t, status.NextRetry)
+	assert.Len(t, status.Transitions, 3)
+	assert.Equal(t, "Reconnecting", status.Transitions[1].To)
+	assert.Equal(t, "address in use", status.Transitions[1].Error)
+}
+
+func TestTransitionHistoryBounded(t *testing.T) {
+	s := newState()
+	s.transition(engineModels.Starting, nil)
+	s.transition(engineModels.Started, nil)
+	for range maxTransitions {
+		s.transition(engineModels.Degraded, nil)
+		s.transition(engineModels.Started, nil)
+	}
+	status := s.status(true)
+	assert.Len(t, status.Transitions, maxTransitions)
+	assert.Equal(t, "Started", status.Transitions[maxTransitions-1].To)
+}
+
+func TestTransitionConcurrent(t *testing.T) {
+	s := newState()
+	s.transition(engineModels.Starting, nil)
+	s.transition(engineModels.Started, nil)
+	var wg sync.WaitGroup
+	stopped := 0
+	var lock sync.Mutex
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.transition(engineModels.Stopping, nil) {
+				lock.Lock()
+				stopped++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, stopped)
+}
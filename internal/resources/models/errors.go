@@ -0,0 +1,16 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package models
+
+import (
+	"errors"
+)
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already defined")
+	ErrInvalid  = errors.New("definition invalid")
+	ErrInUse    = errors.New("still in use")
+)
@@ -14,6 +14,11 @@ type HostEngine interface {
 	Hosts() []Host
 	Host(string) (Host, bool)
 	KnownHosts() []string
+	Configs() []*config.Host
+	Configured() []*config.Host
+	AddHost(host *config.Host) (Host, error)
+	UpdateHost(id string, host *config.Host) (Host, error)
+	RemoveHost(id string, force bool) ([]string, error)
 }
 
 type HostEngineInternal interface {
@@ -29,13 +34,25 @@ type Host interface {
 	Identity() string
 	KnownHosts() string
 	JumpHost() string
+	Auth() *config.Auth
+	KeepAlive() *config.KeepAlive
+	Retry() *config.Retry
 	Valid() bool
+	Connected() bool
 	Metadata() *config.Metadata
+	Stats() HostStats
+	Status() *config.HostStatus
 }
 
 type HostInternal interface {
 	Host
 	Open() bool
 	Dial(address string) (net.Conn, bool)
+	Listen(address string) (net.Listener, bool)
 	Referenced()
+	AddListener(id string, listener HostListener)
+	RemoveListener(id string)
 }
+
+// HostListener is notified when a host connects, or its connection is lost
+type HostListener func(host Host, connected bool)
@@ -7,7 +7,7 @@ package models
 type Running int
 
 var (
-	entries = [...]string{"Stopped", "Starting", "Started", "Stopping"}
+	entries = [...]string{"Stopped", "Starting", "Started", "Stopping", "Reconnecting", "Degraded", "Failed"}
 )
 
 const (
@@ -15,12 +15,42 @@ const (
 	Starting
 	Started
 	Stopping
+	Reconnecting
+	Degraded
+	Failed
 )
 
-func RunningEnums() [4]string {
+// transitions lists the states each state may move to.  Degraded tunnels are listening
+// but have lost their host, Reconnecting tunnels have lost their entrance, and Failed
+// tunnels have exhausted their retries
+var transitions = map[Running][]Running{
+	Stopped:      {Starting},
+	Starting:     {Started, Reconnecting, Failed, Stopping},
+	Started:      {Degraded, Reconnecting, Stopping},
+	Degraded:     {Started, Reconnecting, Stopping},
+	Reconnecting: {Started, Failed, Stopping},
+	Stopping:     {Stopped},
+	Failed:       {Starting, Stopped},
+}
+
+func RunningEnums() [len(entries)]string {
 	return entries
 
 }
+
+// RunningTransitions maps each state to the states it may move to
+func RunningTransitions() map[string][]string {
+	result := make(map[string][]string, len(transitions))
+	for from, tos := range transitions {
+		names := make([]string, len(tos))
+		for i, to := range tos {
+			names[i] = to.String()
+		}
+		result[from.String()] = names
+	}
+	return result
+}
+
 func (r Running) String() string {
 	return entries[r]
 }
@@ -28,3 +58,12 @@ func (r Running) String() string {
 func (r Running) Index() int {
 	return int(r)
 }
+
+func (r Running) CanTransition(to Running) bool {
+	for _, allowed := range transitions[r] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
@@ -6,11 +6,20 @@ package models
 
 import (
 	"context"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+var (
+	// DialBuckets are the upper bounds, in seconds, dial latencies are counted against
+	DialBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
 )
 
 type StatsEngine interface {
 	StartStatsTunnel(ctx context.Context, port int) error
 	NewEntry() Stats
+	RemoveEntry(stats Stats)
 }
 
 type Stats interface {
@@ -18,5 +27,32 @@ type Stats interface {
 	Disconnected()
 	Received(i int64)
 	Transmitted(i int64)
+	Destination(address string)
 	Updated()
+	Failed()
+	Dialed(latency time.Duration)
+	Reconnecting()
+	Describe(id string, name string, port int, jump bool, metadata *config.Metadata)
+	State(state string)
+	Snapshot() *StatsSnapshot
+}
+
+// StatsSnapshot is a copy of a tunnel's stats at a point in time.  DialCounts holds the
+// number of dials that took no longer than each of DialBuckets
+type StatsSnapshot struct {
+	Received    int64
+	Transmitted int64
+	Open        int
+	Connections int
+	Failures    int
+	Reconnects  int
+	DialCounts  []uint64
+	DialCount   uint64
+	DialSeconds float64
+}
+
+// HostStats counts the connection problems of a host
+type HostStats struct {
+	Failures   int64
+	Reconnects int64
 }
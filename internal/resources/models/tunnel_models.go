@@ -14,18 +14,28 @@ import (
 type TunnelEngine interface {
 	Tunnels() []Tunnel
 	Tunnel(string) (Tunnel, bool)
+	Configs() []*config.Tunnel
+	Configured() []*config.Tunnel
 	StartTunnels(ctx context.Context, stats StatsEngine, wg *sync.WaitGroup)
+	AddTunnel(tunnel *config.Tunnel, start bool) (Tunnel, error)
+	UpdateTunnel(id string, tunnel *config.Tunnel) (Tunnel, error)
+	RemoveTunnel(id string) error
 }
 
 type Tunnel interface {
 	Id() string
 	Name() string
+	Type() string
 	Local() *config.Address
 	Remote() *config.Address
 	Host() string
+	Socks() *config.Socks
 	Valid() bool
 	Running() string
+	Retry() *config.Retry
+	Status() *config.Status
 	Metadata() *config.Metadata
+	Stats() Stats
 	Start()
 	Stop()
 }
@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"us.figge.auto-ssh/internal/core/config"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+// authenticate identifies the caller by its client certificate, if it maps to one of
+// web.clients, or else by a bearer token matching one of web.tokens.  Requests that
+// are neither are rejected.  The api is open when no tokens or client CA are configured,
+// which validation only allows on a loopback address or with web.insecure.
+// Requests on the unix socket need neither, the socket's permissions having let them in
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		if socketRequest(req) {
+			next.ServeHTTP(resp, req.WithContext(managerModels.WithIdentity(req.Context(), socketIdentity)))
+			return
+		}
+		if len(s.webCfg.Tokens) == 0 && s.webCfg.ClientCA == "" {
+			next.ServeHTTP(resp, req)
+			return
+		}
+		var identity *managerModels.Identity
+		if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 {
+			identity = certificateIdentity(s.webCfg.Clients, req.TLS.VerifiedChains[0][0])
+		}
+		if token, ok := bearerToken(req); identity == nil && ok {
+			if matched := matchToken(s.webCfg.Tokens, token); matched != nil {
+				identity = &managerModels.Identity{Name: matched.Name, Role: matched.Role, Tags: matched.Tags}
+			}
+		}
+		if identity == nil {
+			unauthorized(resp)
+			return
+		}
+		next.ServeHTTP(resp, req.WithContext(managerModels.WithIdentity(req.Context(), identity)))
+	})
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// matchToken checks token against every configured token, so the time taken does not
+// reveal which, if any, matched
+func matchToken(tokens []*config.Token, token string) *config.Token {
+	var matched *config.Token
+	for _, t := range tokens {
+		if t.Matches(token) && matched == nil {
+			matched = t
+		}
+	}
+	return matched
+}
+
+func unauthorized(resp http.ResponseWriter) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Header().Set("WWW-Authenticate", `Bearer realm="auto-ssh"`)
+	resp.WriteHeader(http.StatusUnauthorized)
+	_ = json.NewEncoder(resp).Encode(&managerModels.ErrorOutput{Error: "unauthorized"})
+}
+
+func (s *Server) validateTokens(v *config.Validations) {
+	names := make(map[string]bool)
+	for i, token := range s.webCfg.Tokens {
+		token.Name = strings.TrimSpace(token.Name)
+		if token.Name == "" {
+			v.Errorf("web.tokens[%d] requires a name", i)
+		} else if names[token.Name] {
+			v.Errorf("web.tokens name (%s) redefined", token.Name)
+		}
+		names[token.Name] = true
+		if !token.ValidHash() {
+			v.Errorf("web.tokens (%s) hash must be sha256:<hex>.  See ash token", token.Name)
+		}
+		token.Role = strings.ToLower(strings.TrimSpace(token.Role))
+		if token.Role == "" {
+			token.Role = config.RoleReadOnly
+		} else if !slices.Contains(config.Roles(), token.Role) {
+			v.Errorf("web.tokens (%s) role (%s) is invalid.  Must be one of: %s", token.Name, token.Role, strings.Join(config.Roles(), ", "))
+		}
+	}
+	if len(s.webCfg.Tokens) == 0 && s.webCfg.ClientCA == "" {
+		if ip := net.ParseIP(s.webCfg.Address); ip == nil || !ip.IsLoopback() {
+			if !s.webCfg.Insecure {
+				v.Errorf("web.tokens and web.clientCA not set.  Refusing to open the api to anyone that can reach %s.  "+
+					"Set either, a loopback web.address or web.insecure", s.webCfg.Address)
+				return
+			}
+			// Printed whether or not validations are shown, as the api is open
+			fmt.Printf("  Warn  - web.insecure set.  The api is open to anyone that can reach %s\n", s.webCfg.Address)
+		}
+	}
+}
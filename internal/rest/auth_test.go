@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"us.figge.auto-ssh/internal/core/config"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+func TestAuthenticate(t *testing.T) {
+	s := &Server{webCfg: &config.Web{Tokens: []*config.Token{
+		{Name: "ci", Hash: config.HashToken("secret"), Role: config.RoleOperator, Tags: []string{"prod"}},
+	}}}
+	var identity *managerModels.Identity
+	handler := s.authenticate(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		identity = managerModels.IdentityFrom(req.Context())
+		resp.WriteHeader(http.StatusOK)
+	}))
+
+	for _, test := range []struct {
+		header string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Basic secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+		{"bearer secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/tunnels", nil)
+		if test.header != "" {
+			req.Header.Set("Authorization", test.header)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, test.status, resp.Code, test.header)
+		if test.status == http.StatusUnauthorized {
+			assert.JSONEq(t, `{"error":"unauthorized"}`, resp.Body.String())
+		}
+	}
+
+	assert.Equal(t, "ci", identity.Name)
+	assert.True(t, identity.Allows(config.RoleOperator))
+	assert.False(t, identity.Allows(config.RoleAdmin))
+	assert.True(t, identity.Sees([]string{"dev", "PROD"}))
+	assert.False(t, identity.Sees(nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/tunnels", nil)
+	req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, &net.UnixAddr{Name: "ash.sock", Net: "unix"}))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusOK, resp.Code)
+	assert.True(t, identity.Allows(config.RoleAdmin))
+
+	v := config.NewValidations()
+	s.webCfg.Tokens = append(s.webCfg.Tokens,
+		&config.Token{Name: "ci", Hash: "secret"},
+		&config.Token{Name: "ops", Hash: config.HashToken("ops"), Role: "root"},
+		&config.Token{Name: "default", Hash: config.HashToken("default")},
+	)
+	s.validateTokens(&v)
+	assert.Len(t, v.Validations(), 3)
+	assert.Equal(t, config.RoleReadOnly, s.webCfg.Tokens[3].Role)
+}
+
+func TestValidateOpenApi(t *testing.T) {
+	for _, test := range []struct {
+		web    config.Web
+		errors int
+	}{
+		{config.Web{Address: "127.0.0.1"}, 0},
+		{config.Web{Address: "0.0.0.0"}, 1},
+		{config.Web{Address: "0.0.0.0", Insecure: true}, 0},
+		{config.Web{Address: "0.0.0.0", ClientCA: "ca.pem"}, 0},
+		{config.Web{Address: "0.0.0.0", Tokens: []*config.Token{{Name: "ci", Hash: config.HashToken("secret")}}}, 0},
+	} {
+		s := &Server{webCfg: &test.web}
+		v := config.NewValidations()
+		s.validateTokens(&v)
+		assert.Len(t, v.Validations(), test.errors, "%+v", test.web)
+	}
+}
@@ -0,0 +1,277 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/utils"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+const (
+	TokenEnv      = "ASH_TOKEN"
+	ServerEnv     = "ASH_SERVER"
+	ClientCertEnv = "ASH_CLIENT_CERT"
+	ClientKeyEnv  = "ASH_CLIENT_KEY"
+	CACertEnv     = "ASH_CA_CERT"
+)
+
+var (
+	ErrNoServer     = errors.New("no auto-ssh api server found.  Start auto-ssh, or set web.port, --server or " + ServerEnv)
+	ErrUnauthorized = errors.New("unauthorized.  Set --token or " + TokenEnv)
+	ErrRequest      = errors.New("request failed")
+	ErrCACert       = errors.New("ca certificate file contains no pem encoded certificates")
+)
+
+const (
+	socketURL    = "http://localhost"
+	socketScheme = "unix://"
+)
+
+// Client calls the auto-ssh rest api, authenticating with a bearer token when one is set.
+// The api's unix socket is used when present
+type Client struct {
+	baseURL  string
+	socket   string
+	token    string
+	certFile string
+	keyFile  string
+	caFile   string
+	http     *http.Client
+	refused  error
+}
+
+type OptFn func(c *Client)
+
+// OptionServer sets the base url of the api, such as http://127.0.0.1:8080, or the path
+// of its socket, such as unix:///run/user/1000/auto-ssh/ash.sock
+func OptionServer(server string) OptFn {
+	return func(c *Client) {
+		if strings.HasPrefix(server, socketScheme) {
+			c.socket, c.baseURL = strings.TrimPrefix(server, socketScheme), socketURL
+		} else if server != "" {
+			c.socket, c.baseURL = "", strings.TrimSuffix(server, "/")
+		}
+	}
+}
+
+// OptionSocket uses the api's unix socket, if it is present and trusted
+func OptionSocket(path string) OptFn {
+	return func(c *Client) {
+		if path != "" && c.trusted(path) {
+			c.socket, c.baseURL = path, socketURL
+		}
+	}
+}
+
+// OptionWeb derives the base url of the api from the web configuration it is served with,
+// preferring its socket
+func OptionWeb(web *config.Web) OptFn {
+	return func(c *Client) {
+		if path := web.SocketPath(); path != "" && c.trusted(path) {
+			c.socket, c.baseURL = path, socketURL
+			return
+		}
+		if web == nil || web.Port == 0 {
+			return
+		}
+		c.socket = ""
+		scheme := "http"
+		if web.CertificateFile != "" {
+			scheme = "https"
+		}
+		address := web.Address
+		if ip := net.ParseIP(address); address == "" || (ip != nil && ip.IsUnspecified()) {
+			address = "127.0.0.1"
+		}
+		c.baseURL = fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(address, strconv.Itoa(int(web.Port))))
+	}
+}
+
+// trusted reports whether path is a socket that can be connected to.  One that is refused
+// is reported if no other way to reach the api is found
+func (c *Client) trusted(path string) bool {
+	if !config.IsSocket(path) {
+		return false
+	}
+	if err := config.TrustedSocket(path); err != nil {
+		c.refused = err
+		return false
+	}
+	return true
+}
+
+func OptionToken(token string) OptFn {
+	return func(c *Client) {
+		if token != "" {
+			c.token = token
+		}
+	}
+}
+
+// OptionTLS sets the client certificate presented to a server requiring one, and the CA
+// certificates its own certificate is verified with
+func OptionTLS(certFile string, keyFile string, caFile string) OptFn {
+	return func(c *Client) {
+		c.certFile = utils.DefaultString(certFile, c.certFile)
+		c.keyFile = utils.DefaultString(keyFile, c.keyFile)
+		c.caFile = utils.DefaultString(caFile, c.caFile)
+	}
+}
+
+func OptionHTTPClient(httpClient *http.Client) OptFn {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// NewClient creates a client from the options, then the environment, then the rest
+// flags, each overriding what came before
+func NewClient(options ...OptFn) *Client {
+	c := &Client{}
+	OptionSocket(config.DefaultSocket())(c)
+	for _, option := range options {
+		option(c)
+	}
+	OptionServer(os.Getenv(ServerEnv))(c)
+	OptionToken(os.Getenv(TokenEnv))(c)
+	OptionTLS(os.Getenv(ClientCertEnv), os.Getenv(ClientKeyEnv), os.Getenv(CACertEnv))(c)
+	OptionServer(config.ServerFlag)(c)
+	OptionToken(config.TokenFlag)(c)
+	OptionTLS(config.ClientCertFlag, config.ClientKeyFlag, config.CACertFlag)(c)
+	return c
+}
+
+// httpClient builds the http client on first use, so a bad certificate is reported as
+// the error of a request
+func (c *Client) httpClient() (*http.Client, error) {
+	if c.http != nil {
+		return c.http, nil
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.certFile != "" || c.keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if c.caFile != "" {
+		bs, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(bs) {
+			return nil, fmt.Errorf("%w: %s", ErrCACert, c.caFile)
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	if c.socket != "" {
+		transport.DialContext = func(ctx context.Context, _ string, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", c.socket)
+		}
+	}
+	c.http = &http.Client{Timeout: 30 * time.Second, Transport: transport}
+	return c.http, nil
+}
+
+// Do sends input, if any, as json and decodes the response into output
+func (c *Client) Do(ctx context.Context, method string, path string, input any, output any) error {
+	if c.baseURL == "" {
+		if c.refused != nil {
+			return fmt.Errorf("%w: %w", ErrNoServer, c.refused)
+		}
+		return ErrNoServer
+	}
+	var body []byte
+	if input != nil {
+		var err error
+		if body, err = json.Marshal(input); err != nil {
+			return err
+		}
+	}
+	url := c.baseURL + path
+	if config.CurlFlag {
+		c.printCurl(method, url, body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	httpClient, err := c.httpClient()
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case resp.StatusCode >= http.StatusBadRequest:
+		return fmt.Errorf("%w: %s: %s", ErrRequest, resp.Status, errorMessage(bs))
+	case output == nil || resp.StatusCode == http.StatusNoContent:
+		return nil
+	}
+	return json.Unmarshal(bs, output)
+}
+
+// errorMessage extracts the message of an error response, which is json or plain text
+func errorMessage(bs []byte) string {
+	output := &managerModels.ErrorOutput{}
+	if err := json.Unmarshal(bs, output); err == nil && output.Error != "" {
+		return output.Error
+	}
+	return strings.TrimSpace(string(bs))
+}
+
+// printCurl prints an equivalent curl command.  The token is left as a reference to the
+// environment so it is not echoed to the terminal
+func (c *Client) printCurl(method string, url string, body []byte) {
+	var sb strings.Builder
+	sb.WriteString("curl -X " + method)
+	if c.socket != "" {
+		sb.WriteString(" --unix-socket '" + c.socket + "'")
+	}
+	if c.token != "" {
+		sb.WriteString(` -H "Authorization: Bearer $` + TokenEnv + `"`)
+	}
+	if body != nil {
+		sb.WriteString(` -H "Content-Type: application/json" -d '` + string(body) + `'`)
+	}
+	sb.WriteString(" '" + url + "'")
+	fmt.Fprintln(os.Stderr, sb.String())
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Header.Get("Authorization") != "Bearer secret":
+			resp.WriteHeader(http.StatusUnauthorized)
+		case req.URL.Path == "/missing":
+			resp.WriteHeader(http.StatusNotFound)
+			_, _ = resp.Write([]byte("tunnel not found: missing"))
+		default:
+			_, _ = resp.Write([]byte(`{"id":"web"}`))
+		}
+	}))
+	defer server.Close()
+	t.Setenv(TokenEnv, "")
+	t.Setenv(ServerEnv, "")
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	output := struct {
+		Id string `json:"id"`
+	}{}
+	c := NewClient(OptionServer(server.URL), OptionToken("secret"))
+	require.NoError(t, c.Do(context.Background(), http.MethodGet, "/tunnels/web", nil, &output))
+	assert.Equal(t, "web", output.Id)
+
+	err := c.Do(context.Background(), http.MethodGet, "/missing", nil, &output)
+	assert.ErrorIs(t, err, ErrRequest)
+	assert.ErrorContains(t, err, "tunnel not found: missing")
+
+	t.Setenv(TokenEnv, "wrong")
+	c = NewClient(OptionServer(server.URL), OptionToken("secret"))
+	assert.ErrorIs(t, c.Do(context.Background(), http.MethodGet, "/tunnels", nil, nil), ErrUnauthorized)
+
+	assert.ErrorIs(t, NewClient().Do(context.Background(), http.MethodGet, "/tunnels", nil, nil), ErrNoServer)
+}
+
+func TestDoSocket(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(TokenEnv, "")
+	t.Setenv(ServerEnv, "")
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	socket := config.DefaultSocket()
+	require.NoError(t, os.MkdirAll(filepath.Dir(socket), 0700))
+	ln, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(resp http.ResponseWriter, req *http.Request) {
+		_, _ = resp.Write([]byte(`{"id":"web"}`))
+	}))
+	server.Listener = ln
+	server.Start()
+	defer server.Close()
+
+	output := struct {
+		Id string `json:"id"`
+	}{}
+	for _, c := range []*Client{
+		NewClient(),
+		NewClient(OptionWeb(&config.Web{Port: 8080})),
+		NewClient(OptionServer("unix://" + socket)),
+	} {
+		require.NoError(t, c.Do(context.Background(), http.MethodGet, "/tunnels/web", nil, &output))
+		assert.Equal(t, "web", output.Id)
+	}
+
+	c := NewClient(OptionWeb(&config.Web{Port: 8080, Socket: filepath.Join(dir, "missing.sock")}))
+	assert.Equal(t, "http://127.0.0.1:8080", c.baseURL)
+
+	require.NoError(t, os.Chmod(filepath.Dir(socket), 0755))
+	err = NewClient().Do(context.Background(), http.MethodGet, "/tunnels/web", nil, &output)
+	assert.ErrorIs(t, err, ErrNoServer)
+	assert.ErrorIs(t, err, config.ErrSocketDir)
+}
@@ -13,7 +13,10 @@ import (
 	"net/http"
 	"reflect"
 
+	"us.figge.auto-ssh/internal/core/config"
 	managers2 "us.figge.auto-ssh/internal/managers"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
 )
 
 const (
@@ -25,6 +28,20 @@ var (
 	ErrEncodeOutput = fmt.Errorf("failed to encode output")
 )
 
+// authorized rejects a request from a caller without the required role
+func authorized(resp http.ResponseWriter, req *http.Request, role string) bool {
+	identity := managerModels.IdentityFrom(req.Context())
+	if identity.Allows(role) {
+		return true
+	}
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(http.StatusForbidden)
+	_ = json.NewEncoder(resp).Encode(&managerModels.ErrorOutput{
+		Error: fmt.Sprintf("%v: %s requires the %s role", managers2.ErrForbidden, identity.Name, role),
+	})
+	return false
+}
+
 func handleErrorResponse(resp http.ResponseWriter, err error) {
 	httpStatus := http.StatusInternalServerError
 	switch {
@@ -32,6 +49,13 @@ func handleErrorResponse(resp http.ResponseWriter, err error) {
 		httpStatus = http.StatusNotFound
 	case errors.Is(errors.Unwrap(err), managers2.ErrTunnelNotFound):
 		httpStatus = http.StatusNotFound
+	case errors.Is(err, managers2.ErrForbidden):
+		httpStatus = http.StatusForbidden
+	case errors.Is(err, engineModels.ErrInvalid), errors.Is(err, managers2.ErrInvalidTunnel):
+		httpStatus = http.StatusBadRequest
+	case errors.Is(err, engineModels.ErrExists), errors.Is(err, engineModels.ErrInUse), errors.Is(err, managers2.ErrTunnelRunning),
+		errors.Is(err, config.ErrNoConfigFile), errors.Is(err, config.ErrConfigEdited):
+		httpStatus = http.StatusConflict
 	}
 	resp.WriteHeader(httpStatus)
 	resp.Write([]byte(err.Error()))
@@ -0,0 +1,103 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/managers"
+	"us.figge.auto-ssh/internal/resources/engine/enginetest"
+	"us.figge.auto-ssh/internal/resources/engine/host"
+	"us.figge.auto-ssh/internal/resources/engine/tunnel"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+func TestAuthorization(t *testing.T) {
+	identity := enginetest.Identity(t)
+	tagged := func(cfgHost *config.Host, tag string) *config.Host {
+		cfgHost.Metadata = &config.Metadata{Tags: []string{tag}}
+		return cfgHost
+	}
+	ctx := context.Background()
+	hosts := host.NewEngine(ctx, []*config.Host{
+		tagged(enginetest.Host(identity, "", "bastion", ""), "prod"),
+		tagged(enginetest.Host(identity, "", "sandbox", ""), "dev"),
+	})
+	web := enginetest.Tunnel("web", "127.0.0.1:18431")
+	web.Host = "bastion"
+	web.Metadata = &config.Metadata{Tags: []string{"prod"}}
+	tunnels := tunnel.NewEngine(ctx, hosts, []*config.Tunnel{web})
+	hostManager, err := managers.NewHostManager(ctx, hosts, nil)
+	require.NoError(t, err)
+	tunnelManager, err := managers.NewTunnelManager(ctx, hosts, tunnels, nil)
+	require.NoError(t, err)
+	router := mux.NewRouter()
+	NewHostRest(ctx, hostManager, router)
+	NewTunnelRest(ctx, tunnelManager, router)
+
+	call := func(identity *managerModels.Identity, method string, path string, body any) int {
+		var bs []byte
+		if body != nil {
+			bs, err = json.Marshal(body)
+			require.NoError(t, err)
+		}
+		req := httptest.NewRequest(method, path, bytes.NewReader(bs))
+		req = req.WithContext(managerModels.WithIdentity(req.Context(), identity))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp.Code
+	}
+	newHost := func(name string, tag string, jump string) *managerModels.AddHostInput {
+		return &managerModels.AddHostInput{Host: *tagged(enginetest.Host(identity, "", name, jump), tag)}
+	}
+	newTunnel := func(name string, tag string, cfgHost string) *managerModels.AddTunnelInput {
+		cfgTunnel := enginetest.Tunnel(name, "127.0.0.1:18432")
+		cfgTunnel.Host = cfgHost
+		cfgTunnel.Metadata = &config.Metadata{Tags: []string{tag}}
+		return &managerModels.AddTunnelInput{Tunnel: *cfgTunnel}
+	}
+
+	mutations := []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodPost, "/hosts", newHost("db", "prod", "")},
+		{http.MethodPut, "/hosts/bastion", newHost("bastion", "prod", "")},
+		{http.MethodDelete, "/hosts/bastion", nil},
+		{http.MethodPost, "/tunnels", newTunnel("api", "prod", "")},
+		{http.MethodPut, "/tunnels/web", newTunnel("web", "prod", "bastion")},
+		{http.MethodDelete, "/tunnels/web", nil},
+	}
+	for _, role := range []string{config.RoleReadOnly, config.RoleOperator} {
+		caller := &managerModels.Identity{Name: role, Role: role}
+		for _, m := range mutations {
+			assert.Equal(t, http.StatusForbidden, call(caller, m.method, m.path, m.body), "%s %s %s", role, m.method, m.path)
+		}
+	}
+	viewer := &managerModels.Identity{Name: "viewer", Role: config.RoleReadOnly}
+	assert.Equal(t, http.StatusForbidden, call(viewer, http.MethodPatch, "/tunnels/web/start", nil))
+	assert.Equal(t, http.StatusForbidden, call(viewer, http.MethodPatch, "/tunnels/web/stop", nil))
+	assert.Equal(t, http.StatusOK, call(viewer, http.MethodGet, "/hosts/bastion", nil))
+
+	scoped := &managerModels.Identity{Name: "prod", Role: config.RoleAdmin, Tags: []string{"prod"}}
+	assert.Equal(t, http.StatusForbidden, call(scoped, http.MethodPost, "/hosts", newHost("db", "dev", "")))
+	assert.Equal(t, http.StatusForbidden, call(scoped, http.MethodPost, "/hosts", newHost("db", "prod", "sandbox")))
+	assert.Equal(t, http.StatusForbidden, call(scoped, http.MethodPut, "/hosts/bastion", newHost("bastion", "prod", "sandbox")))
+	assert.Equal(t, http.StatusForbidden, call(scoped, http.MethodPost, "/tunnels", newTunnel("api", "prod", "sandbox")))
+	assert.Equal(t, http.StatusForbidden, call(scoped, http.MethodPut, "/tunnels/web", newTunnel("web", "prod", "sandbox")))
+	assert.Equal(t, http.StatusNotFound, call(scoped, http.MethodDelete, "/hosts/sandbox", nil))
+	assert.Equal(t, http.StatusOK, call(scoped, http.MethodPost, "/hosts", newHost("db", "prod", "bastion")))
+	assert.Equal(t, http.StatusOK, call(scoped, http.MethodPost, "/tunnels", newTunnel("api", "prod", "db")))
+}
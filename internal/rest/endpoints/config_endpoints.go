@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"us.figge.auto-ssh/internal/core/config"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+type ConfigRest struct {
+	manager managerModels.Config
+}
+
+func NewConfigRest(ctx context.Context, manager managerModels.Config, router *mux.Router) {
+	apis := &ConfigRest{
+		manager: manager,
+	}
+	router.Methods(http.MethodGet).Path("/config").HandlerFunc(apis.Get)
+	router.Methods(http.MethodPost).Path("/config/save").HandlerFunc(apis.Save)
+}
+
+func (c ConfigRest) Get(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
+	output, err := c.manager.GetConfig(req.Context(), &managerModels.GetConfigInput{}, extractConfigOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
+	}
+	handleOutputResponse(resp, output)
+}
+
+func (c ConfigRest) Save(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleAdmin) {
+		return
+	}
+	output, err := c.manager.SaveConfig(req.Context(), &managerModels.SaveConfigInput{}, extractConfigOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
+	}
+	handleOutputResponse(resp, output)
+}
+
+func extractConfigOptions(req *http.Request) []managerModels.ConfigOptionFunc {
+	var opts []managerModels.ConfigOptionFunc
+	for key, values := range req.URL.Query() {
+		switch key {
+		case "force":
+			if b, err := strconv.ParseBool(values[0]); err == nil {
+				opts = append(opts, managerModels.ConfigOptionForce(b))
+			}
+		}
+	}
+	return opts
+}
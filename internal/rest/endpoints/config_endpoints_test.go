@@ -0,0 +1,61 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/managers"
+	"us.figge.auto-ssh/internal/resources/engine/enginetest"
+	"us.figge.auto-ssh/internal/resources/engine/host"
+	"us.figge.auto-ssh/internal/resources/engine/tunnel"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+func TestSaveConfig(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".auto-ssh.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte("web:\n  port: 8080\n"), 0600))
+	ctx := context.Background()
+	hosts := host.NewEngine(ctx, []*config.Host{enginetest.Host(enginetest.Identity(t), "", "bastion", "")})
+	tunnels := tunnel.NewEngine(ctx, hosts, nil)
+	manager, err := managers.NewConfigManager(ctx, filename, hosts, tunnels)
+	require.NoError(t, err)
+	router := mux.NewRouter()
+	NewConfigRest(ctx, manager, router)
+
+	save := func(identity *managerModels.Identity) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/config/save", nil)
+		req = req.WithContext(managerModels.WithIdentity(req.Context(), identity))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := save(&managerModels.Identity{Name: "viewer", Role: config.RoleReadOnly})
+	assert.Equal(t, http.StatusForbidden, resp.Code)
+
+	resp = save(&managerModels.Identity{Name: "root", Role: config.RoleAdmin})
+	require.Equal(t, http.StatusOK, resp.Code)
+	output := &managerModels.SaveConfigOutput{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(output))
+	assert.Equal(t, filename, output.File)
+	assert.Equal(t, 1, output.Hosts)
+	assert.NotEmpty(t, output.Backup)
+
+	bs, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Contains(t, string(bs), "port: 8080")
+	assert.Contains(t, string(bs), "name: bastion")
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/events"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+const (
+	keepAliveInterval = 15 * time.Second
+	retryMillis       = 3000
+)
+
+type EventRest struct {
+	ctx     context.Context
+	manager managerModels.Events
+}
+
+// NewEventRest serves the event stream.  Streams end when ctx does, as they would
+// otherwise hold up the server shutting down
+func NewEventRest(ctx context.Context, manager managerModels.Events, router *mux.Router) {
+	apis := &EventRest{
+		ctx:     ctx,
+		manager: manager,
+	}
+	router.Methods(http.MethodGet).Path("/events").HandlerFunc(apis.StreamEvents)
+}
+
+// StreamEvents streams events as server-sent events.  Events the caller may have missed
+// while disconnected are sent first, preceded by an events.missed event if some of them
+// are no longer kept
+func (e *EventRest) StreamEvents(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
+	flusher, ok := resp.(http.Flusher)
+	if !ok {
+		handleErrorResponse(resp, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	input := &managerModels.StreamEventsInput{}
+	input.Vars(req)
+
+	ctx, cancel := context.WithCancel(req.Context())
+	defer cancel()
+	defer context.AfterFunc(e.ctx, cancel)()
+	output, err := e.manager.StreamEvents(ctx, input, extractEventOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
+	}
+
+	resp.Header().Set("Content-Type", "text/event-stream")
+	resp.Header().Set("Cache-Control", "no-cache")
+	resp.Header().Set("Connection", "keep-alive")
+	resp.Header().Set("X-Accel-Buffering", "no")
+	resp.WriteHeader(http.StatusOK)
+	_, _ = fmt.Fprintf(resp, "retry: %d\n\n", retryMillis)
+	if output.Missed {
+		writeEvent(resp, &events.Event{Type: events.Missed, Time: time.Now(), Message: "events since the last event id are no longer kept"})
+	}
+	for _, event := range output.History {
+		writeEvent(resp, event)
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			_, _ = io.WriteString(resp, ": keep-alive\n\n")
+		case event, ok := <-output.Events:
+			if !ok {
+				return
+			}
+			writeEvent(resp, event)
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w io.Writer, event *events.Event) {
+	bs, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	if event.Id > 0 {
+		_, _ = fmt.Fprintf(w, "id: %d\n", event.Id)
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, bs)
+}
+
+func extractEventOptions(req *http.Request) []managerModels.EventOptionFunc {
+	var opts []managerModels.EventOptionFunc
+	return opts
+}
@@ -7,11 +7,11 @@ package endpoints
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"us.figge.auto-ssh/internal/core/config"
 	managerModels "us.figge.auto-ssh/internal/rest/models"
 )
 
@@ -23,7 +23,8 @@ func NewHostRest(ctx context.Context, manager managerModels.Host, router *mux.Ro
 	apis := &HostRest{
 		manager: manager,
 	}
-	router.Methods(http.MethodGet, http.MethodPost).Path("/hosts").HandlerFunc(apis.ListHosts)
+	router.Methods(http.MethodGet).Path("/hosts").HandlerFunc(apis.ListHosts)
+	router.Methods(http.MethodPost).Path("/hosts/list").HandlerFunc(apis.ListHosts)
 	router.Methods(http.MethodPost).Path("/hosts").HandlerFunc(apis.AddHost)
 	router.Methods(http.MethodGet).Path("/hosts/known-hosts").HandlerFunc(apis.ListKnownHosts)
 	router.Methods(http.MethodGet).Path("/hosts/{id}").HandlerFunc(apis.GetHost)
@@ -32,6 +33,9 @@ func NewHostRest(ctx context.Context, manager managerModels.Host, router *mux.Ro
 }
 
 func (a *HostRest) ListHosts(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
 	input := &managerModels.ListHostInput{}
 	if req.Method == http.MethodGet {
 		input.Vars(req)
@@ -52,49 +56,77 @@ func (a *HostRest) ListHosts(resp http.ResponseWriter, req *http.Request) {
 }
 
 func (a *HostRest) GetHost(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
 	input := &managerModels.GetHostInput{}
 	input.Id = mux.Vars(req)[id]
 	output, err := a.manager.GetHost(req.Context(), input, extractHostOptions(req)...)
 	if err != nil {
 		handleErrorResponse(resp, err)
+		return
 	}
 	handleOutputResponse(resp, output)
 }
 
 func (a *HostRest) AddHost(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleAdmin) {
+		return
+	}
 	input := &managerModels.AddHostInput{}
 	err := json.NewDecoder(req.Body).Decode(&input)
 	if err != nil {
 		resp.WriteHeader(http.StatusBadRequest)
+		return
 	}
-	_, err = a.manager.AddHost(req.Context(), input, extractHostOptions(req)...)
-	hostName := mux.Vars(req)[id]
-	resp.Write([]byte(fmt.Sprintf("AddHost: " + hostName)))
+	output, err := a.manager.AddHost(req.Context(), input, extractHostOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
+	}
+	handleOutputResponse(resp, output)
 }
 
 func (a *HostRest) UpdateHost(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleAdmin) {
+		return
+	}
 	input := &managerModels.UpdateHostInput{}
 	err := json.NewDecoder(req.Body).Decode(&input)
 	if err != nil {
 		resp.WriteHeader(http.StatusBadRequest)
+		return
 	}
-	_, err = a.manager.UpdateHost(req.Context(), input, extractHostOptions(req)...)
-	hostName := mux.Vars(req)[id]
-	resp.Write([]byte(fmt.Sprintf("UpdateHost: " + hostName)))
+	input.Id = mux.Vars(req)[id]
+	output, err := a.manager.UpdateHost(req.Context(), input, extractHostOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
+	}
+	handleOutputResponse(resp, output)
 }
 
 func (a *HostRest) RemoveHost(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleAdmin) {
+		return
+	}
 	input := &managerModels.RemoveHostInput{}
-	err := json.NewDecoder(req.Body).Decode(&input)
+	input.Id = mux.Vars(req)[id]
+	if force := req.URL.Query().Get("force"); force != "" {
+		input.Force, _ = strconv.ParseBool(force)
+	}
+	output, err := a.manager.RemoveHost(req.Context(), input, extractHostOptions(req)...)
 	if err != nil {
-		resp.WriteHeader(http.StatusBadRequest)
+		handleErrorResponse(resp, err)
+		return
 	}
-	_, err = a.manager.RemoveHost(req.Context(), input, extractHostOptions(req)...)
-	hostName := mux.Vars(req)[id]
-	resp.Write([]byte(fmt.Sprintf("RemoveHost: " + hostName)))
+	handleOutputResponse(resp, output)
 }
 
 func (a *HostRest) ListKnownHosts(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
 	input := &managerModels.ListKnownHostsInput{}
 	if req.Method == http.MethodGet {
 		input.Vars(req)
@@ -10,6 +10,7 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"us.figge.auto-ssh/internal/core/config"
 	managerModels "us.figge.auto-ssh/internal/rest/models"
 )
 
@@ -26,6 +27,9 @@ func NewMetadataRest(ctx context.Context, manager managerModels.Metadata, router
 }
 
 func (m MetadataRest) States(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
 	output, err := m.manager.ListStates(req.Context(), extractMetadataOptions(req)...)
 	if err != nil {
 		handleErrorResponse(resp, err)
@@ -35,6 +39,9 @@ func (m MetadataRest) States(resp http.ResponseWriter, req *http.Request) {
 }
 
 func (m MetadataRest) Tags(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
 	input := &managerModels.ListMetadataTagsInput{}
 	if req.Method == http.MethodGet {
 		input.Vars(req)
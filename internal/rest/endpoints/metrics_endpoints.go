@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package endpoints
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"us.figge.auto-ssh/internal/core/config"
+	engineModels "us.figge.auto-ssh/internal/resources/models"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+const (
+	metricsPrefix      = "autossh_"
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+type MetricsRest struct {
+	manager managerModels.Metrics
+}
+
+func NewMetricsRest(ctx context.Context, manager managerModels.Metrics, router *mux.Router) {
+	apis := &MetricsRest{
+		manager: manager,
+	}
+	router.Methods(http.MethodGet).Path("/metrics").HandlerFunc(apis.Metrics)
+}
+
+// Metrics writes the stats of the tunnels and hosts in the prometheus text format
+func (m MetricsRest) Metrics(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
+	output, err := m.manager.GetMetrics(req.Context(), &managerModels.GetMetricsInput{}, extractMetricsOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
+	}
+	b := bytes.Buffer{}
+	writeMetrics(&b, output)
+	resp.Header().Set("Content-Type", metricsContentType)
+	resp.Header().Set("Content-Length", strconv.Itoa(b.Len()))
+	resp.WriteHeader(http.StatusOK)
+	_, _ = resp.Write(b.Bytes())
+}
+
+type tunnelFamily struct {
+	name  string
+	kind  string
+	help  string
+	value func(t *managerModels.TunnelMetrics) float64
+}
+
+type hostFamily struct {
+	name  string
+	kind  string
+	help  string
+	value func(h *managerModels.HostMetrics) float64
+}
+
+var (
+	tunnelFamilies = []tunnelFamily{
+		{"tunnel_received_bytes_total", "counter", "Bytes received by the tunnel from its clients",
+			func(t *managerModels.TunnelMetrics) float64 { return float64(t.Received) }},
+		{"tunnel_sent_bytes_total", "counter", "Bytes sent by the tunnel back to its clients",
+			func(t *managerModels.TunnelMetrics) float64 { return float64(t.Transmitted) }},
+		{"tunnel_open_connections", "gauge", "Connections currently forwarded by the tunnel",
+			func(t *managerModels.TunnelMetrics) float64 { return float64(t.Open) }},
+		{"tunnel_connections_total", "counter", "Connections accepted by the tunnel",
+			func(t *managerModels.TunnelMetrics) float64 { return float64(t.Connections) }},
+		{"tunnel_connection_errors_total", "counter", "Connections the tunnel could not forward",
+			func(t *managerModels.TunnelMetrics) float64 { return float64(t.Failures) }},
+		{"tunnel_reconnects_total", "counter", "Attempts to reopen the tunnel's entrance",
+			func(t *managerModels.TunnelMetrics) float64 { return float64(t.Reconnects) }},
+	}
+	hostFamilies = []hostFamily{
+		{"host_connected", "gauge", "Whether the host is connected",
+			func(h *managerModels.HostMetrics) float64 { return boolValue(h.Connected) }},
+		{"host_valid", "gauge", "Whether the host definition is valid",
+			func(h *managerModels.HostMetrics) float64 { return boolValue(h.Valid) }},
+		{"host_connection_errors_total", "counter", "Failed attempts to connect to the host",
+			func(h *managerModels.HostMetrics) float64 { return float64(h.Failures) }},
+		{"host_reconnects_total", "counter", "Attempts to reconnect to the host after losing it",
+			func(h *managerModels.HostMetrics) float64 { return float64(h.Reconnects) }},
+	}
+)
+
+func writeMetrics(w io.Writer, output *managerModels.GetMetricsOutput) {
+	for _, family := range tunnelFamilies {
+		writeFamily(w, family.name, family.kind, family.help)
+		for _, t := range output.Tunnels {
+			writeSample(w, family.name, tunnelLabels(t), family.value(t))
+		}
+	}
+
+	writeFamily(w, "tunnel_dial_seconds", "histogram", "Time taken to reach the far end of a connection")
+	for _, t := range output.Tunnels {
+		labels := tunnelLabels(t)
+		for i, bucket := range t.DialBuckets {
+			writeSample(w, "tunnel_dial_seconds_bucket", append(labels, "le", strconv.FormatFloat(bucket, 'g', -1, 64)), float64(t.DialCounts[i]))
+		}
+		writeSample(w, "tunnel_dial_seconds_bucket", append(labels, "le", "+Inf"), float64(t.DialCount))
+		writeSample(w, "tunnel_dial_seconds_sum", labels, t.DialSeconds)
+		writeSample(w, "tunnel_dial_seconds_count", labels, float64(t.DialCount))
+	}
+
+	writeFamily(w, "tunnel_state", "gauge", "The state of the tunnel, 1 for the state it is in")
+	for _, t := range output.Tunnels {
+		for _, state := range engineModels.RunningEnums() {
+			writeSample(w, "tunnel_state", append(tunnelLabels(t), "state", state), boolValue(t.Running == state))
+		}
+	}
+
+	for _, family := range hostFamilies {
+		writeFamily(w, family.name, family.kind, family.help)
+		for _, h := range output.Hosts {
+			writeSample(w, family.name, []string{"id", h.Id, "name", h.Name, "tags", strings.Join(h.Tags, ",")}, family.value(h))
+		}
+	}
+}
+
+func tunnelLabels(t *managerModels.TunnelMetrics) []string {
+	return []string{"id", t.Id, "name", t.Name, "host", t.Host, "tags", strings.Join(t.Tags, ",")}
+}
+
+func writeFamily(w io.Writer, name string, kind string, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+}
+
+// writeSample writes a sample, labels holding pairs of label names and values
+func writeSample(w io.Writer, name string, labels []string, value float64) {
+	var sb strings.Builder
+	sb.WriteString(metricsPrefix + name + "{")
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+	}
+	sb.WriteString("} " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+	_, _ = io.WriteString(w, sb.String())
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func extractMetricsOptions(req *http.Request) []managerModels.MetricsOptionFunc {
+	var opts []managerModels.MetricsOptionFunc
+	return opts
+}
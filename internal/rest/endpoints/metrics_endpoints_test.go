@@ -0,0 +1,36 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package endpoints
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	b := bytes.Buffer{}
+	writeMetrics(&b, &managerModels.GetMetricsOutput{
+		Tunnels: []*managerModels.TunnelMetrics{{
+			Id: "web", Name: `say "hi"`, Tags: []string{"prod", "eu"}, Running: "Started",
+			Received: 2048, Open: 1, Connections: 3,
+			DialBuckets: []float64{0.1, 1}, DialCounts: []uint64{1, 2}, DialCount: 3, DialSeconds: 1.75,
+		}},
+		Hosts: []*managerModels.HostMetrics{{Id: "bastion", Name: "bastion", Connected: true, Reconnects: 2}},
+	})
+	metrics := b.String()
+	labels := `id="web",name="say \"hi\"",host="",tags="prod,eu"`
+	assert.Contains(t, metrics, "# TYPE autossh_tunnel_received_bytes_total counter\n")
+	assert.Contains(t, metrics, "autossh_tunnel_received_bytes_total{"+labels+"} 2048\n")
+	assert.Contains(t, metrics, "autossh_tunnel_dial_seconds_bucket{"+labels+`,le="0.1"} 1`+"\n")
+	assert.Contains(t, metrics, "autossh_tunnel_dial_seconds_bucket{"+labels+`,le="+Inf"} 3`+"\n")
+	assert.Contains(t, metrics, "autossh_tunnel_dial_seconds_sum{"+labels+"} 1.75\n")
+	assert.Contains(t, metrics, "autossh_tunnel_state{"+labels+`,state="Started"} 1`+"\n")
+	assert.Contains(t, metrics, "autossh_tunnel_state{"+labels+`,state="Stopped"} 0`+"\n")
+	assert.Contains(t, metrics, `autossh_host_connected{id="bastion",name="bastion",tags=""} 1`+"\n")
+	assert.Contains(t, metrics, `autossh_host_reconnects_total{id="bastion",name="bastion",tags=""} 2`+"\n")
+}
@@ -7,11 +7,11 @@ package endpoints
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"us.figge.auto-ssh/internal/core/config"
 	managerModels "us.figge.auto-ssh/internal/rest/models"
 )
 
@@ -23,7 +23,8 @@ func NewTunnelRest(ctx context.Context, manager managerModels.Tunnel, router *mu
 	apis := &TunnelRest{
 		manager: manager,
 	}
-	router.Methods(http.MethodGet, http.MethodPost).Path("/tunnels").HandlerFunc(apis.ListTunnels)
+	router.Methods(http.MethodGet).Path("/tunnels").HandlerFunc(apis.ListTunnels)
+	router.Methods(http.MethodPost).Path("/tunnels/list").HandlerFunc(apis.ListTunnels)
 	router.Methods(http.MethodPost).Path("/tunnels").HandlerFunc(apis.AddTunnel)
 	router.Methods(http.MethodGet).Path("/tunnels/{id}").HandlerFunc(apis.GetTunnel)
 	router.Methods(http.MethodPut).Path("/tunnels/{id}").HandlerFunc(apis.UpdateTunnel)
@@ -33,6 +34,9 @@ func NewTunnelRest(ctx context.Context, manager managerModels.Tunnel, router *mu
 }
 
 func (a *TunnelRest) ListTunnels(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
 	input := &managerModels.ListTunnelInput{}
 	if req.Method == http.MethodGet {
 		input.Vars(req)
@@ -52,62 +56,91 @@ func (a *TunnelRest) ListTunnels(resp http.ResponseWriter, req *http.Request) {
 	handleOutputResponse(resp, output)
 }
 func (a *TunnelRest) GetTunnel(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleReadOnly) {
+		return
+	}
 	input := &managerModels.GetTunnelInput{}
 	input.Id = mux.Vars(req)[id]
 	output, err := a.manager.GetTunnel(req.Context(), input, extractTunnelOptions(req)...)
 	if err != nil {
 		handleErrorResponse(resp, err)
+		return
 	}
 	handleOutputResponse(resp, output)
 }
 
 func (a *TunnelRest) AddTunnel(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleAdmin) {
+		return
+	}
 	input := &managerModels.AddTunnelInput{}
 	err := json.NewDecoder(req.Body).Decode(input)
 	if err != nil {
 		resp.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	output, err := a.manager.AddTunnel(req.Context(), input, extractTunnelOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
 	}
-	_, err = a.manager.AddTunnel(req.Context(), input, extractTunnelOptions(req)...)
-	hostName := mux.Vars(req)[id]
-	resp.Write([]byte(fmt.Sprintf("AddTunnel: " + hostName)))
+	handleOutputResponse(resp, output)
 }
 
 func (a *TunnelRest) UpdateTunnel(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleAdmin) {
+		return
+	}
 	input := &managerModels.UpdateTunnelInput{}
 	err := json.NewDecoder(req.Body).Decode(input)
 	if err != nil {
 		resp.WriteHeader(http.StatusBadRequest)
+		return
 	}
-	_, err = a.manager.UpdateTunnel(req.Context(), input, extractTunnelOptions(req)...)
-	hostName := mux.Vars(req)[id]
-	resp.Write([]byte(fmt.Sprintf("UpdateTunnel: " + hostName)))
+	input.Id = mux.Vars(req)[id]
+	output, err := a.manager.UpdateTunnel(req.Context(), input, extractTunnelOptions(req)...)
+	if err != nil {
+		handleErrorResponse(resp, err)
+		return
+	}
+	handleOutputResponse(resp, output)
 }
 
 func (a *TunnelRest) RemoveTunnel(resp http.ResponseWriter, req *http.Request) {
-	input := &managerModels.RemoveTunnelInput{}
-	err := json.NewDecoder(req.Body).Decode(input)
+	if !authorized(resp, req, config.RoleAdmin) {
+		return
+	}
+	input := &managerModels.RemoveTunnelInput{Id: mux.Vars(req)[id]}
+	output, err := a.manager.RemoveTunnel(req.Context(), input, extractTunnelOptions(req)...)
 	if err != nil {
-		resp.WriteHeader(http.StatusBadRequest)
+		handleErrorResponse(resp, err)
+		return
 	}
-	_, err = a.manager.RemoveTunnel(req.Context(), input, extractTunnelOptions(req)...)
-	hostName := mux.Vars(req)[id]
-	resp.Write([]byte(fmt.Sprintf("RemoveTunnel: " + hostName)))
+	handleOutputResponse(resp, output)
 }
 
 func (a *TunnelRest) StartTunnel(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleOperator) {
+		return
+	}
 	input := &managerModels.StartTunnelInput{Id: mux.Vars(req)[id]}
 	output, err := a.manager.StartTunnel(req.Context(), input, extractTunnelOptions(req)...)
 	if err != nil {
 		handleErrorResponse(resp, err)
+		return
 	}
 	handleOutputResponse(resp, output)
 }
 
 func (a *TunnelRest) StopTunnel(resp http.ResponseWriter, req *http.Request) {
+	if !authorized(resp, req, config.RoleOperator) {
+		return
+	}
 	input := &managerModels.StopTunnelInput{Id: mux.Vars(req)[id]}
 	output, err := a.manager.StopTunnel(req.Context(), input, extractTunnelOptions(req)...)
 	if err != nil {
 		handleErrorResponse(resp, err)
+		return
 	}
 	handleOutputResponse(resp, output)
 }
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/managers"
+	"us.figge.auto-ssh/internal/resources/engine/host"
+	"us.figge.auto-ssh/internal/resources/engine/tunnel"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+func TestTunnelSocksPassword(t *testing.T) {
+	ctx := context.Background()
+	hosts := host.NewEngine(ctx, nil)
+	tunnels := tunnel.NewEngine(ctx, hosts, nil)
+	tunnelManager, err := managers.NewTunnelManager(ctx, hosts, tunnels, nil)
+	require.NoError(t, err)
+	router := mux.NewRouter()
+	NewTunnelRest(ctx, tunnelManager, router)
+
+	call := func(method string, path string, body string) *config.Tunnel {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req = req.WithContext(managerModels.WithIdentity(req.Context(), &managerModels.Identity{Name: "root", Role: config.RoleAdmin}))
+		resp := httptest.NewRecorder()
+		router.ServeHTTP(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code, resp.Body.String())
+		output := &config.Tunnel{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(output))
+		return output
+	}
+
+	added := call(http.MethodPost, "/tunnels",
+		`{"name": "socks", "type": "dynamic", "local": "127.0.0.1:18433", "socks": {"username": "user", "password": "secret"}}`)
+	require.NotNil(t, added.Socks)
+	assert.Equal(t, "user", added.Socks.Username)
+	assert.Equal(t, config.Masked, added.Socks.Password, "the password is not returned")
+	running, ok := tunnels.Tunnel("socks")
+	require.True(t, ok)
+	assert.Equal(t, "secret", running.Socks().Password)
+
+	bs, err := json.Marshal(added)
+	require.NoError(t, err)
+	call(http.MethodPut, "/tunnels/socks", string(bs))
+	running, _ = tunnels.Tunnel("socks")
+	assert.Equal(t, "secret", running.Socks().Password, "a masked password read back leaves it unchanged")
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	ErrKeyPassphrase = errors.New("certificate key is encrypted and requires a passphrase")
+	ErrKeyDecrypt    = errors.New("certificate key cannot be decrypted")
+	ErrKeyFormat     = errors.New("certificate key is not a pem encoded private key")
+
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// loadKeyPair reads a certificate and its private key.  The key may be encrypted, either
+// as an ENCRYPTED PRIVATE KEY (PKCS#8 with PBES2, as written by current openssl) or as a
+// legacy pem block with a DEK-Info header
+func loadKeyPair(certFile string, keyFile string, passphrase string) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if keyPEM, err = decryptKey(keyPEM, passphrase); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// decryptKey returns an unencrypted pem encoding of a private key
+func decryptKey(keyPEM []byte, passphrase string) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, ErrKeyFormat
+	}
+	var der []byte
+	var err error
+	//nolint:staticcheck // legacy pem encryption is insecure, but still in use
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		if passphrase == "" {
+			return nil, ErrKeyPassphrase
+		}
+		der, err = decryptPKCS8(block.Bytes, []byte(passphrase))
+	case x509.IsEncryptedPEMBlock(block):
+		if passphrase == "" {
+			return nil, ErrKeyPassphrase
+		}
+		der, err = x509.DecryptPEMBlock(block, []byte(passphrase))
+	default:
+		return keyPEM, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeyDecrypt, err)
+	}
+
+	key, err := parsePrivateKey(block.Type, der)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrKeyDecrypt, err)
+	}
+	der, err = x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func parsePrivateKey(blockType string, der []byte) (any, error) {
+	switch blockType {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(der)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(der)
+	default:
+		return x509.ParsePKCS8PrivateKey(der)
+	}
+}
+
+// decryptPKCS8 decrypts a PKCS#8 EncryptedPrivateKeyInfo using PBES2 with PBKDF2 and
+// AES-CBC, the only scheme openssl has written by default for many years
+func decryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported encryption %v.  Only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation %v.  Only PBKDF2 is supported", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdf pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, err
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.PRF.Algorithm) == 0, kdf.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdf.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported pseudorandom function %v", kdf.PRF.Algorithm)
+	}
+	var keyLength int
+	switch scheme := params.EncryptionScheme.Algorithm; {
+	case scheme.Equal(oidAES128CBC):
+		keyLength = 16
+	case scheme.Equal(oidAES192CBC):
+		keyLength = 24
+	case scheme.Equal(oidAES256CBC):
+		keyLength = 32
+	default:
+		return nil, fmt.Errorf("unsupported cipher %v.  Only AES-CBC is supported", scheme)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize || len(info.EncryptedData)%aes.BlockSize != 0 || len(info.EncryptedData) == 0 {
+		return nil, errors.New("malformed encrypted data")
+	}
+
+	key := pbkdf2.Key(passphrase, kdf.Salt, kdf.IterationCount, keyLength, prf)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.EncryptedData)
+	return unpad(plain)
+}
+
+// unpad strips PKCS#7 padding.  Bad padding almost always means a wrong passphrase
+func unpad(plain []byte) ([]byte, error) {
+	n := int(plain[len(plain)-1])
+	if n == 0 || n > aes.BlockSize || n > len(plain) {
+		return nil, errors.New("incorrect passphrase")
+	}
+	pad := plain[len(plain)-n:]
+	for _, b := range pad {
+		if b != byte(n) {
+			return nil, errors.New("incorrect passphrase")
+		}
+	}
+	return plain[:len(plain)-n], nil
+}
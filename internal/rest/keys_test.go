@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/pbkdf2"
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+func TestLoadKeyPair(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+	certFile := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	ecDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	//nolint:staticcheck
+	legacy, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", ecDer, []byte("secret"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+
+	for name, block := range map[string]*pem.Block{
+		"plain":  {Type: "PRIVATE KEY", Bytes: keyDer},
+		"pkcs8":  {Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptPKCS8(t, keyDer, "secret")},
+		"legacy": legacy,
+	} {
+		keyFile := filepath.Join(dir, name+".pem")
+		require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+		_, err = loadKeyPair(certFile, keyFile, "secret")
+		assert.NoError(t, err, name)
+		if name != "plain" {
+			_, err = loadKeyPair(certFile, keyFile, "")
+			assert.ErrorIs(t, err, ErrKeyPassphrase, name)
+			_, err = loadKeyPair(certFile, keyFile, "wrong")
+			assert.ErrorIs(t, err, ErrKeyDecrypt, name)
+		}
+	}
+}
+
+func TestCertificateIdentity(t *testing.T) {
+	clients := []*config.ClientCert{
+		{Subject: "*@example.com", Role: config.RoleOperator, Tags: []string{"prod"}},
+		{Subject: "spiffe://example.com/*", Role: config.RoleAdmin},
+	}
+	alice := &x509.Certificate{Subject: pkix.Name{CommonName: "alice"}, EmailAddresses: []string{"alice@example.com"}}
+	identity := certificateIdentity(clients, alice)
+	require.NotNil(t, identity)
+	assert.Equal(t, "alice", identity.Name)
+	assert.Equal(t, config.RoleOperator, identity.Role)
+
+	uri, _ := url.Parse("spiffe://example.com/deployer")
+	identity = certificateIdentity(clients, &x509.Certificate{URIs: []*url.URL{uri}})
+	require.NotNil(t, identity)
+	assert.Equal(t, "spiffe://example.com/deployer", identity.Name)
+	assert.Equal(t, config.RoleAdmin, identity.Role)
+
+	assert.Nil(t, certificateIdentity(clients, &x509.Certificate{Subject: pkix.Name{CommonName: "mallory"}}))
+}
+
+// encryptPKCS8 encrypts a key as openssl pkcs8 -topk8 -v2 aes-256-cbc -v2prf hmacWithSHA256 does
+func encryptPKCS8(t *testing.T, der []byte, passphrase string) []byte {
+	salt, iv := make([]byte, 8), make([]byte, aes.BlockSize)
+	_, _ = rand.Read(salt)
+	_, _ = rand.Read(iv)
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(passphrase), salt, 2048, 32, sha256.New))
+	require.NoError(t, err)
+	n := aes.BlockSize - len(der)%aes.BlockSize
+	padded := append(append([]byte{}, der...), make([]byte, n)...)
+	for i := len(der); i < len(padded); i++ {
+		padded[i] = byte(n)
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+
+	marshal := func(v any) asn1.RawValue {
+		bs, err := asn1.Marshal(v)
+		require.NoError(t, err)
+		return asn1.RawValue{FullBytes: bs}
+	}
+	info, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: marshal(pbes2Params{
+			KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: marshal(pbkdf2Params{
+				Salt:           salt,
+				IterationCount: 2048,
+				PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+			})},
+			EncryptionScheme: pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: marshal(iv)},
+		})},
+		EncryptedData: padded,
+	})
+	require.NoError(t, err)
+	return info
+}
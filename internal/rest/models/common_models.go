@@ -60,16 +60,29 @@ func (f *FiltersInput) Vars(req *http.Request) {
 		return
 	}
 	for i, filter := range filters {
-		kv := filtersRegEx.FindStringSubmatch(filter)
-		if len(kv) != 3 {
+		parsed, ok := ParseFilter(filter)
+		if !ok {
 			continue
 		}
-		f.Filters = append(f.Filters, &Filter{
-			Key:    kv[1],
-			Values: strings.Split(kv[2], ";"),
-		})
+		f.Filters = append(f.Filters, parsed)
 		if i == 9 {
 			break
 		}
 	}
 }
+
+// ParseFilter reads a filter written as key=<key>,values=<value>;<value>
+func ParseFilter(filter string) (*Filter, bool) {
+	kv := filtersRegEx.FindStringSubmatch(filter)
+	if len(kv) != 3 {
+		return nil, false
+	}
+	return &Filter{
+		Key:    kv[1],
+		Values: strings.Split(kv[2], ";"),
+	}, true
+}
+
+type ErrorOutput struct {
+	Error string `json:"error"`
+}
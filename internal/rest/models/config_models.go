@@ -0,0 +1,58 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package models
+
+import (
+	"context"
+)
+
+type Config interface {
+	GetConfig(
+		ctx context.Context,
+		input *GetConfigInput,
+		options ...ConfigOptionFunc,
+	) (*GetConfigOutput, error)
+	SaveConfig(
+		ctx context.Context,
+		input *SaveConfigInput,
+		options ...ConfigOptionFunc,
+	) (*SaveConfigOutput, error)
+}
+
+type GetConfigInput struct {
+}
+
+// GetConfigOutput identifies the running auto-ssh by its pid and the configuration file
+// it loaded, which is empty when it runs with the defaults
+type GetConfigOutput struct {
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+	Pid  int    `yaml:"pid" json:"pid"`
+}
+
+type SaveConfigInput struct {
+}
+
+type SaveConfigOutput struct {
+	File    string `yaml:"file" json:"file"`
+	Backup  string `yaml:"backup,omitempty" json:"backup,omitempty"`
+	Hosts   int    `yaml:"hosts" json:"hosts"`
+	Tunnels int    `yaml:"tunnels" json:"tunnels"`
+}
+
+type ConfigOptionFunc func(options *ConfigOptions)
+type ConfigOptions struct {
+	force bool
+}
+
+// Force reports whether the file is saved even if it was edited since it was loaded
+func (c *ConfigOptions) Force() bool {
+	return c.force
+}
+
+func ConfigOptionForce(force bool) ConfigOptionFunc {
+	return func(options *ConfigOptions) {
+		options.force = force
+	}
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package models
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"us.figge.auto-ssh/internal/core/events"
+)
+
+type Events interface {
+	StreamEvents(
+		ctx context.Context,
+		input *StreamEventsInput,
+		options ...EventOptionFunc,
+	) (*StreamEventsOutput, error)
+}
+
+// StreamEventsInput selects the events streamed.  Each filter matches any of its values,
+// and an event must match every filter given.  Types match exactly or by their prefix,
+// so host matches host.connected and host.disconnected
+type StreamEventsInput struct {
+	LastEventId uint64   `json:"lastEventId,omitempty"`
+	Tunnels     []string `json:"tunnels,omitempty"`
+	Hosts       []string `json:"hosts,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Types       []string `json:"types,omitempty"`
+}
+
+// Vars reads the filters from the query, as repeated or comma separated values of tunnel,
+// host, tag and type.  The stream resumes from the Last-Event-ID header, which browsers
+// send when reconnecting, or else the lastEventId query value
+func (i *StreamEventsInput) Vars(req *http.Request) {
+	vs := req.URL.Query()
+	i.Tunnels = queryValues(vs["tunnel"])
+	i.Hosts = queryValues(vs["host"])
+	i.Tags = queryValues(vs["tag"])
+	i.Types = queryValues(vs["type"])
+	lastEventId := req.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = vs.Get("lastEventId")
+	}
+	i.LastEventId, _ = strconv.ParseUint(strings.TrimSpace(lastEventId), 10, 64)
+}
+
+func queryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				result = append(result, v)
+			}
+		}
+	}
+	return result
+}
+
+// StreamEventsOutput holds the events kept since LastEventId, then those that follow as
+// they happen.  Events is closed when the stream ends, including when the subscriber
+// falls too far behind, after which it can resume from the last event it received
+type StreamEventsOutput struct {
+	Missed  bool
+	History []*events.Event
+	Events  <-chan *events.Event
+}
+
+type EventOptionFunc func(options *EventOptions)
+type EventOptions struct {
+}
@@ -45,10 +45,11 @@ type Host interface {
 }
 
 type HostHeader struct {
-	Id      string `yaml:"id" json:"id"`
-	Name    string `yaml:"name" json:"name"`
-	Valid   bool   `yaml:"valid" json:"valid"`
-	Running bool   `yaml:"running" json:"running"`
+	Id      string             `yaml:"id" json:"id"`
+	Name    string             `yaml:"name" json:"name"`
+	Valid   bool               `yaml:"valid" json:"valid"`
+	Running bool               `yaml:"running" json:"running"`
+	Status  *config.HostStatus `yaml:"status,omitempty" json:"status,omitempty"`
 }
 
 type KnownHost struct {
@@ -79,22 +80,27 @@ type GetHostOutput struct {
 }
 
 type AddHostInput struct {
-	Name       string           `yaml:"name" json:"name"`
-	Group      string           `yaml:"group,omitempty" json:"group,omitempty"`
-	Address    string           `yaml:"address" json:"address"`
-	Username   string           `yaml:"username" json:"username"`
-	Identity   string           `yaml:"identity" json:"identity"`
-	KnownHosts string           `yaml:"known-hosts" json:"knownHosts"`
-	JumpHost   string           `yaml:"jump-host" json:"jumpHost"`
-	Metadata   *config.Metadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
-}
-type AddHostOutput struct{}
-
-type UpdateHostInput struct{}
-type UpdateHostOutput struct{}
-
-type RemoveHostInput struct{}
-type RemoveHostOutput struct{}
+	config.Host
+}
+type AddHostOutput struct {
+	config.Host
+}
+
+type UpdateHostInput struct {
+	config.Host
+}
+type UpdateHostOutput struct {
+	config.Host
+}
+
+type RemoveHostInput struct {
+	Id    string `json:"id"`
+	Force bool   `json:"force,omitempty"`
+}
+type RemoveHostOutput struct {
+	Id         string   `json:"id"`
+	References []string `json:"references,omitempty"`
+}
 
 type ListKnownHostsInput struct {
 	PaginationInput
@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package models
+
+import (
+	"context"
+	"strings"
+
+	"us.figge.auto-ssh/internal/core/config"
+)
+
+type identityKey struct{}
+
+// Identity is the caller of the api, as authenticated by its token.  Requests carry no
+// identity when the api is open, and are allowed everything
+type Identity struct {
+	Name string
+	Role string
+	Tags []string
+}
+
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+func IdentityFrom(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// Allows reports whether the caller holds at least the required role
+func (i *Identity) Allows(required string) bool {
+	return i == nil || config.RoleAllows(i.Role, required)
+}
+
+// Sees reports whether a resource carrying tags is within the caller's scope
+func (i *Identity) Sees(tags []string) bool {
+	if i == nil || len(i.Tags) == 0 {
+		return true
+	}
+	for _, scope := range i.Tags {
+		for _, tag := range tags {
+			if strings.EqualFold(scope, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
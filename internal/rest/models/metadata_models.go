@@ -21,7 +21,8 @@ type Metadata interface {
 }
 
 type ListMetadataStatesOutput struct {
-	States []string `json:"states"`
+	States      []string            `json:"states"`
+	Transitions map[string][]string `json:"transitions"`
 }
 
 type ListMetadataTagsInput struct {
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package models
+
+import (
+	"context"
+)
+
+type Metrics interface {
+	GetMetrics(
+		ctx context.Context,
+		input *GetMetricsInput,
+		options ...MetricsOptionFunc,
+	) (*GetMetricsOutput, error)
+}
+
+type GetMetricsInput struct {
+}
+
+type GetMetricsOutput struct {
+	Tunnels []*TunnelMetrics `json:"tunnels"`
+	Hosts   []*HostMetrics   `json:"hosts"`
+}
+
+// TunnelMetrics are the stats of a tunnel.  DialCounts holds the number of dials that
+// took no longer than each of DialBuckets, in seconds
+type TunnelMetrics struct {
+	Id          string    `json:"id"`
+	Name        string    `json:"name"`
+	Host        string    `json:"host,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Running     string    `json:"running"`
+	Received    int64     `json:"received"`
+	Transmitted int64     `json:"transmitted"`
+	Open        int       `json:"open"`
+	Connections int       `json:"connections"`
+	Failures    int       `json:"failures"`
+	Reconnects  int       `json:"reconnects"`
+	DialBuckets []float64 `json:"dialBuckets"`
+	DialCounts  []uint64  `json:"dialCounts"`
+	DialCount   uint64    `json:"dialCount"`
+	DialSeconds float64   `json:"dialSeconds"`
+}
+
+type HostMetrics struct {
+	Id         string   `json:"id"`
+	Name       string   `json:"name"`
+	Tags       []string `json:"tags,omitempty"`
+	Valid      bool     `json:"valid"`
+	Connected  bool     `json:"connected"`
+	Failures   int64    `json:"failures"`
+	Reconnects int64    `json:"reconnects"`
+}
+
+type MetricsOptionFunc func(options *MetricsOptions)
+type MetricsOptions struct {
+}
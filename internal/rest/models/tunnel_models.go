@@ -78,14 +78,27 @@ type GetTunnelOutput struct {
 	config.Tunnel
 }
 
-type AddTunnelInput struct{}
-type AddTunnelOutput struct{}
+type AddTunnelInput struct {
+	config.Tunnel
+	Start bool `json:"start,omitempty"`
+}
+type AddTunnelOutput struct {
+	config.Tunnel
+}
 
-type UpdateTunnelInput struct{}
-type UpdateTunnelOutput struct{}
+type UpdateTunnelInput struct {
+	config.Tunnel
+}
+type UpdateTunnelOutput struct {
+	config.Tunnel
+}
 
-type RemoveTunnelInput struct{}
-type RemoveTunnelOutput struct{}
+type RemoveTunnelInput struct {
+	Id string `json:"id"`
+}
+type RemoveTunnelOutput struct {
+	Id string `json:"id"`
+}
 
 type StartTunnelInput struct {
 	Id string `json:"id"`
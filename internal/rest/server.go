@@ -6,6 +6,7 @@ package rest
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -15,6 +16,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/spf13/cobra"
 	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/utils"
 	managers2 "us.figge.auto-ssh/internal/managers"
 	engineModels "us.figge.auto-ssh/internal/resources/models"
 	"us.figge.auto-ssh/internal/rest/endpoints"
@@ -28,6 +30,9 @@ var (
 type Server struct {
 	wg            *sync.WaitGroup
 	webCfg        *config.Web
+	socket        string
+	stopStreams   context.CancelFunc
+	certificates  *certificates
 	httpServer    *http.Server
 	hostManager   managerModels.Host
 	tunnelManager managerModels.Tunnel
@@ -50,8 +55,8 @@ func NewServer(
 		return nil, err
 	}
 
-	hostMgr, tunnelMgr, metadataMgr := s.startManagers(ctx, hosts, tunnels)
-	routers := s.startHandlers(ctx, hostMgr, tunnelMgr, metadataMgr)
+	hostMgr, tunnelMgr, metadataMgr, configMgr, eventMgr, metricsMgr := s.startManagers(ctx, hosts, tunnels)
+	routers := s.startHandlers(ctx, hostMgr, tunnelMgr, metadataMgr, configMgr, eventMgr, metricsMgr)
 	err = s.Serve(ctx, routers)
 	if err != nil {
 		return nil, err
@@ -62,9 +67,12 @@ func NewServer(
 func Flags(cmd *cobra.Command) {
 	cmd.Flags().StringVarP(&cliArgs.Address, "address", "A", "0.0.0.0", "Address for aut-ssh API server. Default is 0.0.0.0")
 	cmd.Flags().Int16VarP(&cliArgs.Port, "port", "P", 0, "port for auto-ssh API server. Zero port disables server")
+	cmd.Flags().StringVar(&cliArgs.Socket, "socket", "", "unix socket for auto-ssh API server. Defaults to "+config.DefaultSocket()+", none disables the socket")
 	cmd.Flags().StringVar(&cliArgs.CertificateFile, "certificate-file", "", "Certificate required to place aut-ssh in https mode")
 	cmd.Flags().StringVar(&cliArgs.CertificateKey, "certificate-key", "", "Certificate private key required to place aut-ssh in https mode")
 	cmd.Flags().StringVar(&cliArgs.KeyPassphrase, "passphrase", "", "passphrase used to decrypt certificate key.  See -w to prompt")
+	cmd.Flags().StringVar(&cliArgs.ClientCA, "client-ca", "", "CA certificates that client certificates must be signed by.  Requires https")
+	cmd.Flags().BoolVar(&cliArgs.Insecure, "insecure", false, "serve the api on a non-loopback address without tokens or a client CA")
 }
 
 // routes map[string]http.Handler
@@ -75,9 +83,12 @@ func (s *Server) Validate() config.Validations {
 		s.validatePort(&v)
 		s.validateCertFile(&v)
 		s.validateCertKey(&v)
+		s.validateClientCA(&v)
+		s.validateTokens(&v)
 	} else {
-		v.Infof("web server disabled. web.port=0")
+		v.Infof("web port disabled. web.port=0")
 	}
+	s.validateSocket(&v)
 	return v
 }
 func (s *Server) validateWebAddress(v *config.Validations) {
@@ -157,27 +168,51 @@ func (s *Server) validateCertKey(v *config.Validations) {
 	}
 	if _, err := os.ReadFile(s.webCfg.CertificateKey); err != nil {
 		v.Errorf("web.certificate_key cannot be read: %v", err)
+		return
+	}
+	_, err := loadKeyPair(s.webCfg.CertificateFile, s.webCfg.CertificateKey, s.webCfg.KeyPassphrase)
+	if errors.Is(err, ErrKeyPassphrase) && config.PromptFlag {
+		if passphrase, ok := utils.Prompt("web.certificate_key passphrase: ", true, true); ok {
+			s.webCfg.KeyPassphrase = passphrase
+			_, err = loadKeyPair(s.webCfg.CertificateFile, s.webCfg.CertificateKey, s.webCfg.KeyPassphrase)
+		}
+	}
+	if err != nil {
+		v.Errorf("web.certificate_key cannot be used: %v", err)
 	}
 }
 
 func (s *Server) startManagers(
 	ctx context.Context, hosts engineModels.HostEngine, tunnels engineModels.TunnelEngine,
-) (managerModels.Host, managerModels.Tunnel, managerModels.Metadata) {
-	hostManager, tunnelManager, metadataManager, err := s.startManagersE(ctx, hosts, tunnels)
+) (managerModels.Host, managerModels.Tunnel, managerModels.Metadata, managerModels.Config, managerModels.Events, managerModels.Metrics) {
+	hostManager, tunnelManager, metadataManager, configManager, eventManager, metricsManager, err := s.startManagersE(ctx, hosts, tunnels)
 	if err != nil {
 		fmt.Printf("failed to start managers: %v\n", err)
 		os.Exit(1)
 	}
-	return hostManager, tunnelManager, metadataManager
+	return hostManager, tunnelManager, metadataManager, configManager, eventManager, metricsManager
 }
 func (s *Server) startManagersE(
 	ctx context.Context, hosts engineModels.HostEngine, tunnels engineModels.TunnelEngine,
-) (hostManager managerModels.Host, tunnelManager managerModels.Tunnel, metadataManager managerModels.Metadata, err error) {
-	hostManager, err = managers2.NewHostManager(ctx, hosts)
+) (
+	hostManager managerModels.Host,
+	tunnelManager managerModels.Tunnel,
+	metadataManager managerModels.Metadata,
+	configManager managerModels.Config,
+	eventManager managerModels.Events,
+	metricsManager managerModels.Metrics,
+	err error,
+) {
+	saver, err := managers2.NewConfigManager(ctx, config.FileName, hosts, tunnels)
+	if err != nil {
+		return
+	}
+	configManager = saver
+	hostManager, err = managers2.NewHostManager(ctx, hosts, saver)
 	if err != nil {
 		return
 	}
-	tunnelManager, err = managers2.NewTunnelManager(ctx, tunnels)
+	tunnelManager, err = managers2.NewTunnelManager(ctx, hosts, tunnels, saver)
 	if err != nil {
 		return
 	}
@@ -185,6 +220,14 @@ func (s *Server) startManagersE(
 	if err != nil {
 		return
 	}
+	eventManager, err = managers2.NewEventManager(ctx)
+	if err != nil {
+		return
+	}
+	metricsManager, err = managers2.NewMetricsManager(ctx, hosts, tunnels)
+	if err != nil {
+		return
+	}
 	return
 }
 
@@ -193,38 +236,82 @@ func (s *Server) startHandlers(
 	hostManager managerModels.Host,
 	tunnelManager managerModels.Tunnel,
 	metadataManager managerModels.Metadata,
+	configManager managerModels.Config,
+	eventManager managerModels.Events,
+	metricsManager managerModels.Metrics,
 ) *mux.Router {
+	var streams context.Context
+	streams, s.stopStreams = context.WithCancel(ctx)
 	routes := mux.NewRouter()
+	routes.Use(s.authenticate)
 	endpoints.NewHostRest(ctx, hostManager, routes)
 	endpoints.NewTunnelRest(ctx, tunnelManager, routes)
 	endpoints.NewMetadataRest(ctx, metadataManager, routes)
+	endpoints.NewConfigRest(ctx, configManager, routes)
+	endpoints.NewEventRest(streams, eventManager, routes)
+	endpoints.NewMetricsRest(ctx, metricsManager, routes)
 	return routes
 }
 
 func (s *Server) Serve(ctx context.Context, routes *mux.Router) error {
-	s.wg.Add(1)
-	listenAddress := fmt.Sprintf("%s:%d", s.webCfg.Address, s.webCfg.Port)
+	if s.webCfg.Port == 0 && s.socket == "" {
+		return nil
+	}
 	//nolint: gosec
 	s.httpServer = &http.Server{
 		Handler: routes,
 	}
+	s.httpServer.RegisterOnShutdown(s.stopStreams)
+	var socketLn net.Listener
+	if s.socket != "" {
+		var err error
+		if socketLn, err = s.listenSocket(); err != nil {
+			return err
+		}
+	}
+	if s.webCfg.Port != 0 {
+		if err := s.serveTCP(); err != nil {
+			if socketLn != nil {
+				_ = socketLn.Close()
+			}
+			return err
+		}
+	}
+	s.wg.Add(1)
+	if socketLn != nil {
+		go s.serveSocket(socketLn)
+	}
+	return nil
+}
+func (s *Server) serveTCP() error {
+	listenAddress := fmt.Sprintf("%s:%d", s.webCfg.Address, s.webCfg.Port)
 	ln, err := net.Listen("tcp", listenAddress)
 	if err != nil {
 		return err
 	}
 
 	if s.webCfg.CertificateFile != "" {
-		certFile := s.webCfg.CertificateFile
-		keyFile := s.webCfg.CertificateKey
-		go s.serveHTTPS(ln, listenAddress, certFile, keyFile)
+		if s.certificates, err = newCertificates(s.webCfg); err != nil {
+			_ = ln.Close()
+			return err
+		}
+		s.httpServer.TLSConfig = s.certificates.tlsConfig()
+		go s.serveHTTPS(ln, listenAddress)
 	} else {
 		go s.serveHTTP(ln, listenAddress)
 	}
 	return nil
 }
-func (s *Server) serveHTTPS(ln net.Listener, listenAddress, certFile, keyFile string) {
+func (s *Server) serveSocket(ln net.Listener) {
+	fmt.Printf("Listening on unix -> %s\n", s.socket)
+	err := s.httpServer.Serve(ln)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("web socket has shut down: %v\n", err)
+	}
+}
+func (s *Server) serveHTTPS(ln net.Listener, listenAddress string) {
 	fmt.Printf("Listening on https -> %s\n", listenAddress)
-	err := s.httpServer.ServeTLS(ln, certFile, keyFile)
+	err := s.httpServer.ServeTLS(ln, "", "")
 	if err != nil {
 		fmt.Printf("web server has shut down: %v\n", err)
 	}
@@ -0,0 +1,77 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+var (
+	socketIdentity = &managerModels.Identity{Name: "socket", Role: config.RoleAdmin}
+)
+
+// validateSocket resolves the socket the api is served on.  A socket is only served from
+// a directory of the user's own.  A socket left behind by a
+// server that did not shut down cleanly is removed.  If another server is listening on
+// the default socket this one goes without, rather than failing to start
+func (s *Server) validateSocket(v *config.Validations) {
+	path := s.webCfg.SocketPath()
+	if path == "" {
+		v.Infof("web socket disabled. web.socket=%s", config.SocketDisabled)
+		return
+	}
+	if err := config.PrivateDir(filepath.Dir(path)); err != nil {
+		v.Errorf("web.socket directory cannot be used: %v", err)
+		return
+	}
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			v.Errorf("web.socket (%s) exists and is not a socket", path)
+			return
+		}
+		if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+			_ = conn.Close()
+			if s.webCfg.Socket == "" {
+				v.Warnf("web.socket not served.  Another auto-ssh is listening on %s", path)
+				return
+			}
+			v.Errorf("web.socket is already in use [%s]", path)
+			return
+		}
+		if err = os.Remove(path); err != nil {
+			v.Errorf("web.socket (%s) is stale and cannot be removed: %v", path, err)
+			return
+		}
+	}
+	s.socket = path
+}
+
+// listenSocket listens on the socket, readable and writable only by the user the server
+// runs as.  It is created that way, rather than changed once anyone could connect to it
+func (s *Server) listenSocket() (net.Listener, error) {
+	ln, err := listenPrivate(s.socket)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(s.socket, 0600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// socketRequest reports whether req arrived on the socket.  Only the user the server runs
+// as can connect to it, so these requests are trusted as admin
+func socketRequest(req *http.Request) bool {
+	addr, ok := req.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+var (
+	umaskLock sync.Mutex
+)
+
+// listenPrivate listens on a unix socket created with no access for group or others.  The
+// umask is the process's, so it is restored straight after
+func listenPrivate(path string) (net.Listener, error) {
+	umaskLock.Lock()
+	defer umaskLock.Unlock()
+	umask := syscall.Umask(0077)
+	defer syscall.Umask(umask)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,31 @@
+//go:build !windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenPrivate(t *testing.T) {
+	umask := syscall.Umask(0)
+	defer syscall.Umask(umask)
+	path := filepath.Join(t.TempDir(), "ash.sock")
+	ln, err := listenPrivate(path)
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Zero(t, fi.Mode().Perm()&0077, "no one else can connect, even before it is chmod'd")
+	assert.Equal(t, os.FileMode(0), os.FileMode(syscall.Umask(0)), "the umask is restored")
+}
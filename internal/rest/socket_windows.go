@@ -0,0 +1,17 @@
+//go:build windows
+
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"net"
+)
+
+// listenPrivate listens on a unix socket.  Windows has no umask, the socket inheriting the
+// access of the directory it is in
+func listenPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright (C) 2024 by Jason Figge
+ */
+
+package rest
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"us.figge.auto-ssh/internal/core/config"
+	"us.figge.auto-ssh/internal/core/utils"
+	managerModels "us.figge.auto-ssh/internal/rest/models"
+)
+
+const (
+	certificateCheckInterval = 2 * time.Second
+)
+
+var (
+	ErrClientCA = errors.New("web.clientCA contains no pem encoded certificates")
+)
+
+// certificates holds the server certificate and the client CAs, reloading them when
+// their files change so they can be rotated without a restart.  A reload that fails
+// leaves the previous certificates in use
+type certificates struct {
+	lock       sync.RWMutex
+	certFile   string
+	keyFile    string
+	passphrase string
+	caFile     string
+	checked    time.Time
+	modTimes   map[string]time.Time
+	cert       *tls.Certificate
+	clientCAs  *x509.CertPool
+}
+
+func newCertificates(web *config.Web) (*certificates, error) {
+	c := &certificates{
+		certFile:   web.CertificateFile,
+		keyFile:    web.CertificateKey,
+		passphrase: web.KeyPassphrase,
+		caFile:     web.ClientCA,
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certificates) load() error {
+	modTimes := make(map[string]time.Time)
+	for _, file := range []string{c.certFile, c.keyFile, c.caFile} {
+		if file == "" {
+			continue
+		}
+		fi, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = fi.ModTime()
+	}
+	cert, err := loadKeyPair(c.certFile, c.keyFile, c.passphrase)
+	if err != nil {
+		return err
+	}
+	var clientCAs *x509.CertPool
+	if c.caFile != "" {
+		if clientCAs, err = loadCertPool(c.caFile); err != nil {
+			return err
+		}
+	}
+	c.lock.Lock()
+	c.cert, c.clientCAs, c.modTimes = &cert, clientCAs, modTimes
+	c.lock.Unlock()
+	return nil
+}
+
+// refresh reloads the certificates if any of their files have changed since they were
+// loaded.  Files are checked at most every couple of seconds
+func (c *certificates) refresh() {
+	c.lock.Lock()
+	if time.Since(c.checked) < certificateCheckInterval {
+		c.lock.Unlock()
+		return
+	}
+	c.checked = time.Now()
+	changed := false
+	for file, modTime := range c.modTimes {
+		if fi, err := os.Stat(file); err == nil && !fi.ModTime().Equal(modTime) {
+			changed = true
+		}
+	}
+	c.lock.Unlock()
+	if !changed {
+		return
+	}
+	if err := c.load(); err != nil {
+		fmt.Printf("  Error - failed to reload web certificates.  Continuing with the previous ones: %v\n", err)
+		return
+	}
+	fmt.Printf("  Info  - web certificates reloaded\n")
+}
+
+// tlsConfig returns the configuration the server listens with.  Each handshake is given
+// a clone of it holding the certificates current at the time, so the protocols offered
+// and session ticket keys stay those of the server
+func (c *certificates) tlsConfig() *tls.Config {
+	server := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	server.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c.refresh()
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+		cfg := server.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.Certificates = []tls.Certificate{*c.cert}
+		if c.clientCAs != nil {
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			cfg.ClientCAs = c.clientCAs
+		}
+		return cfg, nil
+	}
+	return server
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	bs, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bs) {
+		return nil, fmt.Errorf("%w: %s", ErrClientCA, file)
+	}
+	return pool, nil
+}
+
+// certificateIdentity maps the verified client certificate of a request to the first of
+// web.clients matching it
+func certificateIdentity(clients []*config.ClientCert, cert *x509.Certificate) *managerModels.Identity {
+	names := []string{cert.Subject.CommonName, cert.Subject.String()}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, client := range clients {
+		for _, name := range names {
+			if name != "" && utils.MatchWildcard(client.Subject, name) {
+				return &managerModels.Identity{
+					Name: utils.DefaultString(cert.Subject.CommonName, name),
+					Role: client.Role,
+					Tags: client.Tags,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) validateClientCA(v *config.Validations) {
+	for i, client := range s.webCfg.Clients {
+		client.Subject = strings.TrimSpace(client.Subject)
+		if client.Subject == "" {
+			v.Errorf("web.clients[%d] requires a subject", i)
+		}
+		client.Role = strings.ToLower(strings.TrimSpace(client.Role))
+		if client.Role == "" {
+			client.Role = config.RoleReadOnly
+		} else if !config.RoleAllows(client.Role, config.RoleReadOnly) {
+			v.Errorf("web.clients (%s) role (%s) is invalid.  Must be one of: %s", client.Subject, client.Role, strings.Join(config.Roles(), ", "))
+		}
+	}
+	if s.webCfg.ClientCA == "" {
+		if len(s.webCfg.Clients) > 0 {
+			v.Warnf("web.clients ignored.  web.clientCA is not set")
+		}
+		return
+	}
+	if s.webCfg.CertificateFile == "" {
+		v.Errorf("web.clientCA requires web.certificateFile, client certificates are only used with https")
+		return
+	}
+	if _, err := loadCertPool(s.webCfg.ClientCA); err != nil {
+		v.Errorf("web.clientCA cannot be read: %v", err)
+	}
+	if len(s.webCfg.Clients) == 0 {
+		v.Warnf("web.clients not set.  Only tokens will be accepted from clients with a certificate")
+	}
+}
@@ -7,6 +7,8 @@ package main
 import (
 	"us.figge.auto-ssh/internal/cmd"
 	_ "us.figge.auto-ssh/internal/cmd/core"
+	_ "us.figge.auto-ssh/internal/cmd/core/monitor"
+	_ "us.figge.auto-ssh/internal/cmd/daemon"
 	_ "us.figge.auto-ssh/internal/cmd/hosts"
 	_ "us.figge.auto-ssh/internal/cmd/tunnels"
 )